@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"index-duel-backend/commalist"
+	"index-duel-backend/service"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+const apiKeyUsage = `usage: index-duel-backend apikey <command> [flags]
+
+commands:
+  create -name NAME -scopes sync:read[,decks:write,admin]
+  rotate ID
+  revoke ID
+  list`
+
+// runAPIKeyCommand manages API keys from the command line, so the first admin key
+// can be issued before any exist
+func runAPIKeyCommand(ctx context.Context, keys *service.APIKeyService, args []string) error {
+	if len(args) == 0 {
+		return errors.New(apiKeyUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		fs := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+		name := fs.String("name", "", "name describing the client the key is for")
+		scopes := fs.String("scopes", "", "comma-separated scopes to grant")
+		if err := fs.Parse(args[1:]); err != nil {
+			return err
+		}
+		issued, err := keys.CreateAPIKey(ctx, *name, commalist.Split(*scopes))
+		if err != nil {
+			return err
+		}
+		return printIssuedAPIKey(issued)
+
+	case "rotate":
+		id, err := apiKeyIDArg(args)
+		if err != nil {
+			return err
+		}
+		issued, err := keys.RotateAPIKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		if issued == nil {
+			return fmt.Errorf("no active api key %d", id)
+		}
+		return printIssuedAPIKey(issued)
+
+	case "revoke":
+		id, err := apiKeyIDArg(args)
+		if err != nil {
+			return err
+		}
+		revoked, err := keys.RevokeAPIKey(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !revoked {
+			return fmt.Errorf("no active api key %d", id)
+		}
+		fmt.Printf("Revoked api key %d\n", id)
+		return nil
+
+	case "list":
+		list, err := keys.ListAPIKeys(ctx)
+		if err != nil {
+			return err
+		}
+		tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(tw, "ID\tNAME\tPREFIX\tSCOPES\tCREATED\tLAST USED\tSTATUS")
+		for _, key := range list {
+			lastUsed, status := "-", "active"
+			if key.LastUsedAt != nil {
+				lastUsed = key.LastUsedAt.Format("2006-01-02 15:04")
+			}
+			if key.RevokedAt != nil {
+				status = "revoked"
+			}
+			fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\t%s\t%s\n", key.ID, key.Name, key.Prefix,
+				strings.Join(key.Scopes, ","), key.CreatedAt.Format("2006-01-02 15:04"), lastUsed, status)
+		}
+		return tw.Flush()
+	}
+
+	return fmt.Errorf("unknown apikey command %q\n%s", args[0], apiKeyUsage)
+}
+
+func apiKeyIDArg(args []string) (int64, error) {
+	if len(args) != 2 {
+		return 0, fmt.Errorf("usage: index-duel-backend apikey %s ID", args[0])
+	}
+	id, err := strconv.ParseInt(args[1], 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid api key id %q", args[1])
+	}
+	return id, nil
+}
+
+// printIssuedAPIKey prints a new key; this is the only time the secret is shown
+func printIssuedAPIKey(issued *service.IssuedAPIKey) error {
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(issued); err != nil {
+		return err
+	}
+	fmt.Fprintln(os.Stderr, "Store this key now; it cannot be shown again.")
+	return nil
+}
@@ -0,0 +1,393 @@
+// Package bundle generates the prebuilt SQLite catalogue that new app installs
+// download instead of running a full JSON sync.
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	_ "modernc.org/sqlite"
+)
+
+const (
+	manifestFile = "manifest.json"
+	filePrefix   = "catalogue-"
+	fileSuffix   = ".sqlite"
+)
+
+// Manifest describes the current catalogue bundle. After installing a bundle, the
+// app syncs with Seq as since_seq to receive only the changes made since it was
+// built. LastUpdate serves clients still on timestamp-based sync.
+type Manifest struct {
+	Version        string    `json:"version"`
+	SchemaVersion  int       `json:"schema_version"`
+	LastUpdate     string    `json:"last_update"`
+	Seq            int64     `json:"seq"`
+	CreatedAt      time.Time `json:"created_at"`
+	CardCount      int       `json:"card_count"`
+	IncludesImages bool      `json:"includes_images"`
+	FileName       string    `json:"file_name"`
+	Size           int64     `json:"size"`
+	SHA256         string    `json:"sha256"`
+}
+
+// CardStore is the card storage Builder reads from. repository.CardRepository
+// implements it on Postgres and repository.MemoryCardStore in memory.
+type CardStore interface {
+	GetAllCardsForFirstSync(ctx context.Context, relations models.CardRelations) (*repository.CardIterator, int64, error)
+	ForEachSmallImage(ctx context.Context, fn func(imageID int, cardID int64, data []byte) error) error
+}
+
+var (
+	_ CardStore = (*repository.CardRepository)(nil)
+	_ CardStore = (*repository.MemoryCardStore)(nil)
+)
+
+// Builder writes catalogue bundles into a directory and tracks the current one
+type Builder struct {
+	repo          CardStore
+	dir           string
+	includeImages bool
+
+	buildMu sync.Mutex
+	mu      sync.RWMutex
+	current *Manifest
+}
+
+// NewBuilder creates a bundle builder writing into dir, picking up any bundle
+// already present there
+func NewBuilder(repo CardStore, dir string, includeImages bool) (*Builder, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create bundle directory: %w", err)
+	}
+
+	b := &Builder{
+		repo:          repo,
+		dir:           dir,
+		includeImages: includeImages,
+	}
+
+	manifest, err := b.readManifest()
+	if err != nil {
+		return nil, err
+	}
+	b.current = manifest
+	return b, nil
+}
+
+// Current returns the manifest of the latest bundle, or nil if none has been built
+func (b *Builder) Current() *Manifest {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.current
+}
+
+// Path returns the file path of the bundle described by manifest
+func (b *Builder) Path(manifest *Manifest) string {
+	return filepath.Join(b.dir, manifest.FileName)
+}
+
+// Build generates a new bundle from the database and makes it the current one
+func (b *Builder) Build(ctx context.Context) (*Manifest, error) {
+	b.buildMu.Lock()
+	defer b.buildMu.Unlock()
+
+	now := time.Now().UTC()
+	manifest := &Manifest{
+		Version:        now.Format("20060102T150405Z"),
+		SchemaVersion:  SchemaVersion,
+		CreatedAt:      now,
+		IncludesImages: b.includeImages,
+	}
+	manifest.FileName = filePrefix + manifest.Version + fileSuffix
+
+	tmpPath := filepath.Join(b.dir, manifest.FileName+".tmp")
+	os.Remove(tmpPath)
+	defer os.Remove(tmpPath)
+
+	count, err := b.writeDatabase(ctx, tmpPath, manifest)
+	if err != nil {
+		return nil, err
+	}
+	manifest.CardCount = count
+
+	size, sum, err := checksum(tmpPath)
+	if err != nil {
+		return nil, err
+	}
+	manifest.Size = size
+	manifest.SHA256 = sum
+
+	if err := os.Rename(tmpPath, b.Path(manifest)); err != nil {
+		return nil, fmt.Errorf("failed to move bundle into place: %w", err)
+	}
+	if err := b.writeManifest(manifest); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	b.current = manifest
+	b.mu.Unlock()
+
+	b.removeStaleBundles(ctx, manifest)
+
+	slog.InfoContext(ctx, "built catalogue bundle", "version", manifest.Version, "cards", manifest.CardCount, "bytes", manifest.Size)
+	return manifest, nil
+}
+
+// writeDatabase creates the SQLite file at path and copies the catalogue into it
+func (b *Builder) writeDatabase(ctx context.Context, path string, manifest *Manifest) (int, error) {
+	db, err := sql.Open("sqlite", path)
+	if err != nil {
+		return 0, fmt.Errorf("failed to create bundle database: %w", err)
+	}
+	defer db.Close()
+
+	if _, err := db.ExecContext(ctx, schema); err != nil {
+		return 0, fmt.Errorf("failed to create bundle schema: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA user_version = "+strconv.Itoa(SchemaVersion)); err != nil {
+		return 0, fmt.Errorf("failed to set bundle schema version: %w", err)
+	}
+
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, fmt.Errorf("failed to begin bundle transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	cards, seq, err := b.repo.GetAllCardsForFirstSync(ctx, models.AllCardRelations)
+	if err != nil {
+		return 0, fmt.Errorf("failed to read cards for bundle: %w", err)
+	}
+	defer cards.Close()
+	// Both cursors come from the snapshot the cards are read from, so changes made
+	// while building, or not yet on the replica read, are picked up by the app's
+	// first incremental sync
+	manifest.Seq = seq
+	if through := cards.UpdatedThrough(); !through.IsZero() {
+		manifest.LastUpdate = through.UTC().Format(time.RFC3339)
+	}
+
+	info := map[string]string{
+		"version":        manifest.Version,
+		"last_update":    manifest.LastUpdate,
+		"seq":            strconv.FormatInt(seq, 10),
+		"schema_version": strconv.Itoa(SchemaVersion),
+	}
+	for key, value := range info {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO bundle_info (key, value) VALUES (?, ?)", key, value); err != nil {
+			return 0, fmt.Errorf("failed to write bundle info: %w", err)
+		}
+	}
+
+	w, err := newCardWriter(ctx, tx)
+	if err != nil {
+		return 0, err
+	}
+	defer w.Close()
+
+	count := 0
+	for cards.Next() {
+		if err := w.WriteCard(ctx, cards.Card()); err != nil {
+			return 0, fmt.Errorf("failed to write card to bundle: %w", err)
+		}
+		count++
+	}
+	if err := cards.Err(); err != nil {
+		return 0, fmt.Errorf("failed to read cards for bundle: %w", err)
+	}
+
+	if b.includeImages {
+		err := b.repo.ForEachSmallImage(ctx, func(imageID int, cardID int64, data []byte) error {
+			_, err := tx.ExecContext(ctx, "UPDATE card_images SET image_small_data = ? WHERE id = ?", data, imageID)
+			return err
+		})
+		if err != nil {
+			return 0, fmt.Errorf("failed to write images to bundle: %w", err)
+		}
+	}
+
+	if err := w.Close(); err != nil {
+		return 0, err
+	}
+	if err := tx.Commit(); err != nil {
+		return 0, fmt.Errorf("failed to commit bundle: %w", err)
+	}
+	if _, err := db.ExecContext(ctx, "VACUUM"); err != nil {
+		return 0, fmt.Errorf("failed to compact bundle: %w", err)
+	}
+
+	return count, nil
+}
+
+// removeStaleBundles deletes bundle files other than the current one
+func (b *Builder) removeStaleBundles(ctx context.Context, current *Manifest) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		slog.WarnContext(ctx, "failed to list bundle directory", "error", err)
+		return
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if name == current.FileName || !strings.HasPrefix(name, filePrefix) || !strings.HasSuffix(name, fileSuffix) {
+			continue
+		}
+		if err := os.Remove(filepath.Join(b.dir, name)); err != nil {
+			slog.WarnContext(ctx, "failed to remove stale bundle", "file", name, "error", err)
+		}
+	}
+}
+
+// readManifest loads the manifest of the bundle left by a previous run, if any
+func (b *Builder) readManifest() (*Manifest, error) {
+	data, err := os.ReadFile(filepath.Join(b.dir, manifestFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read bundle manifest: %w", err)
+	}
+
+	manifest := &Manifest{}
+	if err := json.Unmarshal(data, manifest); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle manifest: %w", err)
+	}
+	if _, err := os.Stat(b.Path(manifest)); err != nil {
+		slog.Warn("ignoring bundle manifest whose file is missing", "error", err)
+		return nil, nil
+	}
+	return manifest, nil
+}
+
+// writeManifest atomically replaces the manifest file
+func (b *Builder) writeManifest(manifest *Manifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode bundle manifest: %w", err)
+	}
+
+	tmpPath := filepath.Join(b.dir, manifestFile+".tmp")
+	if err := os.WriteFile(tmpPath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write bundle manifest: %w", err)
+	}
+	if err := os.Rename(tmpPath, filepath.Join(b.dir, manifestFile)); err != nil {
+		return fmt.Errorf("failed to move bundle manifest into place: %w", err)
+	}
+	return nil
+}
+
+// checksum returns the size and hex SHA-256 digest of a file
+func checksum(path string) (int64, string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open bundle: %w", err)
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to checksum bundle: %w", err)
+	}
+	return size, hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// cardWriter inserts cards and their related rows through prepared statements
+type cardWriter struct {
+	card, set, image, price *sql.Stmt
+}
+
+func newCardWriter(ctx context.Context, tx *sql.Tx) (*cardWriter, error) {
+	w := &cardWriter{}
+	statements := []struct {
+		stmt  **sql.Stmt
+		query string
+	}{
+		{&w.card, `INSERT INTO cards (id, name, type, frame_type, description, atk, def, level, race, attribute, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&w.set, `INSERT INTO card_sets (id, card_id, set_name, set_code, set_rarity, set_rarity_code, set_price, created_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?)`},
+		{&w.image, `INSERT INTO card_images (id, card_id, image_url, image_url_small, image_url_cropped, created_at)
+			VALUES (?, ?, ?, ?, ?, ?)`},
+		{&w.price, `INSERT INTO card_prices (id, card_id, cardmarket_price, tcgplayer_price, ebay_price, amazon_price, coolstuffinc_price, created_at, updated_at)
+			VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)`},
+	}
+
+	for _, s := range statements {
+		stmt, err := tx.PrepareContext(ctx, s.query)
+		if err != nil {
+			w.Close()
+			return nil, fmt.Errorf("failed to prepare bundle statement: %w", err)
+		}
+		*s.stmt = stmt
+	}
+	return w, nil
+}
+
+// WriteCard inserts a card together with its sets, images and prices
+func (w *cardWriter) WriteCard(ctx context.Context, card *models.Card) error {
+	_, err := w.card.ExecContext(ctx, card.ID, card.Name, card.Type, card.FrameType, card.Description,
+		card.ATK, card.DEF, card.Level, card.Race, card.Attribute, formatTime(card.CreatedAt), formatTime(card.UpdatedAt))
+	if err != nil {
+		return err
+	}
+
+	for _, set := range card.CardSets {
+		_, err := w.set.ExecContext(ctx, set.ID, card.ID, set.SetName, set.SetCode, set.SetRarity,
+			set.SetRarityCode, set.SetPrice, formatTime(set.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	for _, image := range card.CardImages {
+		_, err := w.image.ExecContext(ctx, image.ID, card.ID, image.ImageURL, image.ImageURLSmall,
+			image.ImageURLCropped, formatTime(image.CreatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	for _, price := range card.CardPrices {
+		_, err := w.price.ExecContext(ctx, price.ID, card.ID, price.CardMarketPrice, price.TCGPlayerPrice,
+			price.EbayPrice, price.AmazonPrice, price.CoolStuffIncPrice, formatTime(price.CreatedAt), formatTime(price.UpdatedAt))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Close releases the prepared statements
+func (w *cardWriter) Close() error {
+	var firstErr error
+	for _, stmt := range []*sql.Stmt{w.card, w.set, w.image, w.price} {
+		if stmt == nil {
+			continue
+		}
+		if err := stmt.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	w.card, w.set, w.image, w.price = nil, nil, nil, nil
+	return firstErr
+}
+
+// formatTime stores timestamps the way the sync API serialises them
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,133 @@
+package bundle
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// newTestStore returns an in-memory store holding two cards, the first with a set,
+// a price and an image with small image data
+func newTestStore(t *testing.T) *repository.MemoryCardStore {
+	t.Helper()
+	price := "1.50"
+	store := repository.NewMemoryCardStore()
+	cards := []models.Card{
+		{
+			ID: 46986414, Name: "Dark Magician", Type: "Normal Monster",
+			CardSets:   []models.CardSet{{SetName: "Legend of Blue Eyes", SetCode: "LOB-005", SetPrice: &price}},
+			CardImages: []models.CardImage{{ImageURLSmall: "https://images.example.com/small/46986414.jpg", ImageSmallData: []byte("small jpeg")}},
+			CardPrices: []models.CardPrice{{TCGPlayerPrice: &price}},
+		},
+		{ID: 40640057, Name: "Kuriboh", Type: "Effect Monster"},
+	}
+	if err := store.CreateCards(context.Background(), cards); err != nil {
+		t.Fatal(err)
+	}
+	return store
+}
+
+func TestBuild(t *testing.T) {
+	for _, includeImages := range []bool{false, true} {
+		name := "without images"
+		if includeImages {
+			name = "with images"
+		}
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			dir := t.TempDir()
+			builder, err := NewBuilder(newTestStore(t), dir, includeImages)
+			if err != nil {
+				t.Fatalf("NewBuilder failed: %v", err)
+			}
+			if builder.Current() != nil {
+				t.Fatal("new builder has a current bundle")
+			}
+
+			manifest, err := builder.Build(ctx)
+			if err != nil {
+				t.Fatalf("Build failed: %v", err)
+			}
+			if manifest.CardCount != 2 || manifest.Seq != 2 || manifest.SchemaVersion != SchemaVersion ||
+				manifest.IncludesImages != includeImages || builder.Current() != manifest {
+				t.Errorf("manifest = %+v", manifest)
+			}
+
+			data, err := os.ReadFile(builder.Path(manifest))
+			if err != nil {
+				t.Fatalf("bundle file missing: %v", err)
+			}
+			sum := sha256.Sum256(data)
+			if manifest.SHA256 != hex.EncodeToString(sum[:]) || manifest.Size != int64(len(data)) {
+				t.Errorf("manifest checksum %s of %d bytes does not match the file", manifest.SHA256, manifest.Size)
+			}
+
+			db, err := sql.Open("sqlite", builder.Path(manifest))
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer db.Close()
+
+			var version int
+			db.QueryRow("PRAGMA user_version").Scan(&version)
+			var seq string
+			db.QueryRow("SELECT value FROM bundle_info WHERE key = 'seq'").Scan(&seq)
+			if version != SchemaVersion || seq != "2" {
+				t.Errorf("user_version = %d, bundle seq = %q", version, seq)
+			}
+
+			var setCode, tcgPrice string
+			var smallImage []byte
+			err = db.QueryRow(`SELECT s.set_code, p.tcgplayer_price, i.image_small_data
+				FROM cards c
+				JOIN card_sets s ON s.card_id = c.id
+				JOIN card_prices p ON p.card_id = c.id
+				JOIN card_images i ON i.card_id = c.id
+				WHERE c.name = 'Dark Magician'`).Scan(&setCode, &tcgPrice, &smallImage)
+			if err != nil {
+				t.Fatalf("failed to read card back: %v", err)
+			}
+			wantImage := ""
+			if includeImages {
+				wantImage = "small jpeg"
+			}
+			if setCode != "LOB-005" || tcgPrice != "1.50" || string(smallImage) != wantImage {
+				t.Errorf("read back set %q, price %q, image %q", setCode, tcgPrice, smallImage)
+			}
+		})
+	}
+}
+
+func TestNewBuilderPicksUpExistingBundle(t *testing.T) {
+	dir := t.TempDir()
+	store := newTestStore(t)
+	first, err := NewBuilder(store, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	built, err := first.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reopened, err := NewBuilder(store, dir, false)
+	if err != nil {
+		t.Fatalf("NewBuilder failed: %v", err)
+	}
+	if current := reopened.Current(); current == nil || current.SHA256 != built.SHA256 {
+		t.Errorf("current = %+v, want the bundle built before", current)
+	}
+
+	// A manifest whose file has gone is ignored rather than served
+	os.Remove(filepath.Join(dir, built.FileName))
+	reopened, err = NewBuilder(store, dir, false)
+	if err != nil || reopened.Current() != nil {
+		t.Errorf("current = %+v, %v; want no bundle", reopened.Current(), err)
+	}
+}
@@ -0,0 +1,68 @@
+package bundle
+
+// SchemaVersion is stored in PRAGMA user_version and the manifest so the app can
+// reject bundles it does not understand
+const SchemaVersion = 1
+
+// schema mirrors the tables the app keeps locally. It matches the Postgres layout
+// minus the full-size image blobs, which are never shipped to devices.
+const schema = `
+CREATE TABLE bundle_info (
+    key   TEXT PRIMARY KEY,
+    value TEXT NOT NULL
+);
+
+CREATE TABLE cards (
+    id          INTEGER PRIMARY KEY,
+    name        TEXT NOT NULL,
+    type        TEXT NOT NULL,
+    frame_type  TEXT NOT NULL,
+    description TEXT NOT NULL,
+    atk         INTEGER,
+    def         INTEGER,
+    level       INTEGER,
+    race        TEXT NOT NULL,
+    attribute   TEXT NOT NULL,
+    created_at  TEXT NOT NULL,
+    updated_at  TEXT NOT NULL
+);
+
+CREATE TABLE card_sets (
+    id              INTEGER PRIMARY KEY,
+    card_id         INTEGER NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    set_name        TEXT NOT NULL,
+    set_code        TEXT NOT NULL,
+    set_rarity      TEXT NOT NULL,
+    set_rarity_code TEXT NOT NULL,
+    set_price       TEXT,
+    created_at      TEXT NOT NULL
+);
+
+CREATE INDEX idx_card_sets_card_id ON card_sets (card_id);
+
+CREATE TABLE card_images (
+    id                INTEGER PRIMARY KEY,
+    card_id           INTEGER NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    image_url         TEXT NOT NULL,
+    image_url_small   TEXT NOT NULL,
+    image_url_cropped TEXT NOT NULL,
+    image_small_data  BLOB,
+    created_at        TEXT NOT NULL
+);
+
+CREATE INDEX idx_card_images_card_id ON card_images (card_id);
+
+CREATE TABLE card_prices (
+    id                 INTEGER PRIMARY KEY,
+    card_id            INTEGER NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    cardmarket_price   TEXT,
+    tcgplayer_price    TEXT,
+    ebay_price         TEXT,
+    amazon_price       TEXT,
+    coolstuffinc_price TEXT,
+    created_at         TEXT NOT NULL,
+    updated_at         TEXT NOT NULL
+);
+
+CREATE INDEX idx_card_prices_card_id ON card_prices (card_id);
+`
@@ -0,0 +1,16 @@
+// Package commalist parses comma-separated lists. Configuration settings, CLI flags
+// and query parameters are all written this way.
+package commalist
+
+import "strings"
+
+// Split splits a comma-separated list, trimming entries and dropping empty ones
+func Split(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
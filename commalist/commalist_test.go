@@ -0,0 +1,25 @@
+package commalist
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplit(t *testing.T) {
+	tests := []struct {
+		value string
+		want  []string
+	}{
+		{"", nil},
+		{"sync:read", []string{"sync:read"}},
+		{" sync:read , admin ", []string{"sync:read", "admin"}},
+		{"a,,b,", []string{"a", "b"}},
+		{" , ", nil},
+	}
+
+	for _, tt := range tests {
+		if got := Split(tt.value); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Split(%q) = %q, want %q", tt.value, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,182 @@
+// Package config loads the service configuration into one typed struct from
+// defaults, an optional YAML file, environment variables and command line flags,
+// and validates it before anything starts.
+package config
+
+import (
+	"time"
+)
+
+// Config is the complete service configuration. Every setting has a yaml key, an
+// environment variable and a flag named after its dotted yaml path, e.g.
+// -server.port. Settings tagged secret are redacted when printed.
+type Config struct {
+	Server    ServerConfig    `yaml:"server"`
+	Log       LogConfig       `yaml:"log"`
+	Tracing   TracingConfig   `yaml:"tracing"`
+	Database  DatabaseConfig  `yaml:"database"`
+	Ingest    IngestConfig    `yaml:"ingest"`
+	Sync      SyncConfig      `yaml:"sync"`
+	Bundle    BundleConfig    `yaml:"bundle"`
+	Auth      AuthConfig      `yaml:"auth"`
+	RateLimit RateLimitConfig `yaml:"rate_limit"`
+	CORS      CORSConfig      `yaml:"cors"`
+	Health    HealthConfig    `yaml:"health"`
+}
+
+// ServerConfig configures the HTTP listener
+type ServerConfig struct {
+	Port        int `yaml:"port" env:"PORT" usage:"port to listen on"`
+	MetricsPort int `yaml:"metrics_port" env:"METRICS_PORT" usage:"port serving /metrics to scrapers inside the deployment; 0 disables it"`
+}
+
+// LogConfig configures structured logging
+type LogConfig struct {
+	Level  string `yaml:"level" env:"LOG_LEVEL" usage:"minimum log level: debug, info, warn or error"`
+	Format string `yaml:"format" env:"LOG_FORMAT" usage:"log encoding: json or text"`
+}
+
+// TracingConfig configures OpenTelemetry span export
+type TracingConfig struct {
+	Exporter    string  `yaml:"exporter" env:"TRACING_EXPORTER" usage:"span exporter: none, otlp or stdout"`
+	SampleRatio float64 `yaml:"sample_ratio" env:"TRACING_SAMPLE_RATIO" usage:"fraction of new traces to record"`
+}
+
+// DatabaseConfig configures the Postgres connection and pool
+type DatabaseConfig struct {
+	URL      string `yaml:"url" env:"DATABASE_URL" secret:"url" usage:"Postgres connection URL; replaces host, port, name, user and password"`
+	Host     string `yaml:"host" env:"PG_HOST" usage:"Postgres host"`
+	Port     int    `yaml:"port" env:"PG_PORT" usage:"Postgres port"`
+	Name     string `yaml:"name" env:"PG_DATABASE" usage:"Postgres database name"`
+	User     string `yaml:"user" env:"PG_USER" usage:"Postgres user"`
+	Password string `yaml:"password" env:"PG_PASSWORD" secret:"true" usage:"Postgres password"`
+
+	SSLMode     string `yaml:"sslmode" env:"PG_SSLMODE" usage:"TLS mode: disable, require, verify-ca or verify-full; empty keeps the URL's mode or disables TLS"`
+	SSLRootCert string `yaml:"sslrootcert" env:"PG_SSLROOTCERT" usage:"CA file used to verify the server certificate"`
+	SSLCert     string `yaml:"sslcert" env:"PG_SSLCERT" usage:"client certificate file"`
+	SSLKey      string `yaml:"sslkey" env:"PG_SSLKEY" usage:"client private key file"`
+
+	ApplicationName  string        `yaml:"application_name" env:"PG_APPLICATION_NAME" usage:"application name reported to Postgres"`
+	ConnectTimeout   time.Duration `yaml:"connect_timeout" env:"PG_CONNECT_TIMEOUT" usage:"timeout for opening each connection; 0 waits indefinitely"`
+	StatementTimeout time.Duration `yaml:"statement_timeout" env:"PG_STATEMENT_TIMEOUT" usage:"server-side limit on statement run time; 0 disables it"`
+	RetryTimeout     time.Duration `yaml:"retry_timeout" env:"PG_RETRY_TIMEOUT" usage:"how long startup retries an unreachable database"`
+
+	MaxOpenConns    int           `yaml:"max_open_conns" env:"PG_MAX_OPEN_CONNS" usage:"maximum open connections; 0 is unlimited"`
+	MaxIdleConns    int           `yaml:"max_idle_conns" env:"PG_MAX_IDLE_CONNS" usage:"maximum idle connections kept in the pool"`
+	ConnMaxLifetime time.Duration `yaml:"conn_max_lifetime" env:"PG_CONN_MAX_LIFETIME" usage:"maximum age of a connection; 0 keeps connections indefinitely"`
+	ConnMaxIdleTime time.Duration `yaml:"conn_max_idle_time" env:"PG_CONN_MAX_IDLE_TIME" usage:"maximum time a connection may sit idle; 0 keeps idle connections"`
+
+	ReplicaURLs          []string      `yaml:"replica_urls" env:"PG_REPLICA_URLS" secret:"url" usage:"comma-separated connection URLs of read replicas used for sync and card reads"`
+	ReplicaMaxLag        time.Duration `yaml:"replica_max_lag" env:"PG_REPLICA_MAX_LAG" usage:"replication lag beyond which reads fall back to the primary"`
+	ReplicaCheckInterval time.Duration `yaml:"replica_check_interval" env:"PG_REPLICA_CHECK_INTERVAL" usage:"how often replica lag is measured"`
+}
+
+// IngestConfig configures fetching the upstream catalogue
+type IngestConfig struct {
+	Source      string        `yaml:"source" env:"INGEST_SOURCE" usage:"where the catalogue comes from: http, file or dir"`
+	APIURL      string        `yaml:"api_url" env:"API" usage:"upstream card catalogue URL for the http source; ingest is skipped when empty"`
+	Path        string        `yaml:"path" env:"INGEST_PATH" usage:"catalogue JSON file for the file source, or fixture directory for the dir source"`
+	Interval    time.Duration `yaml:"interval" env:"INGEST_INTERVAL" usage:"time between scheduled ingests"`
+	BatchSize   int           `yaml:"batch_size" env:"INGEST_BATCH_SIZE" usage:"cards stored per transaction"`
+	BatchDelay  time.Duration `yaml:"batch_delay" env:"INGEST_BATCH_DELAY" usage:"pause between batches to spare the image servers"`
+	HTTPTimeout time.Duration `yaml:"http_timeout" env:"INGEST_HTTP_TIMEOUT" usage:"timeout for each upstream request"`
+}
+
+// SyncConfig configures mobile synchronization
+type SyncConfig struct {
+	FullResyncHorizon time.Duration `yaml:"full_resync_horizon" env:"SYNC_FULL_RESYNC_HORIZON" usage:"cursor age past which clients take a full copy"`
+}
+
+// BundleConfig configures the prebuilt SQLite catalogue
+type BundleConfig struct {
+	Dir           string `yaml:"dir" env:"BUNDLE_DIR" usage:"directory bundles are written to"`
+	IncludeImages bool   `yaml:"include_images" env:"BUNDLE_INCLUDE_IMAGES" usage:"embed small card images in bundles"`
+}
+
+// AuthConfig configures user sessions and API key checks
+type AuthConfig struct {
+	JWTSecret       string        `yaml:"jwt_secret" env:"JWT_SECRET" secret:"true" usage:"HS256 signing secret for access tokens"`
+	AccessTokenTTL  time.Duration `yaml:"access_token_ttl" env:"JWT_ACCESS_TTL" usage:"access token lifetime"`
+	RefreshTokenTTL time.Duration `yaml:"refresh_token_ttl" env:"JWT_REFRESH_TTL" usage:"refresh token lifetime"`
+	// RequireCatalogueKey rejects catalogue requests without an API key. It stays off
+	// until the mobile clients in use send keys, since older ones call without one.
+	RequireCatalogueKey bool `yaml:"require_catalogue_key" env:"AUTH_REQUIRE_CATALOGUE_KEY" usage:"reject catalogue requests without a sync:read API key"`
+}
+
+// RateLimitConfig configures per-client rate limits, written as <count>/<s|m|h>[:burst]
+type RateLimitConfig struct {
+	Store      string `yaml:"store" env:"RATE_LIMIT_STORE" usage:"bucket storage: memory or postgres"`
+	TrustProxy bool   `yaml:"trust_proxy" env:"RATE_LIMIT_TRUST_PROXY" usage:"identify clients by X-Forwarded-For"`
+	IP         string `yaml:"ip" env:"RATE_LIMIT_IP" usage:"limit per IP address on API routes, applied before authentication"`
+	Default    string `yaml:"default" env:"RATE_LIMIT_DEFAULT" usage:"limit for catalogue routes"`
+	Auth       string `yaml:"auth" env:"RATE_LIMIT_AUTH" usage:"limit for credential routes"`
+	FullSync   string `yaml:"full_sync" env:"RATE_LIMIT_FULL_SYNC" usage:"limit for syncs returning the whole catalogue"`
+}
+
+// CORSConfig configures cross-origin access
+type CORSConfig struct {
+	AllowedOrigins      []string      `yaml:"allowed_origins" env:"CORS_ALLOWED_ORIGINS" usage:"origins allowed on the public API"`
+	AdminAllowedOrigins []string      `yaml:"admin_allowed_origins" env:"CORS_ADMIN_ALLOWED_ORIGINS" usage:"origins allowed on admin routes"`
+	AllowCredentials    bool          `yaml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" usage:"allow credentialed cross-origin requests"`
+	MaxAge              time.Duration `yaml:"max_age" env:"CORS_MAX_AGE" usage:"how long browsers may cache preflights"`
+}
+
+// HealthConfig configures readiness thresholds
+type HealthConfig struct {
+	CheckTimeout  time.Duration `yaml:"check_timeout" env:"HEALTH_CHECK_TIMEOUT" usage:"timeout for each readiness check"`
+	IngestWarnAge time.Duration `yaml:"ingest_warn_age" env:"HEALTH_INGEST_WARN_AGE" usage:"last successful ingest age that makes readiness warn"`
+	IngestMaxAge  time.Duration `yaml:"ingest_max_age" env:"HEALTH_INGEST_MAX_AGE" usage:"last successful ingest age that makes readiness fail; 0 never fails"`
+}
+
+// Default returns the configuration used for settings that are not given
+func Default() *Config {
+	return &Config{
+		Server: ServerConfig{Port: 8080, MetricsPort: 9090},
+		Log:    LogConfig{Level: "info", Format: "json"},
+		Tracing: TracingConfig{
+			Exporter:    "none",
+			SampleRatio: 1,
+		},
+		Database: DatabaseConfig{
+			Port:            5432,
+			ApplicationName: "index-duel-backend",
+			ConnectTimeout:  10 * time.Second,
+			RetryTimeout:    time.Minute,
+			MaxOpenConns:    20,
+			MaxIdleConns:    5,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+
+			ReplicaMaxLag:        10 * time.Second,
+			ReplicaCheckInterval: 5 * time.Second,
+		},
+		Ingest: IngestConfig{
+			Source:      "http",
+			Interval:    7 * 24 * time.Hour,
+			BatchSize:   10,
+			BatchDelay:  time.Second,
+			HTTPTimeout: 30 * time.Second,
+		},
+		Sync:   SyncConfig{FullResyncHorizon: 90 * 24 * time.Hour},
+		Bundle: BundleConfig{Dir: "bundles"},
+		Auth: AuthConfig{
+			AccessTokenTTL:  15 * time.Minute,
+			RefreshTokenTTL: 30 * 24 * time.Hour,
+		},
+		RateLimit: RateLimitConfig{
+			Store:    "memory",
+			IP:       "600/m",
+			Default:  "120/m",
+			Auth:     "10/m",
+			FullSync: "4/h:2",
+		},
+		CORS: CORSConfig{
+			AllowedOrigins: []string{"*"},
+			MaxAge:         10 * time.Minute,
+		},
+		Health: HealthConfig{
+			CheckTimeout:  2 * time.Second,
+			IngestWarnAge: 8 * 24 * time.Hour,
+		},
+	}
+}
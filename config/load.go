@@ -0,0 +1,320 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"index-duel-backend/commalist"
+	"io"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// redacted replaces the value of secret settings when the configuration is printed
+const redacted = "[redacted]"
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// setting is a single leaf of Config
+type setting struct {
+	key    string // dotted yaml path, also the flag name
+	env    string
+	usage  string
+	secret string // "true" hides the value, "url" hides only a URL's password
+	value  reflect.Value
+}
+
+// settings lists every leaf of cfg in declaration order
+func settings(cfg *Config) []setting {
+	var all []setting
+	var walk func(prefix string, v reflect.Value)
+	walk = func(prefix string, v reflect.Value) {
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			key := prefix + field.Tag.Get("yaml")
+			if field.Type.Kind() == reflect.Struct && field.Type != durationType {
+				walk(key+".", v.Field(i))
+				continue
+			}
+			all = append(all, setting{
+				key:    key,
+				env:    field.Tag.Get("env"),
+				usage:  field.Tag.Get("usage"),
+				secret: field.Tag.Get("secret"),
+				value:  v.Field(i),
+			})
+		}
+	}
+	walk("", reflect.ValueOf(cfg).Elem())
+	return all
+}
+
+// Load builds the configuration from the defaults, then the YAML file named by
+// -config or CONFIG_FILE, then environment variables, then flags, and validates
+// it. Empty environment variables count as unset. It returns the arguments left
+// after the flags.
+func Load(args []string) (*Config, []string, error) {
+	cfg := Default()
+	all := settings(cfg)
+	byKey := make(map[string]setting, len(all))
+	for _, s := range all {
+		byKey[s.key] = s
+	}
+
+	// Flags are parsed first so -config is known, but applied last so they win
+	type flagValue struct{ key, value string }
+	var flagged []flagValue
+	fs := flag.NewFlagSet("index-duel-backend", flag.ContinueOnError)
+	file := fs.String("config", os.Getenv("CONFIG_FILE"), "YAML configuration file (env CONFIG_FILE)")
+	for _, s := range all {
+		key := s.key
+		usage := fmt.Sprintf("%s (env %s, default %s)", s.usage, s.env, formatDefault(s))
+		record := func(value string) error {
+			flagged = append(flagged, flagValue{key, value})
+			return nil
+		}
+		if s.value.Kind() == reflect.Bool {
+			fs.BoolFunc(key, usage, record)
+		} else {
+			fs.Func(key, usage, record)
+		}
+	}
+	if err := fs.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if *file != "" {
+		if err := loadFile(*file, byKey); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	var errs []error
+	for _, s := range all {
+		if value := os.Getenv(s.env); value != "" {
+			if err := s.set(value); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+			}
+		}
+	}
+	for _, f := range flagged {
+		if err := byKey[f.key].set(f.value); err != nil {
+			errs = append(errs, fmt.Errorf("-%s: %w", f.key, err))
+		}
+	}
+	// Report values that did not parse together with the ones that are out of range
+	errs = append(errs, cfg.problems()...)
+	if len(errs) > 0 {
+		return nil, nil, invalidConfig(errs)
+	}
+	return cfg, fs.Args(), nil
+}
+
+// loadFile applies the settings in a YAML file. Unknown keys are rejected so typos
+// do not go unnoticed.
+func loadFile(path string, byKey map[string]setting) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read config file: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := make(map[string]string)
+	flatten("", doc, values)
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs []error
+	for _, key := range keys {
+		s, ok := byKey[key]
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s: unknown setting %q", path, key))
+			continue
+		}
+		if err := s.set(values[key]); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %s: %w", path, key, err))
+		}
+	}
+	if len(errs) > 0 {
+		return invalidConfig(errs)
+	}
+	return nil
+}
+
+// invalidConfig combines configuration problems into one error listing each of them
+// on its own line
+func invalidConfig(errs []error) error {
+	var b strings.Builder
+	b.WriteString("invalid configuration:")
+	for _, err := range errs {
+		b.WriteString("\n  ")
+		b.WriteString(err.Error())
+	}
+	return errors.New(b.String())
+}
+
+// flatten turns nested YAML mappings into dotted keys with string values. Lists
+// become comma-separated values, like their environment variable form.
+func flatten(prefix string, node map[string]interface{}, out map[string]string) {
+	for key, value := range node {
+		switch v := value.(type) {
+		case map[string]interface{}:
+			flatten(prefix+key+".", v, out)
+		case []interface{}:
+			items := make([]string, len(v))
+			for i, item := range v {
+				items[i] = fmt.Sprint(item)
+			}
+			out[prefix+key] = strings.Join(items, ",")
+		case nil:
+			out[prefix+key] = ""
+		default:
+			out[prefix+key] = fmt.Sprint(v)
+		}
+	}
+}
+
+// set parses value into the setting according to its type
+func (s setting) set(value string) error {
+	value = strings.TrimSpace(value)
+	switch {
+	case s.value.Type() == durationType:
+		d, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("invalid duration %q", value)
+		}
+		s.value.SetInt(int64(d))
+	case s.value.Kind() == reflect.String:
+		s.value.SetString(value)
+	case s.value.Kind() == reflect.Bool:
+		b, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", value)
+		}
+		s.value.SetBool(b)
+	case s.value.Kind() == reflect.Int:
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return fmt.Errorf("invalid integer %q", value)
+		}
+		s.value.SetInt(int64(n))
+	case s.value.Kind() == reflect.Float64:
+		f, err := strconv.ParseFloat(value, 64)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", value)
+		}
+		s.value.SetFloat(f)
+	case s.value.Kind() == reflect.Slice && s.value.Type().Elem().Kind() == reflect.String:
+		s.value.Set(reflect.ValueOf(commalist.Split(value)))
+	default:
+		return fmt.Errorf("unsupported setting type %s", s.value.Type())
+	}
+	return nil
+}
+
+// display returns the setting's value as it should be shown, hiding secrets
+func (s setting) display() interface{} {
+	switch {
+	case s.secret != "" && s.value.IsZero():
+		return ""
+	case s.secret == "url" && s.value.Kind() == reflect.Slice:
+		urls := make([]string, s.value.Len())
+		for i := range urls {
+			urls[i] = redactURL(s.value.Index(i).String())
+		}
+		return urls
+	case s.secret == "url":
+		return redactURL(s.value.String())
+	case s.secret != "":
+		return redacted
+	}
+	if s.value.Type() == durationType {
+		return time.Duration(s.value.Int()).String()
+	}
+	if s.value.Kind() == reflect.Slice && s.value.IsNil() {
+		return []string{}
+	}
+	return s.value.Interface()
+}
+
+// redactURL masks the password of a connection URL, hiding all of it when it does
+// not parse
+func redactURL(raw string) string {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return redacted
+	}
+	return u.Redacted()
+}
+
+// formatDefault describes a setting's default value for flag usage
+func formatDefault(s setting) string {
+	value := s.display()
+	if list, ok := value.([]string); ok {
+		value = strings.Join(list, ",")
+	}
+	if str := fmt.Sprint(value); str != "" {
+		return str
+	}
+	return "none"
+}
+
+// Redacted returns the configuration as nested maps keyed like the YAML file, with
+// secrets replaced, for logging
+func (c *Config) Redacted() map[string]interface{} {
+	out := make(map[string]interface{})
+	for _, s := range settings(c) {
+		section, key, _ := strings.Cut(s.key, ".")
+		values, ok := out[section].(map[string]interface{})
+		if !ok {
+			values = make(map[string]interface{})
+			out[section] = values
+		}
+		values[key] = s.display()
+	}
+	return out
+}
+
+// Print writes the configuration to w as YAML in declaration order, with secrets
+// replaced. The output can be used as a configuration file once secrets are filled in.
+func (c *Config) Print(w io.Writer) error {
+	root := &yaml.Node{Kind: yaml.MappingNode}
+	sections := make(map[string]*yaml.Node)
+	for _, s := range settings(c) {
+		section, key, _ := strings.Cut(s.key, ".")
+		values, ok := sections[section]
+		if !ok {
+			values = &yaml.Node{Kind: yaml.MappingNode}
+			sections[section] = values
+			root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: section}, values)
+		}
+
+		value := &yaml.Node{}
+		if err := value.Encode(s.display()); err != nil {
+			return fmt.Errorf("failed to encode %s: %w", s.key, err)
+		}
+		values.Content = append(values.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: key}, value)
+	}
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(root); err != nil {
+		return fmt.Errorf("failed to print configuration: %w", err)
+	}
+	return enc.Close()
+}
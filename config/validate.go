@@ -0,0 +1,169 @@
+package config
+
+import (
+	"fmt"
+	"index-duel-backend/ratelimit"
+	"net/url"
+	"os"
+)
+
+// MinJWTSecretLength is the shortest HS256 signing secret accepted
+const MinJWTSecretLength = 32
+
+// validator collects problems, naming each setting by its environment variable and
+// yaml key
+type validator struct {
+	envs map[string]string
+	errs []error
+}
+
+// check records a problem with key unless ok holds
+func (v *validator) check(ok bool, key, format string, args ...interface{}) {
+	if !ok {
+		v.errs = append(v.errs, fmt.Errorf("%s (%s) %s", v.envs[key], key, fmt.Sprintf(format, args...)))
+	}
+}
+
+// oneOf records a problem with key unless value is one of allowed
+func (v *validator) oneOf(value, key string, allowed ...string) {
+	for _, a := range allowed {
+		if value == a {
+			return
+		}
+	}
+	v.check(false, key, "is %q, expected one of %v", value, allowed)
+}
+
+// rateLimit records a problem with key unless value is a valid rate limit
+func (v *validator) rateLimit(value, key string) {
+	_, err := ratelimit.ParseLimit(value)
+	v.check(err == nil, key, "%v", err)
+}
+
+// postgresURL records a problem with key unless value is a Postgres connection URL
+func (v *validator) postgresURL(value, key string) {
+	u, err := url.Parse(value)
+	v.check(err == nil && (u.Scheme == "postgres" || u.Scheme == "postgresql"),
+		key, "must be a postgres:// or postgresql:// URL")
+}
+
+// file records a problem with key when path is set but cannot be read
+func (v *validator) file(path, key string) {
+	if path == "" {
+		return
+	}
+	_, err := os.Stat(path)
+	v.check(err == nil, key, "%v", err)
+}
+
+// Validate checks every setting and reports all problems at once
+func (c *Config) Validate() error {
+	if errs := c.problems(); len(errs) > 0 {
+		return invalidConfig(errs)
+	}
+	return nil
+}
+
+// ValidateServer checks the settings only serving needs, which commands such as
+// apikey and config do without
+func (c *Config) ValidateServer() error {
+	v := c.validator()
+	v.check(len(c.Auth.JWTSecret) >= MinJWTSecretLength, "auth.jwt_secret", "must be at least %d characters", MinJWTSecretLength)
+	if len(v.errs) > 0 {
+		return invalidConfig(v.errs)
+	}
+	return nil
+}
+
+// validator returns a validator naming the settings of c
+func (c *Config) validator() *validator {
+	v := &validator{envs: make(map[string]string)}
+	for _, s := range settings(c) {
+		v.envs[s.key] = s.env
+	}
+	return v
+}
+
+// problems returns one error for every setting that is missing or out of range
+func (c *Config) problems() []error {
+	v := c.validator()
+
+	v.check(c.Server.Port > 0 && c.Server.Port <= 65535, "server.port", "must be between 1 and 65535")
+	v.check(c.Server.MetricsPort >= 0 && c.Server.MetricsPort <= 65535, "server.metrics_port", "must be between 0 and 65535")
+	v.check(c.Server.MetricsPort != c.Server.Port, "server.metrics_port", "must differ from server.port")
+
+	v.oneOf(c.Log.Level, "log.level", "debug", "info", "warn", "error")
+	v.oneOf(c.Log.Format, "log.format", "json", "text")
+
+	v.oneOf(c.Tracing.Exporter, "tracing.exporter", "none", "otlp", "stdout")
+	v.check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+
+	if c.Database.URL != "" {
+		v.postgresURL(c.Database.URL, "database.url")
+	} else {
+		v.check(c.Database.Host != "", "database.host", "is required")
+		v.check(c.Database.Port > 0 && c.Database.Port <= 65535, "database.port", "must be between 1 and 65535")
+		v.check(c.Database.Name != "", "database.name", "is required")
+		v.check(c.Database.User != "", "database.user", "is required")
+	}
+	if c.Database.SSLMode != "" {
+		v.oneOf(c.Database.SSLMode, "database.sslmode", "disable", "require", "verify-ca", "verify-full")
+	}
+	v.file(c.Database.SSLRootCert, "database.sslrootcert")
+	v.file(c.Database.SSLCert, "database.sslcert")
+	v.file(c.Database.SSLKey, "database.sslkey")
+	v.check((c.Database.SSLCert == "") == (c.Database.SSLKey == ""), "database.sslkey", "must be set together with the client certificate")
+	v.check(c.Database.ConnectTimeout >= 0, "database.connect_timeout", "must not be negative")
+	v.check(c.Database.StatementTimeout >= 0, "database.statement_timeout", "must not be negative")
+	v.check(c.Database.RetryTimeout >= 0, "database.retry_timeout", "must not be negative")
+	v.check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	v.check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	v.check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns", "must not exceed the maximum open connections")
+	v.check(c.Database.ConnMaxLifetime >= 0, "database.conn_max_lifetime", "must not be negative")
+	v.check(c.Database.ConnMaxIdleTime >= 0, "database.conn_max_idle_time", "must not be negative")
+	for _, replica := range c.Database.ReplicaURLs {
+		v.postgresURL(replica, "database.replica_urls")
+	}
+	if len(c.Database.ReplicaURLs) > 0 {
+		v.check(c.Database.ReplicaMaxLag > 0, "database.replica_max_lag", "must be positive")
+		v.check(c.Database.ReplicaCheckInterval > 0, "database.replica_check_interval", "must be positive")
+	}
+
+	v.oneOf(c.Ingest.Source, "ingest.source", "http", "file", "dir")
+	if c.Ingest.Source == "file" || c.Ingest.Source == "dir" {
+		v.check(c.Ingest.Path != "", "ingest.path", "is required for the %s source", c.Ingest.Source)
+		v.file(c.Ingest.Path, "ingest.path")
+	}
+	if c.Ingest.APIURL != "" {
+		u, err := url.Parse(c.Ingest.APIURL)
+		v.check(err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "",
+			"ingest.api_url", "must be an absolute http or https URL")
+	}
+	v.check(c.Ingest.Interval > 0, "ingest.interval", "must be positive")
+	v.check(c.Ingest.BatchSize > 0, "ingest.batch_size", "must be positive")
+	v.check(c.Ingest.BatchDelay >= 0, "ingest.batch_delay", "must not be negative")
+	v.check(c.Ingest.HTTPTimeout > 0, "ingest.http_timeout", "must be positive")
+
+	v.check(c.Sync.FullResyncHorizon > 0, "sync.full_resync_horizon", "must be positive")
+
+	v.check(c.Bundle.Dir != "", "bundle.dir", "is required")
+
+	v.check(c.Auth.AccessTokenTTL > 0, "auth.access_token_ttl", "must be positive")
+	v.check(c.Auth.RefreshTokenTTL > c.Auth.AccessTokenTTL, "auth.refresh_token_ttl", "must be longer than the access token lifetime")
+
+	v.oneOf(c.RateLimit.Store, "rate_limit.store", "memory", "postgres")
+	v.rateLimit(c.RateLimit.IP, "rate_limit.ip")
+	v.rateLimit(c.RateLimit.Default, "rate_limit.default")
+	v.rateLimit(c.RateLimit.Auth, "rate_limit.auth")
+	v.rateLimit(c.RateLimit.FullSync, "rate_limit.full_sync")
+
+	v.check(c.CORS.MaxAge >= 0, "cors.max_age", "must not be negative")
+
+	v.check(c.Health.CheckTimeout > 0, "health.check_timeout", "must be positive")
+	v.check(c.Health.IngestWarnAge > 0, "health.ingest_warn_age", "must be positive")
+	v.check(c.Health.IngestMaxAge == 0 || c.Health.IngestMaxAge >= c.Health.IngestWarnAge,
+		"health.ingest_max_age", "must be 0 or at least the warning age")
+
+	return v.errs
+}
@@ -1,43 +1,263 @@
 package database
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
-	"os"
+	"log/slog"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
 
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
+// Connection retry backoff bounds
+const (
+	initialRetryDelay = 500 * time.Millisecond
+	maxRetryDelay     = 10 * time.Second
+)
+
+// DB is the primary connection pool, which takes all writes, along with any read
+// replicas. The zero value of every replica field means reads use the primary.
 type DB struct {
 	*sql.DB
+
+	replicas []*Replica
+	maxLag   time.Duration
+	next     atomic.Uint32
+	stop     chan struct{}
+	done     chan struct{}
+}
+
+// Config holds the Postgres connection and pool settings. When URL is set it
+// provides the server, database and credentials, and Host, Port, Name, User and
+// Password are ignored; the remaining settings apply either way.
+type Config struct {
+	URL      string
+	Host     string
+	Port     int
+	Name     string
+	User     string
+	Password string
+
+	// SSLMode is disable, require, verify-ca or verify-full. Empty keeps the mode
+	// given in URL, or disable when connecting with Host.
+	SSLMode string
+	// SSLRootCert is the CA file server certificates are verified against
+	SSLRootCert string
+	// SSLCert and SSLKey are an optional client certificate and key
+	SSLCert string
+	SSLKey  string
+
+	ApplicationName string
+	// ConnectTimeout bounds establishing each connection; zero waits indefinitely
+	ConnectTimeout time.Duration
+	// StatementTimeout makes the server cancel statements running longer; zero disables it
+	StatementTimeout time.Duration
+
+	MaxOpenConns    int
+	MaxIdleConns    int
+	ConnMaxLifetime time.Duration
+	ConnMaxIdleTime time.Duration
+
+	// RetryTimeout is how long NewDB keeps retrying an unreachable server before
+	// giving up; zero tries once
+	RetryTimeout time.Duration
+
+	// ReplicaURLs are connection URLs of read replicas, which share every other setting
+	ReplicaURLs []string
+	// ReplicaMaxLag is how far behind a replica may fall before reads go to the primary
+	ReplicaMaxLag time.Duration
+	// ReplicaCheckInterval is how often replica lag is measured
+	ReplicaCheckInterval time.Duration
+}
+
+// DSN returns the lib/pq connection string for cfg, quoting every value so
+// passwords with spaces, quotes or backslashes survive
+func (cfg Config) DSN() (string, error) {
+	settings := make(map[string]string)
+	if cfg.URL != "" {
+		parsed, err := pq.ParseURL(cfg.URL)
+		if err != nil {
+			return "", fmt.Errorf("invalid database URL: %w", err)
+		}
+		if err := parseDSN(parsed, settings); err != nil {
+			return "", err
+		}
+	} else {
+		settings["host"] = cfg.Host
+		if cfg.Port != 0 {
+			settings["port"] = strconv.Itoa(cfg.Port)
+		}
+		settings["dbname"] = cfg.Name
+		settings["user"] = cfg.User
+		if cfg.Password != "" {
+			settings["password"] = cfg.Password
+		}
+		settings["sslmode"] = "disable"
+	}
+
+	set := func(key, value string) {
+		if value != "" {
+			settings[key] = value
+		}
+	}
+	set("sslmode", cfg.SSLMode)
+	set("sslrootcert", cfg.SSLRootCert)
+	set("sslcert", cfg.SSLCert)
+	set("sslkey", cfg.SSLKey)
+	set("application_name", cfg.ApplicationName)
+	if cfg.ConnectTimeout > 0 {
+		// lib/pq takes whole seconds; round up so short timeouts are not disabled
+		settings["connect_timeout"] = strconv.Itoa(int((cfg.ConnectTimeout + time.Second - 1) / time.Second))
+	}
+	if cfg.StatementTimeout > 0 {
+		settings["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+
+	keys := make([]string, 0, len(settings))
+	for key := range settings {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	parts := make([]string, len(keys))
+	for i, key := range keys {
+		parts[i] = key + "=" + quoteDSNValue(settings[key])
+	}
+	return strings.Join(parts, " "), nil
 }
 
-func NewDB() (*DB, error) {
-	host := os.Getenv("PG_HOST")
-	port := os.Getenv("PG_PORT")
-	dbname := os.Getenv("PG_DATABASE")
-	user := os.Getenv("PG_USER")
-	password := os.Getenv("PG_PASSWORD")
+// parseDSN reads the key='value' pairs produced by pq.ParseURL into settings
+func parseDSN(dsn string, settings map[string]string) error {
+	for rest := strings.TrimSpace(dsn); rest != ""; rest = strings.TrimSpace(rest) {
+		key, after, ok := strings.Cut(rest, "=")
+		if !ok {
+			return fmt.Errorf("invalid database URL: malformed setting %q", rest)
+		}
 
-	if host == "" || port == "" || dbname == "" || user == "" || password == "" {
-		return nil, fmt.Errorf("missing required database environment variables")
+		var value strings.Builder
+		if !strings.HasPrefix(after, "'") {
+			end := strings.IndexByte(after, ' ')
+			if end < 0 {
+				end = len(after)
+			}
+			value.WriteString(after[:end])
+			rest = after[end:]
+		} else {
+			i := 1
+			for ; i < len(after) && after[i] != '\''; i++ {
+				if after[i] == '\\' && i+1 < len(after) {
+					i++
+				}
+				value.WriteByte(after[i])
+			}
+			if i >= len(after) {
+				return fmt.Errorf("invalid database URL: unterminated value for %s", key)
+			}
+			rest = after[i+1:]
+		}
+		settings[strings.TrimSpace(key)] = value.String()
 	}
+	return nil
+}
+
+// quoteDSNValue quotes a connection string value, escaping quotes and backslashes
+func quoteDSNValue(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `'`, `\'`)
+	return "'" + value + "'"
+}
 
-	psqlInfo := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
-		host, port, user, password, dbname)
+// NewDB opens a connection pool configured by cfg and waits for the server to
+// answer, retrying with exponential backoff for up to cfg.RetryTimeout
+func NewDB(ctx context.Context, cfg Config) (*DB, error) {
+	if cfg.URL == "" && (cfg.Host == "" || cfg.Name == "" || cfg.User == "") {
+		return nil, fmt.Errorf("missing required database settings")
+	}
 
-	db, err := sql.Open("postgres", psqlInfo)
+	dsn, err := cfg.DSN()
+	if err != nil {
+		return nil, err
+	}
+
+	db, err := sql.Open("postgres", dsn)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db.SetMaxOpenConns(cfg.MaxOpenConns)
+	db.SetMaxIdleConns(cfg.MaxIdleConns)
+	db.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	db.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 
-	if err := db.Ping(); err != nil {
-		return nil, fmt.Errorf("failed to ping database: %w", err)
+	if err := pingWithRetry(ctx, db, cfg.RetryTimeout); err != nil {
+		db.Close()
+		return nil, err
 	}
 
-	return &DB{db}, nil
+	primary := &DB{DB: db}
+	if err := primary.openReplicas(ctx, cfg); err != nil {
+		primary.Close()
+		return nil, err
+	}
+	return primary, nil
 }
 
+// pingWithRetry pings db until it answers, doubling the delay between attempts up
+// to maxRetryDelay, and gives up once the next attempt would start after timeout
+func pingWithRetry(ctx context.Context, db *sql.DB, timeout time.Duration) error {
+	deadline := time.Now().Add(timeout)
+	delay := initialRetryDelay
+
+	for attempt := 1; ; attempt++ {
+		err := db.PingContext(ctx)
+		if err == nil {
+			return nil
+		}
+		if time.Now().Add(delay).After(deadline) {
+			return fmt.Errorf("failed to ping database after %d attempts: %w", attempt, err)
+		}
+
+		slog.WarnContext(ctx, "database not reachable, retrying", "attempt", attempt, "retry_in", delay, "error", err)
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return fmt.Errorf("failed to ping database: %w", ctx.Err())
+		}
+		delay = min(delay*2, maxRetryDelay)
+	}
+}
+
+// Close stops the replica lag checks and closes every pool
 func (db *DB) Close() error {
+	if db.stop != nil {
+		close(db.stop)
+		<-db.done
+	}
+	for _, r := range db.replicas {
+		r.Close()
+	}
 	return db.DB.Close()
 }
+
+// MissingTables returns the tables among names that do not exist in the search path
+func (db *DB) MissingTables(ctx context.Context, names []string) ([]string, error) {
+	rows, err := db.QueryContext(ctx, "SELECT name FROM unnest($1::text[]) AS name WHERE to_regclass(name) IS NULL", pq.Array(names))
+	if err != nil {
+		return nil, fmt.Errorf("failed to look up tables: %w", err)
+	}
+	defer rows.Close()
+
+	var missing []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan table name: %w", err)
+		}
+		missing = append(missing, name)
+	}
+	return missing, rows.Err()
+}
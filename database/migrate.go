@@ -0,0 +1,83 @@
+package database
+
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"sort"
+	"strings"
+)
+
+//go:embed migrations/*.sql
+var migrationFiles embed.FS
+
+// migrationLockID is the advisory lock key that serialises concurrent migrators
+const migrationLockID = 7291043
+
+// Migrate applies any pending schema migrations in filename order
+func (db *DB) Migrate() error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			version    TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+		)
+	`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations table: %w", err)
+	}
+
+	names, err := fs.Glob(migrationFiles, "migrations/*.sql")
+	if err != nil {
+		return fmt.Errorf("failed to list migrations: %w", err)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		version := strings.TrimSuffix(strings.TrimPrefix(name, "migrations/"), ".sql")
+		applied, err := db.applyMigration(version, name)
+		if err != nil {
+			return fmt.Errorf("failed to apply migration %s: %w", version, err)
+		}
+		if applied {
+			slog.Info("applied database migration", "version", version)
+		}
+	}
+
+	return nil
+}
+
+// applyMigration runs a single migration file unless it has already been recorded
+func (db *DB) applyMigration(version, name string) (bool, error) {
+	script, err := migrationFiles.ReadFile(name)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", migrationLockID); err != nil {
+		return false, fmt.Errorf("failed to acquire migration lock: %w", err)
+	}
+
+	var exists bool
+	err = tx.QueryRow("SELECT EXISTS (SELECT 1 FROM schema_migrations WHERE version = $1)", version).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("failed to check migration state: %w", err)
+	}
+	if exists {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec("INSERT INTO schema_migrations (version) VALUES ($1)", version); err != nil {
+		return false, fmt.Errorf("failed to record migration: %w", err)
+	}
+
+	return true, tx.Commit()
+}
@@ -0,0 +1,59 @@
+CREATE TABLE IF NOT EXISTS cards (
+    id          BIGINT PRIMARY KEY,
+    name        TEXT NOT NULL,
+    type        TEXT NOT NULL DEFAULT '',
+    frame_type  TEXT NOT NULL DEFAULT '',
+    description TEXT NOT NULL DEFAULT '',
+    atk         INTEGER,
+    def         INTEGER,
+    level       INTEGER,
+    race        TEXT NOT NULL DEFAULT '',
+    attribute   TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at  TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_cards_updated_at ON cards (updated_at);
+
+CREATE TABLE IF NOT EXISTS card_sets (
+    id              SERIAL PRIMARY KEY,
+    card_id         BIGINT NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    set_name        TEXT NOT NULL DEFAULT '',
+    set_code        TEXT NOT NULL DEFAULT '',
+    set_rarity      TEXT NOT NULL DEFAULT '',
+    set_rarity_code TEXT NOT NULL DEFAULT '',
+    set_price       TEXT,
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_sets_card_id ON card_sets (card_id);
+
+CREATE TABLE IF NOT EXISTS card_images (
+    id                 SERIAL PRIMARY KEY,
+    card_id            BIGINT NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    image_url          TEXT NOT NULL DEFAULT '',
+    image_url_small    TEXT NOT NULL DEFAULT '',
+    image_url_cropped  TEXT NOT NULL DEFAULT '',
+    image_data         BYTEA,
+    image_small_data   BYTEA,
+    image_cropped_data BYTEA,
+    content_type       TEXT NOT NULL DEFAULT '',
+    file_size          INTEGER,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_images_card_id ON card_images (card_id);
+
+CREATE TABLE IF NOT EXISTS card_prices (
+    id                 SERIAL PRIMARY KEY,
+    card_id            BIGINT NOT NULL REFERENCES cards (id) ON DELETE CASCADE,
+    cardmarket_price   TEXT,
+    tcgplayer_price    TEXT,
+    ebay_price         TEXT,
+    amazon_price       TEXT,
+    coolstuffinc_price TEXT,
+    created_at         TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX IF NOT EXISTS idx_card_prices_card_id ON card_prices (card_id);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS ingest_checkpoints (
+    source           TEXT PRIMARY KEY,
+    upstream_version TEXT NOT NULL,
+    last_index       INTEGER NOT NULL,
+    last_card_id     BIGINT NOT NULL,
+    completed        BOOLEAN NOT NULL DEFAULT FALSE,
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
@@ -0,0 +1,8 @@
+-- Every write to a card, including replacing its sets, images and prices, stamps
+-- the card with the next value of this sequence. Existing rows are backfilled by
+-- the column default.
+CREATE SEQUENCE IF NOT EXISTS card_change_seq;
+
+ALTER TABLE cards ADD COLUMN IF NOT EXISTS change_seq BIGINT NOT NULL DEFAULT nextval('card_change_seq');
+
+CREATE INDEX IF NOT EXISTS idx_cards_change_seq ON cards (change_seq);
@@ -0,0 +1,12 @@
+-- Client API keys. Only a SHA-256 hash of each key is stored; the prefix is kept
+-- in clear so keys can be told apart in listings and logs.
+CREATE TABLE IF NOT EXISTS api_keys (
+    id           BIGSERIAL PRIMARY KEY,
+    name         TEXT NOT NULL,
+    prefix       TEXT NOT NULL UNIQUE,
+    key_hash     BYTEA NOT NULL UNIQUE,
+    scopes       TEXT[] NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    last_used_at TIMESTAMPTZ,
+    revoked_at   TIMESTAMPTZ
+);
@@ -0,0 +1,10 @@
+-- Token buckets for the Postgres rate limit store. full_at is when a bucket will
+-- have refilled completely; rows past it carry no state and are deleted.
+CREATE TABLE IF NOT EXISTS rate_limit_buckets (
+    key        TEXT PRIMARY KEY,
+    tokens     DOUBLE PRECISION NOT NULL,
+    updated_at TIMESTAMPTZ NOT NULL,
+    full_at    TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX IF NOT EXISTS idx_rate_limit_buckets_full_at ON rate_limit_buckets (full_at);
@@ -0,0 +1,17 @@
+-- One row per ingest run, so readiness checks can report how old the catalogue is
+-- and whether the last attempt to refresh it failed. Runs interrupted by a restart
+-- stay in the running state.
+CREATE TABLE IF NOT EXISTS ingest_runs (
+    id              BIGSERIAL PRIMARY KEY,
+    run_id          TEXT NOT NULL UNIQUE,
+    source          TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'running',
+    started_at      TIMESTAMPTZ NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    finished_at     TIMESTAMPTZ,
+    cards_processed INTEGER NOT NULL DEFAULT 0,
+    cards_failed    INTEGER NOT NULL DEFAULT 0,
+    error           TEXT
+);
+
+CREATE INDEX IF NOT EXISTS idx_ingest_runs_started_at ON ingest_runs (started_at DESC);
+CREATE INDEX IF NOT EXISTS idx_ingest_runs_succeeded ON ingest_runs (finished_at DESC) WHERE status = 'succeeded';
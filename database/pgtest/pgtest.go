@@ -0,0 +1,323 @@
+// Package pgtest runs a disposable PostgreSQL server for integration tests. The
+// server is started from locally installed binaries in a temporary directory,
+// tuned for speed over durability, and removed when the tests finish. Each test
+// gets its own database, cloned from a migrated template.
+//
+// A test package using it runs its tests through Run:
+//
+//	func TestMain(m *testing.M) {
+//		os.Exit(pgtest.Run(m))
+//	}
+//
+// The tests fail when no server can be started, for example because PostgreSQL is
+// not installed or the tests run as root. Set PGTEST_SKIP=1 to skip them instead.
+package pgtest
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"index-duel-backend/database"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	_ "github.com/lib/pq"
+)
+
+// ErrUnavailable is returned by Start when no server can be run on this machine
+var ErrUnavailable = errors.New("postgres is unavailable")
+
+// templateName is the database every test database is cloned from
+const templateName = "pgtest_template"
+
+// startAttempts bounds retries when another process takes the chosen port first
+const startAttempts = 3
+
+// Server is a running disposable PostgreSQL server
+type Server struct {
+	bin   string
+	dir   string
+	port  int
+	admin *sql.DB
+
+	mu       sync.Mutex
+	template bool
+	next     int
+}
+
+// Start initialises and starts a server in a new temporary directory. Binaries are
+// taken from PG_BIN, then PATH, then the usual install locations. Stop the server
+// when done.
+func Start() (*Server, error) {
+	bin, err := findBinaries()
+	if err != nil {
+		return nil, err
+	}
+	if os.Geteuid() == 0 {
+		return nil, fmt.Errorf("%w: postgres refuses to run as root", ErrUnavailable)
+	}
+
+	dir, err := os.MkdirTemp("", "pgtest-")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create server directory: %w", err)
+	}
+	s := &Server{bin: bin, dir: dir}
+
+	if err := s.run("initdb", "-D", s.dataDir(), "-U", "postgres", "-A", "trust",
+		"-E", "UTF8", "--no-locale", "--no-sync"); err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	for attempt := 1; ; attempt++ {
+		if err = s.start(); err == nil || attempt == startAttempts {
+			break
+		}
+	}
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+
+	s.admin, err = sql.Open("postgres", s.URL("postgres"))
+	if err == nil {
+		err = s.admin.Ping()
+	}
+	if err != nil {
+		s.Stop()
+		return nil, fmt.Errorf("failed to connect to postgres: %w", err)
+	}
+	return s, nil
+}
+
+// start launches the server on a free port and waits for it to accept connections
+func (s *Server) start() error {
+	port, err := freePort()
+	if err != nil {
+		return err
+	}
+	s.port = port
+
+	// Durability is pointless for a server that is thrown away, and unix sockets
+	// are disabled because temporary paths can exceed their length limit
+	options := fmt.Sprintf("-p %d -h 127.0.0.1 -k '' -F -c synchronous_commit=off -c full_page_writes=off", port)
+	return s.run("pg_ctl", "start", "-w", "-D", s.dataDir(), "-l", s.logFile(), "-o", options)
+}
+
+// Stop shuts the server down and removes its directory
+func (s *Server) Stop() error {
+	if s.admin != nil {
+		s.admin.Close()
+	}
+	err := s.run("pg_ctl", "stop", "-w", "-D", s.dataDir(), "-m", "immediate")
+	if rmErr := os.RemoveAll(s.dir); err == nil {
+		err = rmErr
+	}
+	return err
+}
+
+// URL returns the connection URL of the named database
+func (s *Server) URL(name string) string {
+	return fmt.Sprintf("postgres://postgres@127.0.0.1:%d/%s?sslmode=disable", s.port, name)
+}
+
+// NewDB creates an empty, migrated database for tb and connects to it. The
+// connection is closed when tb finishes.
+func (s *Server) NewDB(tb testing.TB) *database.DB {
+	tb.Helper()
+
+	name, err := s.createDatabase()
+	if err != nil {
+		tb.Fatalf("failed to create test database: %v", err)
+	}
+
+	db, err := database.NewDB(context.Background(), database.Config{
+		URL:             s.URL(name),
+		ApplicationName: "pgtest",
+		MaxOpenConns:    20,
+		MaxIdleConns:    5,
+		RetryTimeout:    5 * time.Second,
+	})
+	if err != nil {
+		tb.Fatalf("failed to connect to test database: %v", err)
+	}
+	tb.Cleanup(func() { db.Close() })
+	return db
+}
+
+// createDatabase clones a new database from the template, migrating the template
+// the first time it is needed
+func (s *Server) createDatabase() (string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.template {
+		if err := s.createTemplate(); err != nil {
+			return "", err
+		}
+		s.template = true
+	}
+
+	s.next++
+	name := fmt.Sprintf("test_%d", s.next)
+	if _, err := s.admin.Exec(fmt.Sprintf("CREATE DATABASE %s TEMPLATE %s", name, templateName)); err != nil {
+		return "", fmt.Errorf("failed to create database %s: %w", name, err)
+	}
+	return name, nil
+}
+
+// createTemplate creates and migrates the template database. Its connection is
+// closed before returning, since a template cannot be cloned while in use.
+func (s *Server) createTemplate() error {
+	if _, err := s.admin.Exec("CREATE DATABASE " + templateName); err != nil {
+		return fmt.Errorf("failed to create template database: %w", err)
+	}
+
+	sqlDB, err := sql.Open("postgres", s.URL(templateName))
+	if err != nil {
+		return fmt.Errorf("failed to open template database: %w", err)
+	}
+	defer sqlDB.Close()
+
+	if err := (&database.DB{DB: sqlDB}).Migrate(); err != nil {
+		return fmt.Errorf("failed to migrate template database: %w", err)
+	}
+	return nil
+}
+
+func (s *Server) dataDir() string {
+	return filepath.Join(s.dir, "data")
+}
+
+func (s *Server) logFile() string {
+	return filepath.Join(s.dir, "postgres.log")
+}
+
+// run executes one of the server binaries, reporting its output and the server log
+// when it fails
+func (s *Server) run(name string, args ...string) error {
+	out, err := exec.Command(filepath.Join(s.bin, name), args...).CombinedOutput()
+	if err != nil {
+		serverLog, _ := os.ReadFile(s.logFile())
+		return fmt.Errorf("failed to run %s: %w\n%s%s", name, err, out, serverLog)
+	}
+	return nil
+}
+
+// installDirs are searched for binaries after PG_BIN and PATH
+var installDirs = []string{
+	"/usr/lib/postgresql/*/bin",
+	"/usr/pgsql-*/bin",
+	"/usr/local/pgsql/bin",
+	"/opt/homebrew/opt/postgresql@*/bin",
+	"/usr/local/opt/postgresql@*/bin",
+}
+
+// findBinaries returns the directory holding initdb and pg_ctl, preferring the
+// newest major version among the install locations
+func findBinaries() (string, error) {
+	if dir := os.Getenv("PG_BIN"); dir != "" {
+		if !hasBinaries(dir) {
+			return "", fmt.Errorf("%w: PG_BIN %s does not contain initdb and pg_ctl", ErrUnavailable, dir)
+		}
+		return dir, nil
+	}
+
+	if path, err := exec.LookPath("pg_ctl"); err == nil && hasBinaries(filepath.Dir(path)) {
+		return filepath.Dir(path), nil
+	}
+
+	var candidates []string
+	for _, pattern := range installDirs {
+		matches, _ := filepath.Glob(pattern)
+		candidates = append(candidates, matches...)
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return majorVersion(candidates[i]) > majorVersion(candidates[j])
+	})
+	for _, dir := range candidates {
+		if hasBinaries(dir) {
+			return dir, nil
+		}
+	}
+	return "", fmt.Errorf("%w: initdb and pg_ctl not found; install PostgreSQL or set PG_BIN", ErrUnavailable)
+}
+
+func hasBinaries(dir string) bool {
+	for _, name := range []string{"initdb", "pg_ctl"} {
+		if info, err := os.Stat(filepath.Join(dir, name)); err != nil || info.IsDir() {
+			return false
+		}
+	}
+	return true
+}
+
+// majorVersion extracts the first number in an install path, so that
+// /usr/lib/postgresql/16/bin sorts above /usr/lib/postgresql/9.6/bin
+func majorVersion(dir string) int {
+	digits := strings.FieldsFunc(dir, func(r rune) bool { return r < '0' || r > '9' })
+	if len(digits) == 0 {
+		return 0
+	}
+	version, _ := strconv.Atoi(digits[0])
+	return version
+}
+
+// freePort asks the kernel for a free TCP port on the loopback interface
+func freePort() (int, error) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return 0, fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer listener.Close()
+	return listener.Addr().(*net.TCPAddr).Port, nil
+}
+
+var (
+	shared    *Server
+	sharedErr error
+)
+
+// skipEnv names the environment variable that lets tests skip, rather than fail,
+// when no server can be started
+const skipEnv = "PGTEST_SKIP"
+
+// Run starts the shared server, runs the tests and stops the server, returning the
+// exit code for os.Exit. When no server can be started the tests fail, unless
+// PGTEST_SKIP is set, in which case tests asking for a database are skipped.
+func Run(m *testing.M) int {
+	shared, sharedErr = Start()
+	if sharedErr != nil && os.Getenv(skipEnv) == "" {
+		fmt.Fprintf(os.Stderr, "pgtest: failed to start postgres: %v (set %s=1 to skip)\n", sharedErr, skipEnv)
+		return 1
+	}
+	if sharedErr == nil {
+		defer shared.Stop()
+	}
+	return m.Run()
+}
+
+// NewDB returns an empty, migrated database on the shared server started by Run,
+// skipping tb when the server is unavailable and PGTEST_SKIP is set
+func NewDB(tb testing.TB) *database.DB {
+	tb.Helper()
+	if sharedErr != nil {
+		if errors.Is(sharedErr, ErrUnavailable) && os.Getenv(skipEnv) != "" {
+			tb.Skip(sharedErr)
+		}
+		tb.Fatalf("failed to start postgres: %v", sharedErr)
+	}
+	if shared == nil {
+		tb.Fatal("pgtest.NewDB called without pgtest.Run in TestMain")
+	}
+	return shared.NewDB(tb)
+}
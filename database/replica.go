@@ -0,0 +1,192 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"index-duel-backend/metrics"
+	"log/slog"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// replicaLagQuery reports whether a standby is receiving WAL from the primary and
+// how far its replay is behind. A standby that has replayed everything it received
+// counts as caught up, since the last replay timestamp only advances when the
+// primary writes; that only holds while its WAL receiver is running, so a standby
+// without one is reported as not receiving. Roles without pg_read_all_stats see the
+// receiver's pid but not its status, so a receiver they can see counts as streaming.
+const replicaLagQuery = `
+	SELECT
+		NOT pg_is_in_recovery() OR EXISTS (
+			SELECT 1 FROM pg_stat_wal_receiver WHERE status IS NULL OR status = 'streaming'
+		),
+		CASE
+			WHEN NOT pg_is_in_recovery() THEN 0
+			WHEN pg_last_wal_receive_lsn() = pg_last_wal_replay_lsn() THEN 0
+			ELSE COALESCE(EXTRACT(EPOCH FROM now() - pg_last_xact_replay_timestamp()), 0)
+		END
+`
+
+// Replica is a read-only standby pool whose replication lag is checked in the
+// background
+type Replica struct {
+	Name string
+	*sql.DB
+
+	usable atomic.Bool
+	mu     sync.Mutex
+	status ReplicaStatus
+}
+
+// ReplicaStatus is the outcome of a replica's most recent lag check
+type ReplicaStatus struct {
+	Name      string
+	Usable    bool
+	Lag       time.Duration
+	Error     string
+	CheckedAt time.Time
+}
+
+// Status returns the outcome of the replica's most recent lag check
+func (r *Replica) Status() ReplicaStatus {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.status
+}
+
+// check measures the replica's lag and marks it usable when it is receiving WAL and
+// the lag is within maxLag, logging whenever that changes
+func (r *Replica) check(ctx context.Context, maxLag time.Duration) {
+	var receiving bool
+	var seconds float64
+	err := r.QueryRowContext(ctx, replicaLagQuery).Scan(&receiving, &seconds)
+	status := evaluateReplica(r.Name, receiving, seconds, err, maxLag)
+	lag := status.Lag
+
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+
+	if was := r.usable.Swap(status.Usable); was != status.Usable {
+		if status.Usable {
+			slog.Info("replica back in rotation", "replica", r.Name, "lag", lag)
+		} else {
+			slog.Warn("replica out of rotation, reading from primary", "replica", r.Name, "reason", status.Error)
+		}
+	}
+
+	metrics.DBReplicaLag.WithLabelValues(r.Name).Set(lag.Seconds())
+	usable := 0.0
+	if status.Usable {
+		usable = 1
+	}
+	metrics.DBReplicaUsable.WithLabelValues(r.Name).Set(usable)
+}
+
+// evaluateReplica grades the outcome of a lag query. A standby whose WAL receiver
+// is gone would report no lag while falling ever further behind, so it is unusable.
+func evaluateReplica(name string, receiving bool, seconds float64, err error, maxLag time.Duration) ReplicaStatus {
+	lag := time.Duration(seconds * float64(time.Second))
+	status := ReplicaStatus{Name: name, Lag: lag, CheckedAt: time.Now()}
+	switch {
+	case err != nil:
+		status.Error = err.Error()
+	case !receiving:
+		status.Error = "no WAL receiver is streaming from the primary"
+	case lag > maxLag:
+		status.Error = fmt.Sprintf("replication lag %s exceeds %s", lag.Round(time.Millisecond), maxLag)
+	default:
+		status.Usable = true
+	}
+	return status
+}
+
+// openReplicas opens a pool for every replica URL in cfg, sharing the primary's
+// TLS, session and pool settings, and starts checking their lag. Replicas that
+// cannot be reached yet are left out of rotation rather than failing startup.
+func (db *DB) openReplicas(ctx context.Context, cfg Config) error {
+	for i, target := range cfg.ReplicaURLs {
+		replicaCfg := cfg
+		replicaCfg.URL = target
+		dsn, err := replicaCfg.DSN()
+		if err != nil {
+			return fmt.Errorf("invalid replica %d: %w", i+1, err)
+		}
+
+		pool, err := sql.Open("postgres", dsn)
+		if err != nil {
+			return fmt.Errorf("failed to open replica %d: %w", i+1, err)
+		}
+		pool.SetMaxOpenConns(cfg.MaxOpenConns)
+		pool.SetMaxIdleConns(cfg.MaxIdleConns)
+		pool.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+		pool.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
+
+		name := "replica-" + strconv.Itoa(i+1)
+		if u, err := url.Parse(target); err == nil && u.Host != "" {
+			name = u.Host
+		}
+		db.replicas = append(db.replicas, &Replica{Name: name, DB: pool})
+	}
+	if len(db.replicas) == 0 {
+		return nil
+	}
+
+	db.maxLag = cfg.ReplicaMaxLag
+	db.checkReplicas(ctx, cfg.ReplicaCheckInterval)
+
+	db.stop = make(chan struct{})
+	db.done = make(chan struct{})
+	go func() {
+		defer close(db.done)
+		ticker := time.NewTicker(cfg.ReplicaCheckInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				db.checkReplicas(context.Background(), cfg.ReplicaCheckInterval)
+			case <-db.stop:
+				return
+			}
+		}
+	}()
+	return nil
+}
+
+// checkReplicas checks every replica concurrently, bounding each check by timeout
+func (db *DB) checkReplicas(ctx context.Context, timeout time.Duration) {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	var wg sync.WaitGroup
+	for _, r := range db.replicas {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.check(ctx, db.maxLag)
+		}()
+	}
+	wg.Wait()
+}
+
+// Replicas returns the configured read replicas
+func (db *DB) Replicas() []*Replica {
+	return db.replicas
+}
+
+// Reader returns the pool read-only queries should use: a replica whose lag is
+// within the limit, picked round-robin, or the primary when there is none
+func (db *DB) Reader() *sql.DB {
+	n := len(db.replicas)
+	start := int(db.next.Add(1))
+	for i := 0; i < n; i++ {
+		if r := db.replicas[(start+i)%n]; r.usable.Load() {
+			return r.DB
+		}
+	}
+	return db.DB
+}
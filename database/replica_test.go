@@ -0,0 +1,78 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestReaderRouting(t *testing.T) {
+	open := func() *sql.DB {
+		pool, err := sql.Open("postgres", "host=localhost")
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { pool.Close() })
+		return pool
+	}
+
+	primary := open()
+	a := &Replica{Name: "a", DB: open()}
+	b := &Replica{Name: "b", DB: open()}
+	db := &DB{DB: primary}
+
+	if db.Reader() != primary {
+		t.Error("Reader without replicas did not return the primary")
+	}
+
+	db.replicas = []*Replica{a, b}
+	if db.Reader() != primary {
+		t.Error("Reader with no replica in rotation did not fall back to the primary")
+	}
+
+	a.usable.Store(true)
+	b.usable.Store(true)
+	seen := map[*sql.DB]int{}
+	for i := 0; i < 4; i++ {
+		seen[db.Reader()]++
+	}
+	if seen[a.DB] != 2 || seen[b.DB] != 2 {
+		t.Errorf("reads were not spread across replicas: a=%d b=%d primary=%d", seen[a.DB], seen[b.DB], seen[primary])
+	}
+
+	a.usable.Store(false)
+	for i := 0; i < 3; i++ {
+		if db.Reader() != b.DB {
+			t.Fatal("Reader returned a replica out of rotation")
+		}
+	}
+}
+
+func TestEvaluateReplica(t *testing.T) {
+	tests := []struct {
+		name       string
+		receiving  bool
+		seconds    float64
+		err        error
+		wantUsable bool
+	}{
+		{"caught up", true, 0, nil, true},
+		{"within limit", true, 2, nil, true},
+		{"too far behind", true, 30, nil, false},
+		{"receiver gone", false, 0, nil, false},
+		{"query failed", true, 0, errors.New("connection refused"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			status := evaluateReplica("replica", tt.receiving, tt.seconds, tt.err, 5*time.Second)
+			if status.Usable != tt.wantUsable {
+				t.Errorf("usable = %v, want %v (%s)", status.Usable, tt.wantUsable, status.Error)
+			}
+			if !status.Usable && status.Error == "" {
+				t.Error("unusable replica without a reason")
+			}
+		})
+	}
+}
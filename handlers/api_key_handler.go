@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"fmt"
+	"index-duel-backend/service"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+)
+
+const errCodeAPIKeyNotFound = "api_key_not_found"
+
+// APIKeyHandler serves the admin endpoints for managing API keys
+type APIKeyHandler struct {
+	keys *service.APIKeyService
+}
+
+// NewAPIKeyHandler creates a new API key handler
+func NewAPIKeyHandler(keys *service.APIKeyService) *APIKeyHandler {
+	return &APIKeyHandler{keys: keys}
+}
+
+// createAPIKeyRequest is the body of a key creation request
+type createAPIKeyRequest struct {
+	Name   string   `json:"name"`
+	Scopes []string `json:"scopes"`
+}
+
+// ListHandler returns every key without its secret
+func (h *APIKeyHandler) ListHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.keys.ListAPIKeys(r.Context())
+	if err != nil {
+		writeError(w, r, internalError("Failed to list API keys", err))
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{"api_keys": keys})
+}
+
+// CreateHandler issues a new key. The secret is only ever returned here.
+func (h *APIKeyHandler) CreateHandler(w http.ResponseWriter, r *http.Request) {
+	var req createAPIKeyRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	issued, err := h.keys.CreateAPIKey(r.Context(), req.Name, req.Scopes)
+	if err != nil {
+		writeServiceError(w, r, "Failed to create API key", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, issued)
+}
+
+// RotateHandler revokes a key and returns its replacement
+func (h *APIKeyHandler) RotateHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	issued, err := h.keys.RotateAPIKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, internalError("Failed to rotate API key", err))
+		return
+	}
+	if issued == nil {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeAPIKeyNotFound, fmt.Sprintf("No active API key %d", id)))
+		return
+	}
+	writeJSON(w, http.StatusCreated, issued)
+}
+
+// RevokeHandler revokes a key
+func (h *APIKeyHandler) RevokeHandler(w http.ResponseWriter, r *http.Request) {
+	id, ok := apiKeyID(w, r)
+	if !ok {
+		return
+	}
+
+	revoked, err := h.keys.RevokeAPIKey(r.Context(), id)
+	if err != nil {
+		writeError(w, r, internalError("Failed to revoke API key", err))
+		return
+	}
+	if !revoked {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeAPIKeyNotFound, fmt.Sprintf("No active API key %d", id)))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// apiKeyID parses the key ID route variable, answering with a 400 when it is invalid
+func apiKeyID(w http.ResponseWriter, r *http.Request) (int64, bool) {
+	id, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || id <= 0 {
+		writeError(w, r, newAPIError(http.StatusBadRequest, "invalid_api_key_id", "API key ID must be a positive integer"))
+		return 0, false
+	}
+	return id, true
+}
@@ -0,0 +1,158 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/models"
+	"index-duel-backend/service"
+	"net/http"
+	"strings"
+)
+
+// APIKeyHeader carries the client API key
+const APIKeyHeader = "X-API-Key"
+
+// Error codes for rejected credentials
+const (
+	errCodeUnauthorized      = "unauthorized"
+	errCodeInsufficientScope = "insufficient_scope"
+)
+
+type (
+	apiKeyContextKey struct{}
+	userContextKey   struct{}
+)
+
+// APIKeyChecker resolves a client API key
+type APIKeyChecker interface {
+	Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error)
+}
+
+// AccessTokenChecker resolves a user access token
+type AccessTokenChecker interface {
+	Authenticate(ctx context.Context, token string) (*models.User, error)
+}
+
+var (
+	_ APIKeyChecker      = (*service.APIKeyService)(nil)
+	_ AccessTokenChecker = (*service.AuthService)(nil)
+)
+
+// Authenticator checks API keys and user access tokens, enforcing per-route scopes
+type Authenticator struct {
+	keys  APIKeyChecker
+	users AccessTokenChecker
+}
+
+// NewAuthenticator creates a new authenticator
+func NewAuthenticator(keys APIKeyChecker, users AccessTokenChecker) *Authenticator {
+	return &Authenticator{keys: keys, users: users}
+}
+
+// RequireScope returns middleware that rejects requests without a valid API key
+// granting scope
+func (a *Authenticator) RequireScope(scope string) func(http.Handler) http.Handler {
+	return a.checkScope(scope, true)
+}
+
+// OptionalScope returns middleware that lets requests without an API key through
+// anonymously, but checks a key that is sent as RequireScope does
+func (a *Authenticator) OptionalScope(scope string) func(http.Handler) http.Handler {
+	return a.checkScope(scope, false)
+}
+
+func (a *Authenticator) checkScope(scope string, required bool) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			plaintext := apiKeyFromRequest(r)
+			if plaintext == "" && !required {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if plaintext == "" {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+				writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeUnauthorized, "An API key is required"))
+				return
+			}
+			key, err := a.keys.Authenticate(r.Context(), plaintext)
+			if errors.Is(err, service.ErrInvalidAPIKey) {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+				writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeUnauthorized, "The API key is invalid or revoked"))
+				return
+			}
+			if err != nil {
+				writeError(w, r, internalError("Failed to check API key", err))
+				return
+			}
+			if !key.HasScope(scope) {
+				writeError(w, r, newAPIError(http.StatusForbidden, errCodeInsufficientScope, "The API key lacks the "+scope+" scope"))
+				return
+			}
+
+			ctx := context.WithValue(r.Context(), apiKeyContextKey{}, key)
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+// RequireUser returns middleware that rejects requests without a valid user access
+// token and puts the authenticated user into the request context
+func (a *Authenticator) RequireUser(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token := accessTokenFromRequest(r)
+		if token == "" {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="users"`)
+			writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeUnauthorized, "An access token is required"))
+			return
+		}
+
+		user, err := a.users.Authenticate(r.Context(), token)
+		if errors.Is(err, service.ErrInvalidToken) {
+			w.Header().Set("WWW-Authenticate", `Bearer realm="users", error="invalid_token"`)
+			writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeUnauthorized, "The access token is invalid or expired"))
+			return
+		}
+		if err != nil {
+			writeError(w, r, internalError("Failed to check access token", err))
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey{}, user)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// UserFromContext returns the user that authenticated the request, if any
+func UserFromContext(ctx context.Context) *models.User {
+	user, _ := ctx.Value(userContextKey{}).(*models.User)
+	return user
+}
+
+// APIKeyFromContext returns the key that authenticated the request, if any
+func APIKeyFromContext(ctx context.Context) *models.APIKey {
+	key, _ := ctx.Value(apiKeyContextKey{}).(*models.APIKey)
+	return key
+}
+
+// apiKeyFromRequest reads the key from the X-API-Key header, or from a bearer
+// token that looks like an API key
+func apiKeyFromRequest(r *http.Request) string {
+	if key := strings.TrimSpace(r.Header.Get(APIKeyHeader)); key != "" {
+		return key
+	}
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	if ok && strings.EqualFold(scheme, "Bearer") && strings.HasPrefix(token, "idk_") {
+		return strings.TrimSpace(token)
+	}
+	return ""
+}
+
+// accessTokenFromRequest reads a bearer token that is not an API key
+func accessTokenFromRequest(r *http.Request) string {
+	scheme, token, ok := strings.Cut(r.Header.Get("Authorization"), " ")
+	token = strings.TrimSpace(token)
+	if ok && strings.EqualFold(scheme, "Bearer") && !strings.HasPrefix(token, "idk_") {
+		return token
+	}
+	return ""
+}
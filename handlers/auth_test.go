@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/models"
+	"index-duel-backend/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeKeyChecker accepts the keys it holds and fails with err when set
+type fakeKeyChecker struct {
+	keys map[string]*models.APIKey
+	err  error
+}
+
+func (f *fakeKeyChecker) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	if f.err != nil {
+		return nil, f.err
+	}
+	if key, ok := f.keys[plaintext]; ok {
+		return key, nil
+	}
+	return nil, service.ErrInvalidAPIKey
+}
+
+func TestAPIKeyFromRequest(t *testing.T) {
+	tests := []struct {
+		name          string
+		apiKey        string
+		authorization string
+		want          string
+	}{
+		{"no credentials", "", "", ""},
+		{"header", "idk_0a1b2c3d_secret", "", "idk_0a1b2c3d_secret"},
+		{"bearer", "", "Bearer idk_0a1b2c3d_secret", "idk_0a1b2c3d_secret"},
+		{"bearer lowercase scheme", "", "bearer idk_0a1b2c3d_secret", "idk_0a1b2c3d_secret"},
+		{"header wins", "idk_header_secret", "Bearer idk_bearer_secret", "idk_header_secret"},
+		{"non key bearer token", "", "Bearer eyJhbGciOiJIUzI1NiJ9.e30.sig", ""},
+		{"basic auth", "", "Basic dXNlcjpwYXNz", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/api/v1/cards/1", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			if tt.authorization != "" {
+				req.Header.Set("Authorization", tt.authorization)
+			}
+			if got := apiKeyFromRequest(req); got != tt.want {
+				t.Errorf("apiKeyFromRequest() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRequireScope(t *testing.T) {
+	syncKey := &models.APIKey{ID: 1, Scopes: []string{models.ScopeSyncRead}}
+	adminKey := &models.APIKey{ID: 2, Scopes: []string{models.ScopeAdmin}}
+	checker := &fakeKeyChecker{keys: map[string]*models.APIKey{"idk_sync": syncKey, "idk_admin": adminKey}}
+
+	tests := []struct {
+		name       string
+		checker    *fakeKeyChecker
+		apiKey     string
+		scope      string
+		optional   bool
+		wantStatus int
+		wantCode   string
+		wantKey    *models.APIKey
+	}{
+		{name: "missing key", checker: checker, scope: models.ScopeSyncRead,
+			wantStatus: http.StatusUnauthorized, wantCode: errCodeUnauthorized},
+		{name: "unknown key", checker: checker, apiKey: "idk_unknown", scope: models.ScopeSyncRead,
+			wantStatus: http.StatusUnauthorized, wantCode: errCodeUnauthorized},
+		{name: "store failure", checker: &fakeKeyChecker{err: errors.New("connection refused")}, apiKey: "idk_sync",
+			scope: models.ScopeSyncRead, wantStatus: http.StatusInternalServerError, wantCode: errCodeInternal},
+		{name: "missing scope", checker: checker, apiKey: "idk_sync", scope: models.ScopeDecksWrite,
+			wantStatus: http.StatusForbidden, wantCode: errCodeInsufficientScope},
+		{name: "granted scope", checker: checker, apiKey: "idk_sync", scope: models.ScopeSyncRead,
+			wantStatus: http.StatusOK, wantKey: syncKey},
+		{name: "admin grants every scope", checker: checker, apiKey: "idk_admin", scope: models.ScopeDecksWrite,
+			wantStatus: http.StatusOK, wantKey: adminKey},
+		{name: "optional without key", checker: checker, scope: models.ScopeSyncRead, optional: true,
+			wantStatus: http.StatusOK},
+		{name: "optional with unknown key", checker: checker, apiKey: "idk_unknown", scope: models.ScopeSyncRead, optional: true,
+			wantStatus: http.StatusUnauthorized, wantCode: errCodeUnauthorized},
+		{name: "optional with granted scope", checker: checker, apiKey: "idk_sync", scope: models.ScopeSyncRead, optional: true,
+			wantStatus: http.StatusOK, wantKey: syncKey},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var gotKey *models.APIKey
+			next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				gotKey = APIKeyFromContext(r.Context())
+			})
+			auth := NewAuthenticator(tt.checker, nil)
+			middleware := auth.RequireScope
+			if tt.optional {
+				middleware = auth.OptionalScope
+			}
+			handler := middleware(tt.scope)(next)
+
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/cards/1", nil)
+			if tt.apiKey != "" {
+				req.Header.Set(APIKeyHeader, tt.apiKey)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantCode != "" && errorCode(t, rec) != tt.wantCode {
+				t.Errorf("error code = %q, want %q", errorCode(t, rec), tt.wantCode)
+			}
+			if tt.wantStatus == http.StatusUnauthorized && rec.Header().Get("WWW-Authenticate") == "" {
+				t.Error("401 without WWW-Authenticate")
+			}
+			if gotKey != tt.wantKey {
+				t.Errorf("key in context = %+v, want %+v", gotKey, tt.wantKey)
+			}
+		})
+	}
+}
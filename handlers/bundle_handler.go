@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/bundle"
+	"net/http"
+	"os"
+)
+
+// BundleHandler serves the prebuilt SQLite catalogue bundle
+type BundleHandler struct {
+	builder *bundle.Builder
+}
+
+// NewBundleHandler creates a new bundle handler
+func NewBundleHandler(builder *bundle.Builder) *BundleHandler {
+	return &BundleHandler{
+		builder: builder,
+	}
+}
+
+// ManifestHandler returns the manifest of the current bundle
+func (h *BundleHandler) ManifestHandler(w http.ResponseWriter, r *http.Request) {
+	manifest := h.builder.Current()
+	if manifest == nil {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeBundleUnavailable, "Catalogue bundle is not available yet"))
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-cache")
+	json.NewEncoder(w).Encode(manifest)
+}
+
+// DownloadHandler serves the current bundle file. Range and conditional requests
+// are supported, and the checksum is sent so clients can verify the download.
+func (h *BundleHandler) DownloadHandler(w http.ResponseWriter, r *http.Request) {
+	manifest := h.builder.Current()
+	if manifest == nil {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeBundleUnavailable, "Catalogue bundle is not available yet"))
+		return
+	}
+
+	f, err := os.Open(h.builder.Path(manifest))
+	if err != nil {
+		writeError(w, r, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    errCodeBundleUnavailable,
+			Message: "Catalogue bundle is not available",
+			Err:     fmt.Errorf("failed to open catalogue bundle %s: %w", manifest.FileName, err),
+		})
+		return
+	}
+	defer f.Close()
+
+	w.Header().Set("Content-Type", "application/x-sqlite3")
+	w.Header().Set("Content-Disposition", `attachment; filename="`+manifest.FileName+`"`)
+	w.Header().Set("ETag", `"`+manifest.SHA256+`"`)
+	w.Header().Set("X-Checksum-SHA256", manifest.SHA256)
+	w.Header().Set("X-Bundle-Version", manifest.Version)
+	w.Header().Set("X-Bundle-Last-Update", manifest.LastUpdate)
+
+	http.ServeContent(w, r, manifest.FileName, manifest.CreatedAt, f)
+}
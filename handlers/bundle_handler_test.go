@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"index-duel-backend/bundle"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// newTestBundle builds a bundle of one card into a temporary directory
+func newTestBundle(t *testing.T) (*bundle.Builder, *bundle.Manifest) {
+	t.Helper()
+	store := repository.NewMemoryCardStore()
+	if err := store.CreateCard(context.Background(), &models.Card{ID: 1, Name: "Card"}); err != nil {
+		t.Fatal(err)
+	}
+	builder, err := bundle.NewBuilder(store, t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	manifest, err := builder.Build(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	return builder, manifest
+}
+
+func TestBundleHandlersWithoutBundle(t *testing.T) {
+	builder, err := bundle.NewBuilder(repository.NewMemoryCardStore(), t.TempDir(), false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewBundleHandler(builder)
+
+	for name, handler := range map[string]http.HandlerFunc{"manifest": h.ManifestHandler, "download": h.DownloadHandler} {
+		rec := httptest.NewRecorder()
+		handler(rec, httptest.NewRequest(http.MethodGet, "/bundle", nil))
+		if rec.Code != http.StatusNotFound || errorCode(t, rec) != errCodeBundleUnavailable {
+			t.Errorf("%s = %d %s, want 404 %s", name, rec.Code, rec.Body, errCodeBundleUnavailable)
+		}
+	}
+}
+
+func TestManifestHandler(t *testing.T) {
+	builder, manifest := newTestBundle(t)
+
+	rec := httptest.NewRecorder()
+	NewBundleHandler(builder).ManifestHandler(rec, httptest.NewRequest(http.MethodGet, "/bundle/manifest", nil))
+
+	if rec.Code != http.StatusOK || rec.Header().Get("Cache-Control") != "no-cache" {
+		t.Fatalf("status = %d, Cache-Control = %q", rec.Code, rec.Header().Get("Cache-Control"))
+	}
+	var got bundle.Manifest
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatalf("invalid manifest: %v", err)
+	}
+	if got.SHA256 != manifest.SHA256 || got.FileName != manifest.FileName || got.Seq != manifest.Seq || got.CardCount != 1 {
+		t.Errorf("manifest = %+v, want %+v", got, manifest)
+	}
+}
+
+func TestDownloadHandler(t *testing.T) {
+	builder, manifest := newTestBundle(t)
+	etag := `"` + manifest.SHA256 + `"`
+
+	tests := []struct {
+		name       string
+		headers    map[string]string
+		wantStatus int
+		wantBody   string
+	}{
+		{name: "full download", wantStatus: http.StatusOK},
+		{name: "matching ETag", headers: map[string]string{"If-None-Match": etag}, wantStatus: http.StatusNotModified},
+		{name: "stale ETag", headers: map[string]string{"If-None-Match": `"older"`}, wantStatus: http.StatusOK},
+		{name: "resumed download", headers: map[string]string{"Range": "bytes=0-15"},
+			wantStatus: http.StatusPartialContent, wantBody: "SQLite format 3\x00"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/bundle/download", nil)
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+			rec := httptest.NewRecorder()
+			NewBundleHandler(builder).DownloadHandler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("ETag"); got != etag {
+				t.Errorf("ETag = %q, want %q", got, etag)
+			}
+			switch tt.wantStatus {
+			case http.StatusOK:
+				sum := sha256.Sum256(rec.Body.Bytes())
+				if hex.EncodeToString(sum[:]) != manifest.SHA256 || rec.Header().Get("X-Checksum-SHA256") != manifest.SHA256 {
+					t.Error("downloaded bundle does not match the manifest checksum")
+				}
+				if rec.Header().Get("Content-Type") != "application/x-sqlite3" || rec.Header().Get("X-Bundle-Version") != manifest.Version {
+					t.Errorf("headers = %v", rec.Header())
+				}
+			case http.StatusPartialContent:
+				if rec.Body.String() != tt.wantBody {
+					t.Errorf("body = %q, want %q", rec.Body, tt.wantBody)
+				}
+			}
+		})
+	}
+}
@@ -1,32 +1,68 @@
 package handlers
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
+	"index-duel-backend/metrics"
 	"index-duel-backend/models"
+	"index-duel-backend/ratelimit"
 	"index-duel-backend/service"
-	"log"
+	"index-duel-backend/tracing"
+	"io"
+	"log/slog"
 	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
 )
 
+// CardReader is the card service behaviour CardHandler needs. service.CardService
+// implements it.
+type CardReader interface {
+	GetCard(ctx context.Context, cardID int64, relations models.CardRelations) (*models.Card, error)
+	GetCardCount(ctx context.Context) (int, error)
+	IsFullSync(req models.SyncRequest) bool
+	SyncCards(ctx context.Context, req models.SyncRequest, relations models.CardRelations) (*service.SyncResult, error)
+}
+
+var _ CardReader = (*service.CardService)(nil)
+
 // CardHandler handles HTTP requests for cards
 type CardHandler struct {
-	cardService *service.CardService
+	cardService   CardReader
+	limiter       *RateLimiter
+	fullSyncLimit ratelimit.Limit
 }
 
 // NewCardHandler creates a new card handler
-func NewCardHandler(cardService *service.CardService) *CardHandler {
+func NewCardHandler(cardService CardReader) *CardHandler {
 	return &CardHandler{
 		cardService: cardService,
 	}
 }
 
-// HealthCheckHandler provides a health check endpoint
+// LimitFullSyncs gives syncs that send the whole catalogue their own, smaller budget
+// per client on top of any route limits
+func (h *CardHandler) LimitFullSyncs(limiter *RateLimiter, limit ratelimit.Limit) {
+	h.limiter = limiter
+	h.fullSyncLimit = limit
+}
+
+// HealthCheckHandler provides a health check endpoint. It counts every card, so
+// orchestrator probes should use /livez and /readyz instead.
 func (h *CardHandler) HealthCheckHandler(w http.ResponseWriter, r *http.Request) {
 	// Get card count to verify database connectivity
-	count, err := h.cardService.GetCardCount()
+	count, err := h.cardService.GetCardCount(r.Context())
 	if err != nil {
-		http.Error(w, "Database connection failed", http.StatusServiceUnavailable)
+		writeError(w, r, &APIError{
+			Status:  http.StatusServiceUnavailable,
+			Code:    errCodeDatabaseUnavailable,
+			Message: "Database connection failed",
+			Err:     err,
+		})
 		return
 	}
 
@@ -39,35 +75,129 @@ func (h *CardHandler) HealthCheckHandler(w http.ResponseWriter, r *http.Request)
 	json.NewEncoder(w).Encode(response)
 }
 
+// GetCardHandler returns a single card, honouring the fields and include parameters
+func (h *CardHandler) GetCardHandler(w http.ResponseWriter, r *http.Request) {
+	cardID, err := strconv.ParseInt(mux.Vars(r)["id"], 10, 64)
+	if err != nil || cardID <= 0 {
+		writeError(w, r, newAPIError(http.StatusBadRequest, errCodeInvalidCardID, "Card ID must be a positive integer"))
+		return
+	}
+
+	projection, err := parseProjection(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
+		return
+	}
+
+	card, err := h.cardService.GetCard(r.Context(), cardID, projection.relations)
+	if err != nil {
+		writeError(w, r, internalError("Failed to get card", fmt.Errorf("failed to get card %d: %w", cardID, err)))
+		return
+	}
+	if card == nil {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeCardNotFound, fmt.Sprintf("Card %d does not exist", cardID)))
+		return
+	}
+
+	// Encode before writing anything, so an encoding failure still gets an error
+	// response; once the body is written, failures can only be logged
+	data, err := projection.marshalCard(card)
+	if err != nil {
+		writeError(w, r, internalError("Failed to encode card", fmt.Errorf("failed to encode card %d: %w", cardID, err)))
+		return
+	}
+	w.Header().Set("Content-Type", contentTypeJSON)
+	if _, err := w.Write(append(data, '\n')); err != nil {
+		slog.WarnContext(r.Context(), "failed to write card response", "card_id", cardID, "error", err)
+	}
+}
+
 // SyncCardsForMobileHandler handles synchronization requests from mobile app
 func (h *CardHandler) SyncCardsForMobileHandler(w http.ResponseWriter, r *http.Request) {
 	var syncRequest models.SyncRequest
 
-	// Parse request body
-	if err := json.NewDecoder(r.Body).Decode(&syncRequest); err != nil {
-		http.Error(w, "Invalid request body", http.StatusBadRequest)
+	projection, err := parseProjection(r.URL.Query())
+	if err != nil {
+		writeError(w, r, err)
 		return
 	}
 
-	log.Printf("Sync request received with last_update: '%s'", syncRequest.LastUpdate)
+	// Parse request body; an empty body is a first sync
+	if err := json.NewDecoder(r.Body).Decode(&syncRequest); err != nil && err != io.EOF {
+		writeError(w, r, newAPIError(http.StatusBadRequest, errCodeInvalidRequestBody, "Request body must be a JSON object"))
+		return
+	}
 
-	// Get cards based on last update timestamp
-	cards, currentTime, err := h.cardService.SyncCards(syncRequest.LastUpdate)
+	fullSync := h.cardService.IsFullSync(syncRequest)
+	if h.limiter != nil && fullSync {
+		if !h.limiter.Allow(w, r, "full_sync", h.fullSyncLimit) {
+			return
+		}
+	}
+
+	// Get cards changed since the client's cursor
+	result, err := h.cardService.SyncCards(r.Context(), syncRequest, projection.relations)
 	if err != nil {
-		log.Printf("Error during sync: %v", err)
-		http.Error(w, fmt.Sprintf("Failed to sync cards: %v", err), http.StatusInternalServerError)
+		writeServiceError(w, r, "Failed to sync cards", err)
 		return
 	}
+	cards := result.Cards
+	defer cards.Close()
 
-	// Prepare response
-	response := models.SyncResponse{
-		Cards:      cards,
-		LastUpdate: currentTime,
-		TotalCards: len(cards),
+	// Load the first page before writing anything, so query failures still get a proper status
+	hasCard := cards.Next()
+	if err := cards.Err(); err != nil {
+		writeError(w, r, internalError("Failed to sync cards", err))
+		return
 	}
 
-	log.Printf("Sending %d cards to mobile client. New last_update: %s", len(cards), currentTime)
+	// Encoding and writing get their own span; the page queries it triggers are
+	// traced as its siblings under the request span
+	_, span := tracing.Start(r.Context(), "CardHandler.streamSync")
+	defer span.End()
 
-	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode(response)
+	stream := newSyncWriter(w, r, projection)
+	if err := stream.Begin(); err != nil {
+		slog.WarnContext(r.Context(), "failed to write sync response", "error", err)
+		return
+	}
+	for ok := hasCard; ok; ok = cards.Next() {
+		if err := stream.WriteCard(cards.Card()); err != nil {
+			slog.WarnContext(r.Context(), "failed to write sync response", "error", err)
+			return
+		}
+	}
+	if err := cards.Err(); err != nil {
+		// The status line is already sent. Returning would end the chunked body cleanly,
+		// so reset the connection to let the client see the failure.
+		slog.ErrorContext(r.Context(), "sync failed mid-stream", "cards_sent", stream.Count(), "error", err)
+		span.RecordError(err)
+		span.SetStatus(codes.Error, "sync failed mid-stream")
+		panic(http.ErrAbortHandler)
+	}
+	trailer := syncTrailer{
+		LastUpdate:         result.LastUpdate,
+		Seq:                result.Seq,
+		FullResyncRequired: result.FullResyncRequired,
+	}
+	if err := stream.End(trailer); err != nil {
+		slog.WarnContext(r.Context(), "failed to write sync response", "error", err)
+		return
+	}
+
+	kind := "delta"
+	if fullSync {
+		kind = "full"
+	}
+	metrics.SyncPayloadBytes.WithLabelValues(stream.Encoding(), kind).Observe(float64(stream.Bytes()))
+	metrics.SyncCards.WithLabelValues(kind).Observe(float64(stream.Count()))
+	span.SetAttributes(
+		attribute.String("sync.kind", kind),
+		attribute.String("sync.encoding", stream.Encoding()),
+		attribute.Int("sync.cards", stream.Count()),
+		attribute.Int64("sync.bytes", stream.Bytes()),
+	)
+
+	slog.InfoContext(r.Context(), "sync completed", "kind", kind, "encoding", stream.Encoding(),
+		"cards", stream.Count(), "bytes", stream.Bytes(), "seq", result.Seq, "last_update", result.LastUpdate)
 }
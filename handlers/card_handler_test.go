@@ -0,0 +1,224 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"index-duel-backend/service"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+// failingReader fails every call, as the card service does when the database is down
+type failingReader struct{}
+
+var errDatabaseDown = errors.New("database is down")
+
+func (failingReader) GetCard(context.Context, int64, models.CardRelations) (*models.Card, error) {
+	return nil, errDatabaseDown
+}
+
+func (failingReader) GetCardCount(context.Context) (int, error) {
+	return 0, errDatabaseDown
+}
+
+func (failingReader) IsFullSync(models.SyncRequest) bool {
+	return false
+}
+
+func (failingReader) SyncCards(context.Context, models.SyncRequest, models.CardRelations) (*service.SyncResult, error) {
+	return nil, errDatabaseDown
+}
+
+// newTestCardService returns a card service over an in-memory store holding cards 1-3
+func newTestCardService(t *testing.T) *service.CardService {
+	t.Helper()
+	store := repository.NewMemoryCardStore()
+	for id := int64(1); id <= 3; id++ {
+		card := models.Card{ID: id, Name: "Card", CardSets: []models.CardSet{{SetCode: "LOB-001"}}}
+		if err := store.CreateCard(context.Background(), &card); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return service.NewCardService(store, nil, service.CardServiceConfig{FullResyncHorizon: 90 * 24 * time.Hour})
+}
+
+// errorCode returns the code of an error response body
+func errorCode(t *testing.T, rec *httptest.ResponseRecorder) string {
+	t.Helper()
+	var body errorBody
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("invalid error body %q: %v", rec.Body.String(), err)
+	}
+	return body.Error.Code
+}
+
+func TestGetCardHandler(t *testing.T) {
+	cardService := newTestCardService(t)
+
+	tests := []struct {
+		name       string
+		reader     CardReader
+		path       string
+		wantStatus int
+		wantCode   string
+	}{
+		{"existing card", cardService, "/cards/2", http.StatusOK, ""},
+		{"projected card", cardService, "/cards/2?fields=id,name", http.StatusOK, ""},
+		{"missing card", cardService, "/cards/99", http.StatusNotFound, errCodeCardNotFound},
+		{"non-numeric id", cardService, "/cards/abc", http.StatusBadRequest, errCodeInvalidCardID},
+		{"zero id", cardService, "/cards/0", http.StatusBadRequest, errCodeInvalidCardID},
+		{"store failure", failingReader{}, "/cards/2", http.StatusInternalServerError, errCodeInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			router := mux.NewRouter()
+			router.HandleFunc("/cards/{id}", NewCardHandler(tt.reader).GetCardHandler)
+
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(t, rec); code != tt.wantCode {
+					t.Errorf("error code = %q, want %q", code, tt.wantCode)
+				}
+				return
+			}
+			var card map[string]interface{}
+			if err := json.Unmarshal(rec.Body.Bytes(), &card); err != nil {
+				t.Fatalf("invalid card body: %v", err)
+			}
+			if card["id"] != float64(2) {
+				t.Errorf("card = %v, want card 2", card)
+			}
+		})
+	}
+}
+
+func TestSyncCardsForMobileHandler(t *testing.T) {
+	cardService := newTestCardService(t)
+
+	tests := []struct {
+		name       string
+		reader     CardReader
+		body       string
+		wantStatus int
+		wantCode   string
+		wantCards  int
+	}{
+		{"empty body is a first sync", cardService, "", http.StatusOK, "", 3},
+		{"null cursors", cardService, `{"last_update":null,"since_seq":null}`, http.StatusOK, "", 3},
+		{"since sequence", cardService, `{"since_seq":1}`, http.StatusOK, "", 2},
+		{"up to date", cardService, `{"since_seq":3}`, http.StatusOK, "", 0},
+		{"negative since_seq", cardService, `{"since_seq":-1}`, http.StatusBadRequest, service.ErrCodeInvalidSinceSeq, 0},
+		{"malformed last_update", cardService, `{"last_update":"yesterday"}`, http.StatusBadRequest, service.ErrCodeInvalidLastUpdate, 0},
+		{"not JSON", cardService, `cards please`, http.StatusBadRequest, errCodeInvalidRequestBody, 0},
+		{"store failure", failingReader{}, "", http.StatusInternalServerError, errCodeInternal, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/cards/sync", strings.NewReader(tt.body))
+			rec := httptest.NewRecorder()
+			NewCardHandler(tt.reader).SyncCardsForMobileHandler(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d: %s", rec.Code, tt.wantStatus, rec.Body)
+			}
+			if tt.wantCode != "" {
+				if code := errorCode(t, rec); code != tt.wantCode {
+					t.Errorf("error code = %q, want %q", code, tt.wantCode)
+				}
+				return
+			}
+
+			var resp struct {
+				Cards      []json.RawMessage `json:"cards"`
+				Seq        int64             `json:"seq"`
+				TotalCards int               `json:"total_cards"`
+			}
+			if err := json.Unmarshal(rec.Body.Bytes(), &resp); err != nil {
+				t.Fatalf("invalid sync body: %v", err)
+			}
+			if len(resp.Cards) != tt.wantCards || resp.TotalCards != tt.wantCards {
+				t.Errorf("cards = %d, total_cards = %d, want %d", len(resp.Cards), resp.TotalCards, tt.wantCards)
+			}
+			if resp.Seq != 3 {
+				t.Errorf("seq = %d, want 3", resp.Seq)
+			}
+		})
+	}
+}
+
+// midStreamFailingReader serves a sync whose second page fails to load
+type midStreamFailingReader struct{ failingReader }
+
+func (midStreamFailingReader) SyncCards(ctx context.Context, _ models.SyncRequest, _ models.CardRelations) (*service.SyncResult, error) {
+	fetch := func(ctx context.Context, afterID int64, limit int) ([]models.Card, error) {
+		if afterID > 0 {
+			return nil, errDatabaseDown
+		}
+		return []models.Card{{ID: 1, Name: "Card"}}, nil
+	}
+	return &service.SyncResult{Cards: repository.NewCardIterator(ctx, fetch, 1)}, nil
+}
+
+func TestSyncFailingMidStreamAbortsResponse(t *testing.T) {
+	for _, accept := range []string{contentTypeJSON, contentTypeProtobuf} {
+		t.Run(accept, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodPost, "/cards/sync", nil)
+			req.Header.Set("Accept", accept)
+			rec := httptest.NewRecorder()
+
+			defer func() {
+				if got := recover(); got != http.ErrAbortHandler {
+					t.Errorf("handler recovered %v, want http.ErrAbortHandler", got)
+				}
+			}()
+			NewCardHandler(midStreamFailingReader{}).SyncCardsForMobileHandler(rec, req)
+		})
+	}
+}
+
+func TestHealthCheckHandler(t *testing.T) {
+	tests := []struct {
+		name       string
+		reader     CardReader
+		wantStatus int
+	}{
+		{"healthy", newTestCardService(t), http.StatusOK},
+		{"database down", failingReader{}, http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rec := httptest.NewRecorder()
+			NewCardHandler(tt.reader).HealthCheckHandler(rec, httptest.NewRequest(http.MethodGet, "/health", nil))
+
+			if rec.Code != tt.wantStatus {
+				t.Fatalf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if tt.wantStatus != http.StatusOK {
+				if code := errorCode(t, rec); code != errCodeDatabaseUnavailable {
+					t.Errorf("error code = %q, want %q", code, errCodeDatabaseUnavailable)
+				}
+				return
+			}
+			var body struct {
+				CardsCount int `json:"cards_count"`
+			}
+			json.Unmarshal(rec.Body.Bytes(), &body)
+			if body.CardsCount != 3 {
+				t.Errorf("cards_count = %d, want 3", body.CardsCount)
+			}
+		})
+	}
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"index-duel-backend/middleware"
+	"index-duel-backend/service"
+	"log/slog"
+	"net/http"
+)
+
+// Stable error codes returned to clients
+const (
+	errCodeInvalidRequestBody  = "invalid_request_body"
+	errCodeInvalidCardID       = "invalid_card_id"
+	errCodeCardNotFound        = "card_not_found"
+	errCodeBundleUnavailable   = "bundle_unavailable"
+	errCodeDatabaseUnavailable = "database_unavailable"
+	errCodeNotFound            = "not_found"
+	errCodeMethodNotAllowed    = "method_not_allowed"
+	errCodeInternal            = "internal_error"
+)
+
+// APIError is an error response. Code and Message are sent to the client; Err is
+// the internal cause, which is only logged.
+type APIError struct {
+	Status  int
+	Code    string
+	Message string
+	Err     error
+}
+
+func (e *APIError) Error() string {
+	if e.Err != nil {
+		return e.Code + ": " + e.Err.Error()
+	}
+	return e.Code + ": " + e.Message
+}
+
+func (e *APIError) Unwrap() error {
+	return e.Err
+}
+
+// newAPIError creates an error response without an internal cause
+func newAPIError(status int, code, message string) *APIError {
+	return &APIError{Status: status, Code: code, Message: message}
+}
+
+// internalError wraps an unexpected failure behind a generic message
+func internalError(message string, err error) *APIError {
+	return &APIError{Status: http.StatusInternalServerError, Code: errCodeInternal, Message: message, Err: err}
+}
+
+// errorBody is the JSON envelope every error response uses
+type errorBody struct {
+	Error errorDetail `json:"error"`
+}
+
+type errorDetail struct {
+	Code      string `json:"code"`
+	Message   string `json:"message"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+// writeError logs the error with its request ID and sends the JSON envelope.
+// Errors that are not *APIError are reported as internal errors.
+func writeError(w http.ResponseWriter, r *http.Request, err error) {
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) {
+		apiErr = internalError("An internal error occurred", err)
+	}
+
+	requestID := middleware.RequestIDFromContext(r.Context())
+	if apiErr.Err != nil {
+		slog.ErrorContext(r.Context(), "request failed", "method", r.Method, "path", r.URL.Path,
+			"code", apiErr.Code, "status", apiErr.Status, "error", apiErr.Err)
+	}
+
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.WriteHeader(apiErr.Status)
+	json.NewEncoder(w).Encode(errorBody{Error: errorDetail{
+		Code:      apiErr.Code,
+		Message:   apiErr.Message,
+		RequestID: requestID,
+	}})
+}
+
+// writeServiceError maps service validation errors to 400 responses and anything
+// else to an internal error behind message
+func writeServiceError(w http.ResponseWriter, r *http.Request, message string, err error) {
+	var invalid *service.InvalidRequestError
+	if errors.As(err, &invalid) {
+		writeError(w, r, newAPIError(http.StatusBadRequest, invalid.Code, invalid.Message))
+		return
+	}
+	writeError(w, r, internalError(message, err))
+}
+
+// writeJSON sends a JSON response body
+func writeJSON(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", contentTypeJSON)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
+
+// NotFoundHandler answers requests that match no route
+func NotFoundHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, newAPIError(http.StatusNotFound, errCodeNotFound, "No such endpoint"))
+	})
+}
+
+// MethodNotAllowedHandler answers requests whose path matches a route but whose method does not
+func MethodNotAllowedHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writeError(w, r, newAPIError(http.StatusMethodNotAllowed, errCodeMethodNotAllowed, "Method not allowed for this endpoint"))
+	})
+}
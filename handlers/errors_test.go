@@ -0,0 +1,59 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"index-duel-backend/middleware"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestWriteErrorEnvelope(t *testing.T) {
+	tests := []struct {
+		name       string
+		err        error
+		wantStatus int
+		wantCode   string
+	}{
+		{"client error", newAPIError(http.StatusBadRequest, errCodeInvalidCardID, "Card ID must be a positive integer"), http.StatusBadRequest, errCodeInvalidCardID},
+		{"internal error", internalError("Failed to sync cards", errors.New("pq: relation \"cards\" does not exist")), http.StatusInternalServerError, errCodeInternal},
+		{"plain error", errors.New("dial tcp 10.0.0.5:5432: connection refused"), http.StatusInternalServerError, errCodeInternal},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := middleware.RequestID(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				writeError(w, r, tt.err)
+			}))
+			req := httptest.NewRequest(http.MethodGet, "/api/v1/cards/1", nil)
+			req.Header.Set(middleware.RequestIDHeader, "req-123")
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := rec.Header().Get("Content-Type"); got != contentTypeJSON {
+				t.Errorf("Content-Type = %q, want %q", got, contentTypeJSON)
+			}
+
+			var body errorBody
+			if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+				t.Fatalf("error body is not JSON: %v\n%s", err, rec.Body.String())
+			}
+			if body.Error.Code != tt.wantCode {
+				t.Errorf("code = %q, want %q", body.Error.Code, tt.wantCode)
+			}
+			if body.Error.RequestID != "req-123" {
+				t.Errorf("request_id = %q, want %q", body.Error.RequestID, "req-123")
+			}
+			for _, leak := range []string{"pq:", "dial tcp"} {
+				if strings.Contains(rec.Body.String(), leak) {
+					t.Errorf("error body leaks internal detail %q: %s", leak, rec.Body.String())
+				}
+			}
+		})
+	}
+}
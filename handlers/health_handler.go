@@ -0,0 +1,38 @@
+package handlers
+
+import (
+	"index-duel-backend/service"
+	"net/http"
+)
+
+// HealthHandler serves the liveness and readiness probes
+type HealthHandler struct {
+	healthService *service.HealthService
+}
+
+// NewHealthHandler creates a new health handler
+func NewHealthHandler(healthService *service.HealthService) *HealthHandler {
+	return &HealthHandler{
+		healthService: healthService,
+	}
+}
+
+// LivezHandler reports that the process is up and serving requests. It does not
+// touch any dependency, so a database outage never gets the process restarted.
+func (h *HealthHandler) LivezHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, map[string]string{"status": service.CheckOK})
+}
+
+// ReadyzHandler runs the readiness checks and returns their breakdown, with 503
+// when any check failed. Warnings are reported with 200.
+func (h *HealthHandler) ReadyzHandler(w http.ResponseWriter, r *http.Request) {
+	report := h.healthService.Readiness(r.Context())
+
+	status := http.StatusOK
+	if !report.Ready() {
+		status = http.StatusServiceUnavailable
+	}
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, status, report)
+}
@@ -0,0 +1,195 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/commalist"
+	"index-duel-backend/models"
+	"net/http"
+	"net/url"
+)
+
+// Error codes for malformed projection parameters
+const (
+	errCodeInvalidFields  = "invalid_fields"
+	errCodeInvalidInclude = "invalid_include"
+)
+
+// cardField is a top-level field of a card's JSON representation
+type cardField struct {
+	name  string
+	value func(card *models.Card) interface{}
+}
+
+// cardFields lists the card's JSON fields in the order models.Card encodes them
+var cardFields = []cardField{
+	{"id", func(c *models.Card) interface{} { return c.ID }},
+	{"name", func(c *models.Card) interface{} { return c.Name }},
+	{"type", func(c *models.Card) interface{} { return c.Type }},
+	{"frameType", func(c *models.Card) interface{} { return c.FrameType }},
+	{"desc", func(c *models.Card) interface{} { return c.Description }},
+	{"atk", func(c *models.Card) interface{} { return c.ATK }},
+	{"def", func(c *models.Card) interface{} { return c.DEF }},
+	{"level", func(c *models.Card) interface{} { return c.Level }},
+	{"race", func(c *models.Card) interface{} { return c.Race }},
+	{"attribute", func(c *models.Card) interface{} { return c.Attribute }},
+	{"card_sets", func(c *models.Card) interface{} { return c.CardSets }},
+	{"card_images", func(c *models.Card) interface{} { return c.CardImages }},
+	{"card_prices", func(c *models.Card) interface{} { return c.CardPrices }},
+	{"CreatedAt", func(c *models.Card) interface{} { return c.CreatedAt }},
+	{"UpdatedAt", func(c *models.Card) interface{} { return c.UpdatedAt }},
+}
+
+// includeRelations maps include values to the card field holding the related rows
+var includeRelations = map[string]string{
+	"sets":   "card_sets",
+	"images": "card_images",
+	"prices": "card_prices",
+}
+
+// cardProjection selects the card fields a client asked for and the related tables
+// needed to fill them
+type cardProjection struct {
+	fields    map[string]bool
+	relations models.CardRelations
+	full      bool
+}
+
+// fullProjection returns every field with all related rows
+var fullProjection = cardProjection{relations: models.AllCardRelations, full: true}
+
+// parseProjection reads the fields and include query parameters. fields lists the
+// card fields to return, include lists the related tables (sets, images, prices) to
+// load; leaving either out selects everything, and id is always returned. Unknown
+// values are reported as a 400 *APIError.
+func parseProjection(query url.Values) (cardProjection, error) {
+	if !query.Has("fields") && !query.Has("include") {
+		return fullProjection, nil
+	}
+
+	fields := make(map[string]bool, len(cardFields))
+	if query.Has("fields") {
+		known := make(map[string]bool, len(cardFields))
+		for _, f := range cardFields {
+			known[f.name] = true
+		}
+		for _, name := range commalist.Split(query.Get("fields")) {
+			if !known[name] {
+				return cardProjection{}, newAPIError(http.StatusBadRequest, errCodeInvalidFields, fmt.Sprintf("unknown field %q", name))
+			}
+			fields[name] = true
+		}
+		fields["id"] = true
+	} else {
+		for _, f := range cardFields {
+			fields[f.name] = true
+		}
+	}
+
+	if query.Has("include") {
+		included := make(map[string]bool, len(includeRelations))
+		for _, name := range commalist.Split(query.Get("include")) {
+			field, ok := includeRelations[name]
+			if !ok {
+				return cardProjection{}, newAPIError(http.StatusBadRequest, errCodeInvalidInclude,
+					fmt.Sprintf("unknown include %q, expected sets, images or prices", name))
+			}
+			included[field] = true
+		}
+		for _, field := range includeRelations {
+			if !included[field] {
+				delete(fields, field)
+			}
+		}
+	}
+
+	p := cardProjection{
+		fields: fields,
+		relations: models.CardRelations{
+			Sets:   fields["card_sets"],
+			Images: fields["card_images"],
+			Prices: fields["card_prices"],
+		},
+	}
+	p.full = len(fields) == len(cardFields)
+	return p, nil
+}
+
+// marshalCard encodes the selected fields of a card as a JSON object
+func (p cardProjection) marshalCard(card *models.Card) ([]byte, error) {
+	if p.full {
+		return json.Marshal(card)
+	}
+
+	buf := []byte{'{'}
+	for _, f := range cardFields {
+		if !p.fields[f.name] {
+			continue
+		}
+		value, err := json.Marshal(f.value(card))
+		if err != nil {
+			return nil, err
+		}
+		if len(buf) > 1 {
+			buf = append(buf, ',')
+		}
+		buf = append(buf, '"')
+		buf = append(buf, f.name...)
+		buf = append(buf, '"', ':')
+		buf = append(buf, value...)
+	}
+	return append(buf, '}'), nil
+}
+
+// apply returns a copy of the card with unselected fields cleared, for encodings
+// that cannot leave fields out
+func (p cardProjection) apply(card *models.Card) *models.Card {
+	if p.full {
+		return card
+	}
+
+	projected := models.Card{ID: card.ID}
+	if p.fields["name"] {
+		projected.Name = card.Name
+	}
+	if p.fields["type"] {
+		projected.Type = card.Type
+	}
+	if p.fields["frameType"] {
+		projected.FrameType = card.FrameType
+	}
+	if p.fields["desc"] {
+		projected.Description = card.Description
+	}
+	if p.fields["atk"] {
+		projected.ATK = card.ATK
+	}
+	if p.fields["def"] {
+		projected.DEF = card.DEF
+	}
+	if p.fields["level"] {
+		projected.Level = card.Level
+	}
+	if p.fields["race"] {
+		projected.Race = card.Race
+	}
+	if p.fields["attribute"] {
+		projected.Attribute = card.Attribute
+	}
+	if p.fields["card_sets"] {
+		projected.CardSets = card.CardSets
+	}
+	if p.fields["card_images"] {
+		projected.CardImages = card.CardImages
+	}
+	if p.fields["card_prices"] {
+		projected.CardPrices = card.CardPrices
+	}
+	if p.fields["CreatedAt"] {
+		projected.CreatedAt = card.CreatedAt
+	}
+	if p.fields["UpdatedAt"] {
+		projected.UpdatedAt = card.UpdatedAt
+	}
+	return &projected
+}
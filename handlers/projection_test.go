@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"index-duel-backend/models"
+	"net/url"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+func TestParseProjection(t *testing.T) {
+	tests := []struct {
+		query     string
+		relations models.CardRelations
+		fields    []string
+		full      bool
+		errCode   string
+	}{
+		{query: "", relations: models.AllCardRelations, full: true},
+		{query: "include=sets,images,prices", relations: models.AllCardRelations, full: true},
+		{query: "include=sets", relations: models.CardRelations{Sets: true},
+			fields: []string{"CreatedAt", "UpdatedAt", "atk", "attribute", "card_sets", "def", "desc", "frameType", "id", "level", "name", "race", "type"}},
+		{query: "include=", relations: models.CardRelations{},
+			fields: []string{"CreatedAt", "UpdatedAt", "atk", "attribute", "def", "desc", "frameType", "id", "level", "name", "race", "type"}},
+		{query: "fields=name,atk", relations: models.CardRelations{}, fields: []string{"atk", "id", "name"}},
+		{query: "fields=name,card_prices", relations: models.CardRelations{Prices: true}, fields: []string{"card_prices", "id", "name"}},
+		{query: "fields=name,card_prices&include=sets", relations: models.CardRelations{}, fields: []string{"id", "name"}},
+		{query: "fields=name, desc ,", relations: models.CardRelations{}, fields: []string{"desc", "id", "name"}},
+		{query: "fields=name,password", errCode: errCodeInvalidFields},
+		{query: "include=sets,artwork", errCode: errCodeInvalidInclude},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.query, func(t *testing.T) {
+			query, _ := url.ParseQuery(tt.query)
+			got, err := parseProjection(query)
+			if tt.errCode != "" {
+				var invalid *APIError
+				if !errors.As(err, &invalid) || invalid.Code != tt.errCode {
+					t.Fatalf("parseProjection(%q) error = %v, want code %q", tt.query, err, tt.errCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseProjection(%q) failed: %v", tt.query, err)
+			}
+			if got.relations != tt.relations {
+				t.Errorf("relations = %+v, want %+v", got.relations, tt.relations)
+			}
+			if got.full != tt.full {
+				t.Errorf("full = %v, want %v", got.full, tt.full)
+			}
+			if !tt.full {
+				var fields []string
+				for name := range got.fields {
+					fields = append(fields, name)
+				}
+				sort.Strings(fields)
+				if !reflect.DeepEqual(fields, tt.fields) {
+					t.Errorf("fields = %v, want %v", fields, tt.fields)
+				}
+			}
+		})
+	}
+}
+
+func TestProjectedCardJSON(t *testing.T) {
+	card := goldenCards()[0]
+	query, _ := url.ParseQuery("fields=name,atk,card_sets")
+	projection, err := parseProjection(query)
+	if err != nil {
+		t.Fatalf("parseProjection failed: %v", err)
+	}
+
+	data, err := projection.marshalCard(&card)
+	if err != nil {
+		t.Fatalf("marshalCard failed: %v", err)
+	}
+	var got map[string]json.RawMessage
+	if err := json.Unmarshal(data, &got); err != nil {
+		t.Fatalf("projected card is not valid JSON: %v\n%s", err, data)
+	}
+	want := []string{"atk", "card_sets", "id", "name"}
+	var keys []string
+	for key := range got {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	if !reflect.DeepEqual(keys, want) {
+		t.Errorf("projected keys = %v, want %v", keys, want)
+	}
+
+	// The full projection must match the plain encoding byte for byte
+	full, _ := fullProjection.marshalCard(&card)
+	plain, _ := json.Marshal(&card)
+	if string(full) != string(plain) {
+		t.Errorf("full projection differs from json.Marshal")
+	}
+
+	applied := projection.apply(&card)
+	if applied.Description != "" || applied.CardImages != nil || applied.Name != card.Name || len(applied.CardSets) != len(card.CardSets) {
+		t.Errorf("apply kept the wrong fields: %+v", applied)
+	}
+}
+
+// TestCardFieldsMatchModel guards the field table against drifting from models.Card
+func TestCardFieldsMatchModel(t *testing.T) {
+	card := goldenCards()[0]
+	var plain map[string]json.RawMessage
+	data, _ := json.Marshal(&card)
+	if err := json.Unmarshal(data, &plain); err != nil {
+		t.Fatalf("failed to decode card: %v", err)
+	}
+	if len(plain) != len(cardFields) {
+		t.Fatalf("models.Card encodes %d fields, cardFields lists %d", len(plain), len(cardFields))
+	}
+	for _, f := range cardFields {
+		want, ok := plain[f.name]
+		if !ok {
+			t.Errorf("cardFields has %q, which models.Card does not encode", f.name)
+			continue
+		}
+		got, _ := json.Marshal(f.value(&card))
+		if string(got) != string(want) {
+			t.Errorf("field %q encodes as %s, want %s", f.name, got, want)
+		}
+	}
+}
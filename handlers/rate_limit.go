@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"index-duel-backend/metrics"
+	"index-duel-backend/ratelimit"
+	"log/slog"
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const errCodeRateLimited = "rate_limited"
+
+// RateLimiter enforces token-bucket limits per client. Clients are identified by
+// their API key and IP address when the request carries a key, since one key is
+// shared by every install of an app, and by IP address otherwise.
+type RateLimiter struct {
+	store      ratelimit.Store
+	trustProxy bool
+}
+
+// NewRateLimiter creates a rate limiter. With trustProxy set, the client IP is taken
+// from the last X-Forwarded-For entry, which is the address the proxy in front of
+// the server saw.
+func NewRateLimiter(store ratelimit.Store, trustProxy bool) *RateLimiter {
+	return &RateLimiter{store: store, trustProxy: trustProxy}
+}
+
+// Limit returns middleware that applies limit to each client, with a separate
+// budget per name
+func (l *RateLimiter) Limit(name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.Allow(w, r, name, limit) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// LimitIP returns middleware that applies limit to each IP address, whether or not
+// the request is authenticated. Put it in front of authentication, so requests with
+// missing or invalid keys are limited too.
+func (l *RateLimiter) LimitIP(name string, limit ratelimit.Limit) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if !l.take(w, r, name, "ip:"+l.clientIP(r), limit) {
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Allow takes a token from the client's budget for name and sets the RateLimit
+// headers. When the budget is exhausted it answers with a 429 and returns false.
+// Failures of the store are logged and counted, and the request is allowed.
+func (l *RateLimiter) Allow(w http.ResponseWriter, r *http.Request, name string, limit ratelimit.Limit) bool {
+	return l.take(w, r, name, l.clientKey(r), limit)
+}
+
+// take takes a token from client's budget for name, as Allow does
+func (l *RateLimiter) take(w http.ResponseWriter, r *http.Request, name, client string, limit ratelimit.Limit) bool {
+	result, err := l.store.Take(r.Context(), name+":"+client, limit, time.Now())
+	if err != nil {
+		metrics.RateLimitStoreErrors.WithLabelValues(name).Inc()
+		slog.WarnContext(r.Context(), "rate limiting failed, allowing request", "budget", name, "error", err)
+		return true
+	}
+
+	h := w.Header()
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", strconv.Itoa(result.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(result.Reset)))
+	if result.Allowed {
+		return true
+	}
+
+	h.Set("Retry-After", strconv.Itoa(ceilSeconds(result.RetryAfter)))
+	writeError(w, r, newAPIError(http.StatusTooManyRequests, errCodeRateLimited, "Too many requests, retry later"))
+	return false
+}
+
+// clientKey identifies the client a request counts against
+func (l *RateLimiter) clientKey(r *http.Request) string {
+	if key := APIKeyFromContext(r.Context()); key != nil {
+		return "key:" + strconv.FormatInt(key.ID, 10) + ":ip:" + l.clientIP(r)
+	}
+	return "ip:" + l.clientIP(r)
+}
+
+// clientIP returns the address of the client that sent the request
+func (l *RateLimiter) clientIP(r *http.Request) string {
+	if l.trustProxy {
+		forwarded := strings.Split(r.Header.Get("X-Forwarded-For"), ",")
+		if ip := strings.TrimSpace(forwarded[len(forwarded)-1]); net.ParseIP(ip) != nil {
+			return ip
+		}
+	}
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,123 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/metrics"
+	"index-duel-backend/models"
+	"index-duel-backend/ratelimit"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestRateLimiterRejectsWithHeaders(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), false)
+	handler := limiter.Limit("api", ratelimit.Limit{Rate: 0.5, Burst: 2})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	wantStatus := []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests}
+	for i, want := range wantStatus {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/cards/sync", nil)
+		req.RemoteAddr = "203.0.113.7:51234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if rec.Code != want {
+			t.Fatalf("request %d: status = %d, want %d", i, rec.Code, want)
+		}
+		if got := rec.Header().Get("RateLimit-Limit"); got != "2" {
+			t.Errorf("request %d: RateLimit-Limit = %q, want 2", i, got)
+		}
+		if want == http.StatusTooManyRequests {
+			if got := rec.Header().Get("Retry-After"); got != "2" {
+				t.Errorf("Retry-After = %q, want 2", got)
+			}
+			if got := rec.Header().Get("RateLimit-Remaining"); got != "0" {
+				t.Errorf("RateLimit-Remaining = %q, want 0", got)
+			}
+			if got := rec.Header().Get("Content-Type"); got != contentTypeJSON {
+				t.Errorf("429 Content-Type = %q, want %q", got, contentTypeJSON)
+			}
+		}
+	}
+
+	// Another client has its own budget
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/cards/sync", nil)
+	req.RemoteAddr = "198.51.100.20:40000"
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Errorf("second client status = %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestRateLimiterClientIP(t *testing.T) {
+	tests := []struct {
+		name       string
+		trustProxy bool
+		forwarded  string
+		want       string
+	}{
+		{"remote address", false, "", "203.0.113.7"},
+		{"forwarded ignored without trust", false, "198.51.100.1", "203.0.113.7"},
+		{"last forwarded entry", true, "192.0.2.1, 198.51.100.1", "198.51.100.1"},
+		{"invalid forwarded entry", true, "unknown", "203.0.113.7"},
+		{"no forwarded header", true, "", "203.0.113.7"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			limiter := NewRateLimiter(ratelimit.NewMemoryStore(), tt.trustProxy)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = "203.0.113.7:51234"
+			if tt.forwarded != "" {
+				req.Header.Set("X-Forwarded-For", tt.forwarded)
+			}
+			if got := limiter.clientIP(req); got != tt.want {
+				t.Errorf("clientIP() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRateLimiterClientKey(t *testing.T) {
+	limiter := NewRateLimiter(ratelimit.NewMemoryStore(), false)
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.RemoteAddr = "203.0.113.7:51234"
+	if got := limiter.clientKey(req); got != "ip:203.0.113.7" {
+		t.Errorf("clientKey() without a key = %q", got)
+	}
+
+	// A key shared by many installs still gives each address its own budget
+	ctx := context.WithValue(req.Context(), apiKeyContextKey{}, &models.APIKey{ID: 3})
+	if got := limiter.clientKey(req.WithContext(ctx)); got != "key:3:ip:203.0.113.7" {
+		t.Errorf("clientKey() with a key = %q", got)
+	}
+}
+
+// failingStore fails every Take
+type failingStore struct{}
+
+func (failingStore) Take(context.Context, string, ratelimit.Limit, time.Time) (ratelimit.Result, error) {
+	return ratelimit.Result{}, errors.New("connection refused")
+}
+
+func TestRateLimiterFailsOpenAndCounts(t *testing.T) {
+	before := testutil.ToFloat64(metrics.RateLimitStoreErrors.WithLabelValues("ip"))
+	handler := NewRateLimiter(failingStore{}, false).LimitIP("ip", ratelimit.Limit{Rate: 1, Burst: 1})(
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("status = %d, want the request allowed", rec.Code)
+	}
+	if got := testutil.ToFloat64(metrics.RateLimitStoreErrors.WithLabelValues("ip")) - before; got != 1 {
+		t.Errorf("store errors counted = %g, want 1", got)
+	}
+}
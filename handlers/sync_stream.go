@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"bufio"
+	"encoding/json"
+	"index-duel-backend/models"
+	"index-duel-backend/syncpb"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"google.golang.org/protobuf/encoding/protowire"
+	"google.golang.org/protobuf/proto"
+)
+
+// syncFlushInterval is how many cards are written between flushes to the client
+const syncFlushInterval = 100
+
+// Content types the sync endpoint can respond with
+const (
+	contentTypeJSON     = "application/json"
+	contentTypeProtobuf = "application/x-protobuf"
+)
+
+// syncTrailer holds the response fields written after the cards
+type syncTrailer struct {
+	LastUpdate         string
+	Seq                int64
+	FullResyncRequired bool
+}
+
+// syncWriter streams a sync response: Begin, one WriteCard per card, then End
+type syncWriter interface {
+	Begin() error
+	WriteCard(card *models.Card) error
+	End(trailer syncTrailer) error
+	Count() int
+	// Bytes returns the size of the body written so far, before compression
+	Bytes() int64
+	// Encoding names the response encoding, for metrics
+	Encoding() string
+}
+
+// newSyncWriter picks the response encoding from the request's Accept header
+func newSyncWriter(w http.ResponseWriter, r *http.Request, projection cardProjection) syncWriter {
+	if acceptsProtobuf(r.Header.Get("Accept")) {
+		return newProtoSyncWriter(w, projection)
+	}
+	return newJSONSyncWriter(w, projection)
+}
+
+// acceptsProtobuf reports whether the Accept header asks for the protobuf encoding
+func acceptsProtobuf(accept string) bool {
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, _, _ := strings.Cut(part, ";")
+		switch strings.ToLower(strings.TrimSpace(mediaType)) {
+		case contentTypeProtobuf, "application/protobuf":
+			return true
+		}
+	}
+	return false
+}
+
+// jsonSyncWriter writes a models.SyncResponse to the client one card at a time,
+// so the full card list never has to be held in memory
+type jsonSyncWriter struct {
+	w          http.ResponseWriter
+	counter    *byteCounter
+	buf        *bufio.Writer
+	flusher    http.Flusher
+	projection cardProjection
+	count      int
+}
+
+func newJSONSyncWriter(w http.ResponseWriter, projection cardProjection) *jsonSyncWriter {
+	flusher, _ := w.(http.Flusher)
+	counter := &byteCounter{w: w}
+	return &jsonSyncWriter{
+		w:          w,
+		counter:    counter,
+		buf:        bufio.NewWriterSize(counter, 32*1024),
+		flusher:    flusher,
+		projection: projection,
+	}
+}
+
+// Begin sends the response headers and opens the cards array
+func (s *jsonSyncWriter) Begin() error {
+	s.w.Header().Set("Content-Type", contentTypeJSON)
+	s.w.WriteHeader(http.StatusOK)
+	_, err := s.buf.WriteString(`{"cards":[`)
+	return err
+}
+
+// WriteCard appends a card to the cards array, flushing periodically
+func (s *jsonSyncWriter) WriteCard(card *models.Card) error {
+	data, err := s.projection.marshalCard(card)
+	if err != nil {
+		return err
+	}
+
+	if s.count > 0 {
+		if err := s.buf.WriteByte(','); err != nil {
+			return err
+		}
+	}
+	if _, err := s.buf.Write(data); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.count%syncFlushInterval == 0 {
+		return s.Flush()
+	}
+	return nil
+}
+
+// End closes the cards array, writes the trailing fields and flushes the response
+func (s *jsonSyncWriter) End(trailer syncTrailer) error {
+	lastUpdateJSON, err := json.Marshal(trailer.LastUpdate)
+	if err != nil {
+		return err
+	}
+
+	if _, err := s.buf.WriteString(`],"last_update":`); err != nil {
+		return err
+	}
+	if _, err := s.buf.Write(lastUpdateJSON); err != nil {
+		return err
+	}
+	if _, err := s.buf.WriteString(`,"seq":` + strconv.FormatInt(trailer.Seq, 10)); err != nil {
+		return err
+	}
+	if _, err := s.buf.WriteString(`,"total_cards":` + strconv.Itoa(s.count)); err != nil {
+		return err
+	}
+	if _, err := s.buf.WriteString(`,"full_resync_required":` + strconv.FormatBool(trailer.FullResyncRequired)); err != nil {
+		return err
+	}
+	if _, err := s.buf.WriteString("}\n"); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Flush pushes buffered bytes through to the client
+func (s *jsonSyncWriter) Flush() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// Count returns the number of cards written so far
+func (s *jsonSyncWriter) Count() int {
+	return s.count
+}
+
+// Bytes returns the number of body bytes flushed to the client so far
+func (s *jsonSyncWriter) Bytes() int64 {
+	return s.counter.n
+}
+
+// Encoding names the response encoding
+func (s *jsonSyncWriter) Encoding() string {
+	return "json"
+}
+
+// protoSyncWriter writes a syncpb.SyncResponse to the client one card at a time.
+// Each card is emitted as its own entry of the repeated cards field, which is a
+// valid encoding of the whole message once the trailing fields are appended. A body
+// cut short between cards also decodes as a valid message, so a failed stream must
+// reset the connection rather than end the response.
+type protoSyncWriter struct {
+	w          http.ResponseWriter
+	counter    *byteCounter
+	buf        *bufio.Writer
+	flusher    http.Flusher
+	projection cardProjection
+	scratch    []byte
+	count      int
+}
+
+func newProtoSyncWriter(w http.ResponseWriter, projection cardProjection) *protoSyncWriter {
+	flusher, _ := w.(http.Flusher)
+	counter := &byteCounter{w: w}
+	return &protoSyncWriter{
+		w:          w,
+		counter:    counter,
+		buf:        bufio.NewWriterSize(counter, 32*1024),
+		flusher:    flusher,
+		projection: projection,
+	}
+}
+
+// Begin sends the response headers
+func (s *protoSyncWriter) Begin() error {
+	s.w.Header().Set("Content-Type", contentTypeProtobuf)
+	s.w.WriteHeader(http.StatusOK)
+	return nil
+}
+
+// WriteCard appends a card to the cards field, flushing periodically. Fields left
+// out of the projection are sent as their zero values.
+func (s *protoSyncWriter) WriteCard(card *models.Card) error {
+	msg, err := protoMarshal.Marshal(syncpb.FromCard(s.projection.apply(card)))
+	if err != nil {
+		return err
+	}
+
+	s.scratch = protowire.AppendTag(s.scratch[:0], syncResponseCardsField, protowire.BytesType)
+	s.scratch = protowire.AppendBytes(s.scratch, msg)
+	if _, err := s.buf.Write(s.scratch); err != nil {
+		return err
+	}
+	s.count++
+
+	if s.count%syncFlushInterval == 0 {
+		return s.Flush()
+	}
+	return nil
+}
+
+// End writes the trailing fields and flushes the response
+func (s *protoSyncWriter) End(trailer syncTrailer) error {
+	s.scratch = s.scratch[:0]
+	if trailer.LastUpdate != "" {
+		s.scratch = protowire.AppendTag(s.scratch, syncResponseLastUpdateField, protowire.BytesType)
+		s.scratch = protowire.AppendString(s.scratch, trailer.LastUpdate)
+	}
+	if s.count != 0 {
+		s.scratch = protowire.AppendTag(s.scratch, syncResponseTotalCardsField, protowire.VarintType)
+		s.scratch = protowire.AppendVarint(s.scratch, uint64(int32(s.count)))
+	}
+	if trailer.Seq != 0 {
+		s.scratch = protowire.AppendTag(s.scratch, syncResponseSeqField, protowire.VarintType)
+		s.scratch = protowire.AppendVarint(s.scratch, uint64(trailer.Seq))
+	}
+	if trailer.FullResyncRequired {
+		s.scratch = protowire.AppendTag(s.scratch, syncResponseFullResyncRequiredField, protowire.VarintType)
+		s.scratch = protowire.AppendVarint(s.scratch, protowire.EncodeBool(true))
+	}
+	if _, err := s.buf.Write(s.scratch); err != nil {
+		return err
+	}
+	return s.Flush()
+}
+
+// Flush pushes buffered bytes through to the client
+func (s *protoSyncWriter) Flush() error {
+	if err := s.buf.Flush(); err != nil {
+		return err
+	}
+	if s.flusher != nil {
+		s.flusher.Flush()
+	}
+	return nil
+}
+
+// Count returns the number of cards written so far
+func (s *protoSyncWriter) Count() int {
+	return s.count
+}
+
+// Bytes returns the number of body bytes flushed to the client so far
+func (s *protoSyncWriter) Bytes() int64 {
+	return s.counter.n
+}
+
+// Encoding names the response encoding
+func (s *protoSyncWriter) Encoding() string {
+	return "protobuf"
+}
+
+// byteCounter counts bytes written through to the response
+type byteCounter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *byteCounter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.n += int64(n)
+	return n, err
+}
+
+// Field numbers of syncpb.SyncResponse, as declared in sync.proto
+const (
+	syncResponseCardsField      protowire.Number = 1
+	syncResponseLastUpdateField protowire.Number = 2
+	syncResponseTotalCardsField protowire.Number = 3
+	syncResponseSeqField        protowire.Number = 4
+
+	syncResponseFullResyncRequiredField protowire.Number = 5
+)
+
+var protoMarshal = proto.MarshalOptions{Deterministic: true}
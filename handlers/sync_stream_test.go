@@ -0,0 +1,199 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"index-duel-backend/models"
+	"index-duel-backend/syncpb"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"google.golang.org/protobuf/proto"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite golden files in testdata")
+
+const (
+	goldenLastUpdate = "2024-05-01T12:00:00Z"
+	goldenSeq        = 48213
+)
+
+// goldenCards covers optional fields both set and unset, and cards with and
+// without related rows
+func goldenCards() []models.Card {
+	atk, def, level := 3000, 2500, 8
+	price, zero := "1.50", "0.00"
+	created := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	updated := time.Date(2024, 4, 30, 22, 15, 0, 0, time.UTC)
+
+	return []models.Card{
+		{
+			ID:          89631139,
+			Name:        "Blue-Eyes White Dragon",
+			Type:        "Normal Monster",
+			FrameType:   "normal",
+			Description: "This legendary dragon is a powerful engine of destruction.",
+			ATK:         &atk,
+			DEF:         &def,
+			Level:       &level,
+			Race:        "Dragon",
+			Attribute:   "LIGHT",
+			CardSets: []models.CardSet{
+				{ID: 1, CardID: 89631139, SetName: "Legend of Blue Eyes White Dragon", SetCode: "LOB-001",
+					SetRarity: "Ultra Rare", SetRarityCode: "(UR)", SetPrice: &price, CreatedAt: created},
+				{ID: 2, CardID: 89631139, SetName: "Starter Deck: Kaiba", SetCode: "SDK-001",
+					SetRarity: "Ultra Rare", SetRarityCode: "(UR)", CreatedAt: created},
+			},
+			CardImages: []models.CardImage{
+				{ID: 7, CardID: 89631139, ImageURL: "https://images.example.com/89631139.jpg",
+					ImageURLSmall:   "https://images.example.com/small/89631139.jpg",
+					ImageURLCropped: "https://images.example.com/cropped/89631139.jpg", CreatedAt: created},
+			},
+			CardPrices: []models.CardPrice{
+				{ID: 3, CardID: 89631139, CardMarketPrice: &price, TCGPlayerPrice: &zero,
+					CreatedAt: created, UpdatedAt: updated},
+			},
+			CreatedAt: created,
+			UpdatedAt: updated,
+		},
+		{
+			ID:          5318639,
+			Name:        "Mystical Space Typhoon",
+			Type:        "Spell Card",
+			FrameType:   "spell",
+			Description: "Target 1 Spell/Trap on the field; destroy that target.",
+			Race:        "Quick-Play",
+			CreatedAt:   created,
+			UpdatedAt:   created,
+		},
+	}
+}
+
+// writeGoldenSync streams the golden cards through a sync writer and returns the body
+func writeGoldenSync(t *testing.T, newWriter func(*httptest.ResponseRecorder) syncWriter) *httptest.ResponseRecorder {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	stream := newWriter(rec)
+	if err := stream.Begin(); err != nil {
+		t.Fatalf("Begin failed: %v", err)
+	}
+	cards := goldenCards()
+	for i := range cards {
+		if err := stream.WriteCard(&cards[i]); err != nil {
+			t.Fatalf("WriteCard failed: %v", err)
+		}
+	}
+	if err := stream.End(syncTrailer{LastUpdate: goldenLastUpdate, Seq: goldenSeq, FullResyncRequired: true}); err != nil {
+		t.Fatalf("End failed: %v", err)
+	}
+	return rec
+}
+
+// checkGolden compares got against testdata/name, rewriting it when -update is set
+func checkGolden(t *testing.T, name string, got []byte) {
+	t.Helper()
+
+	path := filepath.Join("testdata", name)
+	if *updateGolden {
+		if err := os.WriteFile(path, got, 0o644); err != nil {
+			t.Fatalf("failed to update golden file: %v", err)
+		}
+	}
+
+	want, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read golden file: %v", err)
+	}
+	if !bytes.Equal(got, want) {
+		t.Errorf("%s does not match the golden file; run go test ./handlers -update to accept the change", name)
+	}
+}
+
+func TestJSONSyncWriterGolden(t *testing.T) {
+	rec := writeGoldenSync(t, func(rec *httptest.ResponseRecorder) syncWriter { return newJSONSyncWriter(rec, fullProjection) })
+
+	if got := rec.Header().Get("Content-Type"); got != contentTypeJSON {
+		t.Errorf("Content-Type = %q, want %q", got, contentTypeJSON)
+	}
+	checkGolden(t, "sync_response.json.golden", rec.Body.Bytes())
+}
+
+func TestProtoSyncWriterGolden(t *testing.T) {
+	rec := writeGoldenSync(t, func(rec *httptest.ResponseRecorder) syncWriter { return newProtoSyncWriter(rec, fullProjection) })
+
+	if got := rec.Header().Get("Content-Type"); got != contentTypeProtobuf {
+		t.Errorf("Content-Type = %q, want %q", got, contentTypeProtobuf)
+	}
+	checkGolden(t, "sync_response.pb.golden", rec.Body.Bytes())
+}
+
+func TestSyncEncodingsCarrySameData(t *testing.T) {
+	jsonBody, err := os.ReadFile(filepath.Join("testdata", "sync_response.json.golden"))
+	if err != nil {
+		t.Fatalf("failed to read JSON golden file: %v", err)
+	}
+	pbBody, err := os.ReadFile(filepath.Join("testdata", "sync_response.pb.golden"))
+	if err != nil {
+		t.Fatalf("failed to read protobuf golden file: %v", err)
+	}
+
+	var fromJSON models.SyncResponse
+	if err := json.Unmarshal(jsonBody, &fromJSON); err != nil {
+		t.Fatalf("failed to decode JSON response: %v", err)
+	}
+
+	var pb syncpb.SyncResponse
+	if err := proto.Unmarshal(pbBody, &pb); err != nil {
+		t.Fatalf("failed to decode protobuf response: %v", err)
+	}
+	fromProto := models.SyncResponse{
+		LastUpdate:         pb.GetLastUpdate(),
+		Seq:                pb.GetSeq(),
+		TotalCards:         int(pb.GetTotalCards()),
+		FullResyncRequired: pb.GetFullResyncRequired(),
+	}
+	for _, card := range pb.GetCards() {
+		fromProto.Cards = append(fromProto.Cards, syncpb.ToCard(card))
+	}
+
+	// Compare through JSON so only the fields clients can see are considered
+	want, _ := json.Marshal(fromJSON)
+	got, _ := json.Marshal(fromProto)
+	if !bytes.Equal(got, want) {
+		t.Errorf("protobuf response differs from JSON response\n json: %s\nproto: %s", want, got)
+	}
+	if fromProto.Seq != goldenSeq {
+		t.Errorf("seq = %d, want %d", fromProto.Seq, goldenSeq)
+	}
+	if !fromProto.FullResyncRequired {
+		t.Errorf("full_resync_required was lost")
+	}
+	if fromProto.TotalCards != len(goldenCards()) {
+		t.Errorf("total_cards = %d, want %d", fromProto.TotalCards, len(goldenCards()))
+	}
+}
+
+func TestAcceptsProtobuf(t *testing.T) {
+	tests := []struct {
+		accept string
+		want   bool
+	}{
+		{"", false},
+		{"application/json", false},
+		{"application/x-protobuf", true},
+		{"application/json;q=0.5, application/x-protobuf", true},
+		{"Application/X-Protobuf; charset=binary", true},
+		{"application/protobuf", true},
+	}
+
+	for _, tt := range tests {
+		if got := acceptsProtobuf(tt.accept); got != tt.want {
+			t.Errorf("acceptsProtobuf(%q) = %v, want %v", tt.accept, got, tt.want)
+		}
+	}
+}
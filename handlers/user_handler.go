@@ -0,0 +1,138 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"index-duel-backend/service"
+	"net/http"
+)
+
+// Error codes for account and session requests
+const (
+	errCodeEmailTaken         = "email_taken"
+	errCodeInvalidCredentials = "invalid_credentials"
+	errCodeInvalidToken       = "invalid_token"
+)
+
+// UserHandler serves registration, login and session endpoints
+type UserHandler struct {
+	auth *service.AuthService
+}
+
+// NewUserHandler creates a new user handler
+func NewUserHandler(auth *service.AuthService) *UserHandler {
+	return &UserHandler{auth: auth}
+}
+
+// credentialsRequest is the body of a registration or login request
+type credentialsRequest struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+}
+
+// refreshRequest is the body of a refresh or logout request
+type refreshRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// RegisterHandler creates an account
+func (h *UserHandler) RegisterHandler(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	user, err := h.auth.Register(r.Context(), req.Email, req.Password)
+	if errors.Is(err, service.ErrEmailTaken) {
+		writeError(w, r, newAPIError(http.StatusConflict, errCodeEmailTaken, "An account with this email already exists"))
+		return
+	}
+	if err != nil {
+		writeServiceError(w, r, "Failed to register", err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, user)
+}
+
+// LoginHandler starts a session, returning an access token and a refresh token
+func (h *UserHandler) LoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req credentialsRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	tokens, err := h.auth.Login(r.Context(), req.Email, req.Password)
+	if errors.Is(err, service.ErrInvalidCredentials) {
+		writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeInvalidCredentials, "Email or password is incorrect"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError("Failed to log in", err))
+		return
+	}
+	writeTokens(w, tokens)
+}
+
+// RefreshHandler exchanges a refresh token for a new token pair
+func (h *UserHandler) RefreshHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	tokens, err := h.auth.Refresh(r.Context(), req.RefreshToken)
+	if errors.Is(err, service.ErrInvalidToken) {
+		writeError(w, r, newAPIError(http.StatusUnauthorized, errCodeInvalidToken, "The refresh token is invalid, expired or revoked"))
+		return
+	}
+	if err != nil {
+		writeError(w, r, internalError("Failed to refresh session", err))
+		return
+	}
+	writeTokens(w, tokens)
+}
+
+// LogoutHandler ends the session the refresh token belongs to
+func (h *UserHandler) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	var req refreshRequest
+	if !decodeJSONBody(w, r, &req) {
+		return
+	}
+
+	if err := h.auth.Logout(r.Context(), req.RefreshToken); err != nil {
+		writeError(w, r, internalError("Failed to log out", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// LogoutAllHandler ends every session of the authenticated user
+func (h *UserHandler) LogoutAllHandler(w http.ResponseWriter, r *http.Request) {
+	user := UserFromContext(r.Context())
+	if err := h.auth.LogoutAll(r.Context(), user.ID); err != nil {
+		writeError(w, r, internalError("Failed to log out", err))
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// MeHandler returns the authenticated user
+func (h *UserHandler) MeHandler(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, UserFromContext(r.Context()))
+}
+
+// writeTokens sends a token pair, which must never be cached
+func writeTokens(w http.ResponseWriter, tokens *service.TokenPair) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, tokens)
+}
+
+// decodeJSONBody decodes a JSON request body into v, answering with a 400 when it
+// is not a JSON object
+func decodeJSONBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		writeError(w, r, newAPIError(http.StatusBadRequest, errCodeInvalidRequestBody, "Request body must be a JSON object"))
+		return false
+	}
+	return true
+}
@@ -0,0 +1,80 @@
+// Package logging configures structured logging with log/slog and carries
+// per-request and per-run attributes through contexts.
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"strings"
+)
+
+// New creates a logger writing to w. level is one of debug, info, warn or error,
+// and format is json or text; empty values select info and json.
+func New(w io.Writer, level, format string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if level != "" {
+		if err := lvl.UnmarshalText([]byte(level)); err != nil {
+			return nil, fmt.Errorf("invalid log level %q, expected debug, info, warn or error", level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+	var handler slog.Handler
+	switch strings.ToLower(format) {
+	case "", "json":
+		handler = slog.NewJSONHandler(w, opts)
+	case "text":
+		handler = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("invalid log format %q, expected json or text", format)
+	}
+	return slog.New(contextHandler{handler}), nil
+}
+
+// Setup creates a logger and installs it as the default for both slog and the
+// standard log package
+func Setup(w io.Writer, level, format string) (*slog.Logger, error) {
+	logger, err := New(w, level, format)
+	if err != nil {
+		return nil, err
+	}
+	slog.SetDefault(logger)
+	log.SetFlags(0)
+	return logger, nil
+}
+
+type attrsKey struct{}
+
+// WithAttrs returns a context whose log records carry attrs in addition to any
+// attributes already attached to ctx
+func WithAttrs(ctx context.Context, attrs ...slog.Attr) context.Context {
+	existing, _ := ctx.Value(attrsKey{}).([]slog.Attr)
+	merged := make([]slog.Attr, 0, len(existing)+len(attrs))
+	merged = append(merged, existing...)
+	merged = append(merged, attrs...)
+	return context.WithValue(ctx, attrsKey{}, merged)
+}
+
+// contextHandler adds the attributes attached with WithAttrs to records logged
+// through the *Context logging methods
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if attrs, ok := ctx.Value(attrsKey{}).([]slog.Attr); ok {
+		r.AddAttrs(attrs...)
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return contextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+func (h contextHandler) WithGroup(name string) slog.Handler {
+	return contextHandler{h.Handler.WithGroup(name)}
+}
@@ -0,0 +1,62 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func TestNewValidatesOptions(t *testing.T) {
+	tests := []struct {
+		level, format string
+		wantErr       bool
+	}{
+		{"", "", false},
+		{"debug", "json", false},
+		{"WARN", "text", false},
+		{"verbose", "json", true},
+		{"info", "xml", true},
+	}
+	for _, tt := range tests {
+		_, err := New(&bytes.Buffer{}, tt.level, tt.format)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("New(%q, %q) error = %v, want error %v", tt.level, tt.format, err, tt.wantErr)
+		}
+	}
+}
+
+func TestLevelFiltersRecords(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "warn", "text")
+	if err != nil {
+		t.Fatal(err)
+	}
+	logger.Info("hidden")
+	logger.Warn("shown")
+	if out := buf.String(); strings.Contains(out, "hidden") || !strings.Contains(out, "shown") {
+		t.Errorf("unexpected output %q", out)
+	}
+}
+
+func TestWithAttrsAddsContextAttributes(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, "", "json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := WithAttrs(context.Background(), slog.String("request_id", "abc"))
+	ctx = WithAttrs(ctx, slog.Int("attempt", 2))
+	logger.InfoContext(ctx, "hello", "cards", 3)
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if record["msg"] != "hello" || record["request_id"] != "abc" || record["attempt"] != float64(2) || record["cards"] != float64(3) {
+		t.Errorf("unexpected record %v", record)
+	}
+}
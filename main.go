@@ -1,14 +1,31 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"index-duel-backend/bundle"
+	"index-duel-backend/config"
 	"index-duel-backend/database"
 	"index-duel-backend/handlers"
+	"index-duel-backend/logging"
+	"index-duel-backend/metrics"
+	"index-duel-backend/middleware"
+	"index-duel-backend/models"
+	"index-duel-backend/ratelimit"
 	"index-duel-backend/repository"
 	"index-duel-backend/scheduler"
 	"index-duel-backend/service"
-	"log"
+	"index-duel-backend/tracing"
+	"index-duel-backend/upstream"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"strconv"
+	"syscall"
+	"time"
 
 	"github.com/gorilla/mux"
 	"github.com/joho/godotenv"
@@ -16,87 +33,319 @@ import (
 
 func main() {
 	// Load environment variables
-	if err := godotenv.Load(); err != nil {
-		log.Printf("Warning: .env file not found: %v", err)
+	envErr := godotenv.Load()
+
+	// Load and validate the configuration from a file, the environment and flags
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// Logging is not configured yet, and the problems read best one per line
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
+	}
+
+	// Print the effective configuration instead of serving
+	if len(args) > 0 && args[0] == "config" {
+		if err := cfg.Print(os.Stdout); err != nil {
+			fatal("config command failed", err)
+		}
+		return
+	}
+
+	// Serving signs access tokens, which the apikey command never does
+	if len(args) == 0 || args[0] != "apikey" {
+		if err := cfg.ValidateServer(); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(2)
+		}
+	}
+
+	// Structured logs go to stderr
+	if _, err := logging.Setup(os.Stderr, cfg.Log.Level, cfg.Log.Format); err != nil {
+		fatal("failed to configure logging", err)
+	}
+	if envErr != nil {
+		slog.Warn(".env file not found", "error", envErr)
+	}
+	slog.Info("effective configuration", "config", cfg.Redacted())
+
+	// Tracing is off unless an otlp or stdout exporter is selected
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:    cfg.Tracing.Exporter,
+		ServiceName: "index-duel-backend",
+		SampleRatio: cfg.Tracing.SampleRatio,
+	})
+	if err != nil {
+		fatal("failed to configure tracing", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			slog.Warn("failed to flush traces", "error", err)
+		}
+	}()
+
+	// Initialize database connection, retrying until the server is up or we are interrupted
+	connectCtx, stopConnect := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	db, err := database.NewDB(connectCtx, database.Config{
+		URL:              cfg.Database.URL,
+		Host:             cfg.Database.Host,
+		Port:             cfg.Database.Port,
+		Name:             cfg.Database.Name,
+		User:             cfg.Database.User,
+		Password:         cfg.Database.Password,
+		SSLMode:          cfg.Database.SSLMode,
+		SSLRootCert:      cfg.Database.SSLRootCert,
+		SSLCert:          cfg.Database.SSLCert,
+		SSLKey:           cfg.Database.SSLKey,
+		ApplicationName:  cfg.Database.ApplicationName,
+		ConnectTimeout:   cfg.Database.ConnectTimeout,
+		StatementTimeout: cfg.Database.StatementTimeout,
+		MaxOpenConns:     cfg.Database.MaxOpenConns,
+		MaxIdleConns:     cfg.Database.MaxIdleConns,
+		ConnMaxLifetime:  cfg.Database.ConnMaxLifetime,
+		ConnMaxIdleTime:  cfg.Database.ConnMaxIdleTime,
+		RetryTimeout:     cfg.Database.RetryTimeout,
 
-	// Initialize database connection
-	db, err := database.NewDB()
+		ReplicaURLs:          cfg.Database.ReplicaURLs,
+		ReplicaMaxLag:        cfg.Database.ReplicaMaxLag,
+		ReplicaCheckInterval: cfg.Database.ReplicaCheckInterval,
+	})
+	stopConnect()
 	if err != nil {
-		log.Fatal("Failed to connect to database:", err)
+		fatal("failed to connect to database", err)
 	}
 	defer db.Close()
 
-	log.Println("Connected to database successfully")
+	slog.Info("connected to database")
+
+	// Apply pending schema migrations
+	if err := db.Migrate(); err != nil {
+		fatal("failed to migrate database", err)
+	}
 
 	// Initialize repositories
 	cardRepo := repository.NewCardRepository(db)
+	apiKeyRepo := repository.NewAPIKeyRepository(db)
+	userRepo := repository.NewUserRepository(db)
 
 	// Initialize services
-	cardService := service.NewCardService(cardRepo)
+	// With the http source and no URL there is nothing to ingest; stored cards are still served
+	var cardSource upstream.CardSource
+	switch {
+	case cfg.Ingest.Source == "file":
+		cardSource = upstream.NewFileSource(cfg.Ingest.Path, cfg.Ingest.HTTPTimeout)
+	case cfg.Ingest.Source == "dir":
+		cardSource = upstream.NewDirSource(cfg.Ingest.Path, cfg.Ingest.HTTPTimeout)
+	case cfg.Ingest.APIURL != "":
+		cardSource = upstream.NewHTTPSource(cfg.Ingest.APIURL, cfg.Ingest.HTTPTimeout)
+	}
+	cardService := service.NewCardService(cardRepo, cardSource, service.CardServiceConfig{
+		BatchSize:         cfg.Ingest.BatchSize,
+		BatchDelay:        cfg.Ingest.BatchDelay,
+		FullResyncHorizon: cfg.Sync.FullResyncHorizon,
+	})
+	apiKeyService := service.NewAPIKeyService(apiKeyRepo)
+
+	// Manage API keys from the command line instead of serving
+	if len(args) > 0 && args[0] == "apikey" {
+		if err := runAPIKeyCommand(context.Background(), apiKeyService, args[1:]); err != nil {
+			fatal("apikey command failed", err)
+		}
+		return
+	}
+
+	authService, err := service.NewAuthService(userRepo, service.AuthConfig{
+		Secret:          cfg.Auth.JWTSecret,
+		AccessTokenTTL:  cfg.Auth.AccessTokenTTL,
+		RefreshTokenTTL: cfg.Auth.RefreshTokenTTL,
+	})
+	if err != nil {
+		fatal("failed to initialize user authentication", err)
+	}
+
+	// Initialize the catalogue bundle builder
+	bundleBuilder, err := bundle.NewBuilder(cardRepo, cfg.Bundle.Dir, cfg.Bundle.IncludeImages)
+	if err != nil {
+		fatal("failed to initialize catalogue bundle builder", err)
+	}
 
 	// Initialize handlers
 	cardHandler := handlers.NewCardHandler(cardService)
+	bundleHandler := handlers.NewBundleHandler(bundleBuilder)
+	apiKeyHandler := handlers.NewAPIKeyHandler(apiKeyService)
+	userHandler := handlers.NewUserHandler(authService)
+	auth := handlers.NewAuthenticator(apiKeyService, authService)
+	healthHandler := handlers.NewHealthHandler(service.NewHealthService(db, cardRepo, service.HealthConfig{
+		CheckTimeout:  cfg.Health.CheckTimeout,
+		IngestWarnAge: cfg.Health.IngestWarnAge,
+		IngestMaxAge:  cfg.Health.IngestMaxAge,
+	}))
+
+	// Initialize per-client rate limiting
+	var rateLimitStore ratelimit.Store = ratelimit.NewMemoryStore()
+	if cfg.RateLimit.Store == "postgres" {
+		rateLimitStore = ratelimit.NewPostgresStore(db.DB)
+	}
+	limiter := handlers.NewRateLimiter(rateLimitStore, cfg.RateLimit.TrustProxy)
+	ipLimit := mustParseLimit(cfg.RateLimit.IP)
+	defaultLimit := mustParseLimit(cfg.RateLimit.Default)
+	authLimit := mustParseLimit(cfg.RateLimit.Auth)
+	cardHandler.LimitFullSyncs(limiter, mustParseLimit(cfg.RateLimit.FullSync))
 
-	// Initialize and start the weekly scheduler
-	cardScheduler := scheduler.NewScheduler(cardService)
-	cardScheduler.Start()
+	// Initialize and start the periodic ingest scheduler, unless there is nothing to ingest
+	if cardSource != nil {
+		cardScheduler := scheduler.NewScheduler(cardService, bundleBuilder, cfg.Ingest.Interval)
+		cardScheduler.Start()
+	} else {
+		slog.Warn("no ingest source configured, serving stored cards only")
+	}
 
-	log.Println("Weekly card synchronization scheduler initialized")
+	// Expose connection pool statistics alongside the request metrics
+	metrics.RegisterDBStats(db.DB, "primary")
+	for _, replica := range db.Replicas() {
+		metrics.RegisterDBStats(replica.DB, replica.Name)
+	}
 
 	// Setup routes
 	router := mux.NewRouter()
+	router.NotFoundHandler = metrics.Middleware(handlers.NotFoundHandler())
+	router.MethodNotAllowedHandler = metrics.Middleware(handlers.MethodNotAllowedHandler())
+
+	// Orchestrator probes: liveness only needs the process, readiness checks its dependencies
+	router.HandleFunc("/livez", healthHandler.LivezHandler).Methods("GET", "HEAD")
+	router.HandleFunc("/readyz", healthHandler.ReadyzHandler).Methods("GET", "HEAD")
+
+	// Cross-origin policies: the public API follows cors.allowed_origins, while admin
+	// routes only accept the origins in cors.admin_allowed_origins
+	publicCORS := newCORS(cfg.CORS, cfg.CORS.AllowedOrigins, []string{"GET", "HEAD", "POST"})
+	adminCORS := newCORS(cfg.CORS, cfg.CORS.AdminAllowedOrigins, []string{"GET", "POST", "DELETE"})
 
-	// API routes
+	// API routes. Every route accepts OPTIONS so its CORS policy can answer preflights.
 	api := router.PathPrefix("/api/v1").Subrouter()
+	// Every client address has a budget checked before its credentials, so requests
+	// with missing or invalid keys cannot hammer the key lookup unlimited
+	api.Use(limiter.LimitIP("ip", ipLimit))
+	public := api.NewRoute().Subrouter()
+	public.Use(publicCORS.Middleware)
 
 	// Health check
-	api.HandleFunc("/health", cardHandler.HealthCheckHandler).Methods("GET")
+	public.HandleFunc("/health", cardHandler.HealthCheckHandler).Methods("GET", "OPTIONS")
+
+	// Catalogue routes take an API key with the sync:read scope. Keys are only
+	// required once auth.require_catalogue_key is on, since released mobile clients
+	// call these routes without one.
+	catalogue := public.NewRoute().Subrouter()
+	if cfg.Auth.RequireCatalogueKey {
+		catalogue.Use(auth.RequireScope(models.ScopeSyncRead))
+	} else {
+		catalogue.Use(auth.OptionalScope(models.ScopeSyncRead))
+	}
+	catalogue.Use(limiter.Limit("api", defaultLimit))
 
 	// Main endpoint for mobile app synchronization
-	api.HandleFunc("/cards/sync", cardHandler.SyncCardsForMobileHandler).Methods("POST")
+	catalogue.HandleFunc("/cards/sync", cardHandler.SyncCardsForMobileHandler).Methods("POST", "OPTIONS")
+
+	// Single card lookup
+	catalogue.HandleFunc("/cards/{id:[0-9]+}", cardHandler.GetCardHandler).Methods("GET", "OPTIONS")
 
-	// Add CORS middleware
-	router.Use(corsMiddleware)
+	// Prebuilt SQLite catalogue for first install
+	catalogue.HandleFunc("/catalogue/bundle", bundleHandler.DownloadHandler).Methods("GET", "HEAD", "OPTIONS")
+	catalogue.HandleFunc("/catalogue/bundle/manifest", bundleHandler.ManifestHandler).Methods("GET", "OPTIONS")
 
-	// Add logging middleware
-	router.Use(loggingMiddleware)
+	// User accounts and sessions; credential endpoints get a tight per-IP budget
+	accounts := public.PathPrefix("/auth").Subrouter()
+	accounts.Use(limiter.Limit("auth", authLimit))
+	accounts.HandleFunc("/register", userHandler.RegisterHandler).Methods("POST", "OPTIONS")
+	accounts.HandleFunc("/login", userHandler.LoginHandler).Methods("POST", "OPTIONS")
+	accounts.HandleFunc("/refresh", userHandler.RefreshHandler).Methods("POST", "OPTIONS")
+	accounts.HandleFunc("/logout", userHandler.LogoutHandler).Methods("POST", "OPTIONS")
+	accounts.Handle("/logout-all", auth.RequireUser(http.HandlerFunc(userHandler.LogoutAllHandler))).Methods("POST", "OPTIONS")
+	public.Handle("/users/me", auth.RequireUser(http.HandlerFunc(userHandler.MeHandler))).Methods("GET", "OPTIONS")
 
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	// API key management requires the admin scope
+	admin := api.PathPrefix("/admin").Subrouter()
+	admin.Use(adminCORS.Middleware)
+	admin.Use(auth.RequireScope(models.ScopeAdmin))
+	admin.HandleFunc("/apikeys", apiKeyHandler.ListHandler).Methods("GET", "OPTIONS")
+	admin.HandleFunc("/apikeys", apiKeyHandler.CreateHandler).Methods("POST")
+	admin.HandleFunc("/apikeys/{id:[0-9]+}/rotate", apiKeyHandler.RotateHandler).Methods("POST", "OPTIONS")
+	admin.HandleFunc("/apikeys/{id:[0-9]+}", apiKeyHandler.RevokeHandler).Methods("DELETE", "OPTIONS")
+
+	// Name request spans after the matched route
+	router.Use(tracing.RouteMiddleware)
+
+	// Add request metrics middleware, outside compression so sizes are as sent
+	router.Use(metrics.Middleware)
+
+	// Add response compression middleware
+	router.Use(middleware.Compress)
+
+	// Prometheus metrics are served on their own port, which is kept off the public
+	// network, rather than next to the API
+	if cfg.Server.MetricsPort > 0 {
+		go serveMetrics(cfg.Server.MetricsPort)
 	}
 
-	log.Printf("Starting server on port %s...", port)
-	log.Printf("Health check available at: http://localhost:%s/api/v1/health", port)
-	log.Printf("Mobile sync endpoint: POST http://localhost:%s/api/v1/cards/sync", port)
-	log.Printf("Weekly synchronization with Yu-Gi-Oh API enabled")
+	port := strconv.Itoa(cfg.Server.Port)
+	slog.Info("starting server", "port", port,
+		"health", "/api/v1/health", "sync", "POST /api/v1/cards/sync", "bundle", "GET /api/v1/catalogue/bundle")
 
-	if err := http.ListenAndServe(":"+port, router); err != nil {
-		log.Fatal("Failed to start server:", err)
+	// Tracing, request IDs and access logs wrap the whole router so unmatched routes get them too
+	handler := tracing.Middleware(middleware.RequestID(middleware.Logging(router)))
+	if err := http.ListenAndServe(":"+port, handler); err != nil {
+		fatal("failed to start server", err)
 	}
 }
 
-// corsMiddleware adds CORS headers
-func corsMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		w.Header().Set("Access-Control-Allow-Origin", "*")
-		w.Header().Set("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		w.Header().Set("Access-Control-Allow-Headers", "Content-Type, Authorization")
+// serveMetrics serves /metrics on port for scrapers inside the deployment
+func serveMetrics(port int) {
+	routes := http.NewServeMux()
+	routes.Handle("GET /metrics", metrics.Handler())
 
-		if r.Method == "OPTIONS" {
-			w.WriteHeader(http.StatusOK)
-			return
-		}
+	slog.Info("serving metrics", "port", port, "path", "/metrics")
+	if err := http.ListenAndServe(":"+strconv.Itoa(port), routes); err != nil {
+		fatal("failed to serve metrics", err)
+	}
+}
 
-		next.ServeHTTP(w, r)
-	})
+// fatal logs err at error level and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-// loggingMiddleware logs HTTP requests
-func loggingMiddleware(next http.Handler) http.Handler {
-	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("%s %s %s", r.Method, r.URL.Path, r.RemoteAddr)
-		next.ServeHTTP(w, r)
+// mustParseLimit parses a rate limit the configuration has already validated
+func mustParseLimit(value string) ratelimit.Limit {
+	limit, err := ratelimit.ParseLimit(value)
+	if err != nil {
+		fatal("invalid rate limit", err)
+	}
+	return limit
+}
+
+// newCORS builds a CORS policy allowing methods from origins. Credentials and
+// preflight caching follow the shared CORS settings.
+func newCORS(cfg config.CORSConfig, origins, methods []string) *middleware.CORS {
+	cors, err := middleware.NewCORS(middleware.CORSConfig{
+		AllowedOrigins: origins,
+		AllowedMethods: methods,
+		AllowedHeaders: []string{
+			"Accept", "Authorization", "Content-Type", "If-None-Match", "If-Range", "Range",
+			handlers.APIKeyHeader, middleware.RequestIDHeader,
+		},
+		ExposedHeaders: []string{
+			"ETag", "Retry-After", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset",
+			"X-Bundle-Version", "X-Bundle-Last-Update", "X-Checksum-SHA256", middleware.RequestIDHeader,
+		},
+		AllowCredentials: cfg.AllowCredentials,
+		MaxAge:           cfg.MaxAge,
 	})
+	if err != nil {
+		fatal("invalid CORS configuration", err)
+	}
+	return cors
 }
@@ -0,0 +1,41 @@
+package metrics
+
+import (
+	"index-duel-backend/middleware"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// unmatchedRoute labels requests that matched no route, keeping label values bounded
+const unmatchedRoute = "unmatched"
+
+// Handler serves the registered metrics in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// Middleware records request counts, latency and response sizes, labelled by the
+// mux route template rather than the raw path
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route := unmatchedRoute
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				route = template
+			}
+		}
+
+		start := time.Now()
+		rec := middleware.NewResponseRecorder(w)
+		next.ServeHTTP(rec, r)
+
+		status := strconv.Itoa(rec.Status)
+		HTTPRequests.WithLabelValues(route, r.Method, status).Inc()
+		HTTPDuration.WithLabelValues(route, r.Method, status).Observe(time.Since(start).Seconds())
+		HTTPResponseBytes.WithLabelValues(route).Observe(float64(rec.Bytes))
+	})
+}
@@ -0,0 +1,43 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddlewareLabelsByRouteTemplate(t *testing.T) {
+	router := mux.NewRouter()
+	router.Use(Middleware)
+	router.HandleFunc("/api/v1/cards/{id:[0-9]+}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	})
+	router.NotFoundHandler = Middleware(http.NotFoundHandler())
+
+	for _, path := range []string{"/api/v1/cards/1", "/api/v1/cards/2", "/nope/1", "/nope/2"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues("/api/v1/cards/{id:[0-9]+}", "GET", "404")); got != 2 {
+		t.Errorf("requests for the card route = %v, want 2", got)
+	}
+	if got := testutil.ToFloat64(HTTPRequests.WithLabelValues(unmatchedRoute, "GET", "404")); got != 2 {
+		t.Errorf("unmatched requests = %v, want 2", got)
+	}
+}
+
+func TestStatusRecorderKeepsFlusher(t *testing.T) {
+	rec := httptest.NewRecorder()
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+	}))
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/stream", nil))
+
+	if !rec.Flushed {
+		t.Errorf("Flush did not reach the underlying writer")
+	}
+}
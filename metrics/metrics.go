@@ -0,0 +1,123 @@
+// Package metrics defines the Prometheus metrics the server exposes on /metrics
+package metrics
+
+import (
+	"database/sql"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// namespace prefixes every metric name
+const namespace = "index_duel"
+
+var (
+	// HTTPRequests counts requests by route template, method and status code
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "HTTP requests by route, method and status code.",
+	}, []string{"route", "method", "status"})
+
+	// HTTPDuration observes request latency by route template, method and status code
+	HTTPDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency by route, method and status code.",
+		Buckets:   []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60},
+	}, []string{"route", "method", "status"})
+
+	// HTTPResponseBytes observes response sizes on the wire, after compression
+	HTTPResponseBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_response_size_bytes",
+		Help:      "HTTP response body sizes as sent, by route.",
+		Buckets:   prometheus.ExponentialBuckets(256, 4, 10),
+	}, []string{"route"})
+
+	// SyncPayloadBytes observes sync response sizes before compression
+	SyncPayloadBytes = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_payload_size_bytes",
+		Help:      "Uncompressed sync response sizes by encoding and kind of sync.",
+		Buckets:   prometheus.ExponentialBuckets(1024, 4, 10),
+	}, []string{"encoding", "kind"})
+
+	// SyncCards observes how many cards each sync response carried
+	SyncCards = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "sync_cards",
+		Help:      "Cards sent per sync response by kind of sync.",
+		Buckets:   []float64{0, 1, 10, 100, 1000, 5000, 10000, 20000},
+	}, []string{"kind"})
+
+	// IngestDuration observes how long each upstream ingest run took
+	IngestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "ingest_duration_seconds",
+		Help:      "Duration of upstream catalogue ingest runs by result.",
+		Buckets:   []float64{60, 300, 600, 1800, 3600, 7200, 14400, 28800},
+	}, []string{"result"})
+
+	// IngestCardsProcessed counts cards stored by ingest runs
+	IngestCardsProcessed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_cards_processed_total",
+		Help:      "Cards stored by upstream ingest runs.",
+	})
+
+	// IngestCardsFailed counts cards ingest runs failed to store
+	IngestCardsFailed = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "ingest_cards_failed_total",
+		Help:      "Cards upstream ingest runs failed to store.",
+	})
+
+	// ImageDownloadBytes counts bytes of card images downloaded
+	ImageDownloadBytes = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_download_bytes_total",
+		Help:      "Bytes of card images downloaded during ingest.",
+	})
+
+	// ImageDownloadErrors counts card image downloads that failed
+	ImageDownloadErrors = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "image_download_errors_total",
+		Help:      "Card image downloads that failed during ingest.",
+	})
+
+	// RateLimitStoreErrors counts requests allowed because the rate limit store failed
+	RateLimitStoreErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limit_store_errors_total",
+		Help:      "Requests let through unlimited because the rate limit store failed, by budget.",
+	}, []string{"budget"})
+
+	// SchedulerLastSuccess holds the Unix time each scheduled job last succeeded
+	SchedulerLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "scheduler_last_success_timestamp_seconds",
+		Help:      "Unix time of the last successful run of each scheduled job.",
+	}, []string{"job"})
+
+	// DBReplicaLag reports the replication lag measured by the latest check of each replica
+	DBReplicaLag = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_lag_seconds",
+		Help:      "Replication lag of each read replica at its latest check.",
+	}, []string{"replica"})
+
+	// DBReplicaUsable reports whether each replica currently serves reads
+	DBReplicaUsable = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "db_replica_usable",
+		Help:      "Whether each read replica is in rotation (1) or reads fall back to the primary (0).",
+	}, []string{"replica"})
+)
+
+// RegisterDBStats exposes the connection pool statistics of a database under name
+func RegisterDBStats(db *sql.DB, name string) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db, name))
+}
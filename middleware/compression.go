@@ -0,0 +1,216 @@
+package middleware
+
+import (
+	"compress/gzip"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/zstd"
+)
+
+// minCompressSize is the smallest response, by Content-Length, worth compressing
+const minCompressSize = 512
+
+// compressor is the common surface of the gzip, Brotli and zstd writers
+type compressor interface {
+	io.Writer
+	Flush() error
+	Close() error
+	Reset(w io.Writer)
+}
+
+// supportedEncodings lists the encodings we can produce, in order of preference
+// when the client accepts several with the same quality
+var supportedEncodings = []string{"zstd", "br", "gzip"}
+
+var compressorPools = map[string]*sync.Pool{
+	"gzip": {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(nil, gzip.DefaultCompression)
+		return w
+	}},
+	"br": {New: func() interface{} {
+		return brotli.NewWriterLevel(nil, 5)
+	}},
+	"zstd": {New: func() interface{} {
+		w, _ := zstd.NewWriter(nil, zstd.WithEncoderConcurrency(1), zstd.WithEncoderLevel(zstd.SpeedDefault))
+		return w
+	}},
+}
+
+// incompressibleTypes are content type prefixes that are already compressed
+var incompressibleTypes = []string{
+	"image/",
+	"video/",
+	"audio/",
+	"font/woff",
+	"application/zip",
+	"application/gzip",
+	"application/x-gzip",
+	"application/zstd",
+	"application/x-sqlite3",
+	"application/octet-stream",
+}
+
+// Compress compresses response bodies with gzip, Brotli or zstd according to the
+// client's Accept-Encoding header. Already-compressed content is passed through, and
+// streamed responses keep working because Flush is forwarded through the encoder.
+func Compress(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Method == http.MethodHead {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cw := &compressResponseWriter{ResponseWriter: w, encoding: encoding}
+		defer cw.Close()
+
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// negotiateEncoding picks the supported encoding with the highest quality value
+func negotiateEncoding(header string) string {
+	if header == "" {
+		return ""
+	}
+
+	qualities := make(map[string]float64)
+	for _, part := range strings.Split(header, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if name == "" {
+			continue
+		}
+
+		q := 1.0
+		if value, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		qualities[name] = q
+	}
+
+	best, bestQ := "", 0.0
+	for _, encoding := range supportedEncodings {
+		q, ok := qualities[encoding]
+		if !ok {
+			q, ok = qualities["*"]
+		}
+		if ok && q > bestQ {
+			best, bestQ = encoding, q
+		}
+	}
+	return best
+}
+
+// compressResponseWriter decides on the first WriteHeader or Write whether the
+// response should be compressed, and then routes the body through the encoder
+type compressResponseWriter struct {
+	http.ResponseWriter
+	encoding    string
+	encoder     compressor
+	wroteHeader bool
+}
+
+func (cw *compressResponseWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	if cw.shouldCompress(status) {
+		h := cw.Header()
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+			h.Set("ETag", "W/"+etag)
+		}
+
+		cw.encoder = compressorPools[cw.encoding].Get().(compressor)
+		cw.encoder.Reset(cw.ResponseWriter)
+	}
+
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressResponseWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			// Sniff before compressing, as net/http would otherwise sniff the compressed bytes
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+
+	if cw.encoder != nil {
+		return cw.encoder.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush pushes compressed data buffered in the encoder through to the client
+func (cw *compressResponseWriter) Flush() {
+	if cw.encoder != nil {
+		cw.encoder.Flush()
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (cw *compressResponseWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// Close finishes the compressed stream and returns the encoder to its pool
+func (cw *compressResponseWriter) Close() error {
+	if cw.encoder == nil {
+		return nil
+	}
+
+	err := cw.encoder.Close()
+	cw.encoder.Reset(io.Discard)
+	compressorPools[cw.encoding].Put(cw.encoder)
+	cw.encoder = nil
+	return err
+}
+
+// shouldCompress reports whether a response with the given status and the headers
+// set so far should be compressed. Partial content is left alone, since its byte
+// ranges refer to the uncompressed body.
+func (cw *compressResponseWriter) shouldCompress(status int) bool {
+	switch {
+	case status < http.StatusOK, status == http.StatusNoContent, status == http.StatusPartialContent,
+		status == http.StatusNotModified:
+		return false
+	}
+
+	h := cw.Header()
+	if h.Get("Content-Encoding") != "" {
+		return false
+	}
+	if length := h.Get("Content-Length"); length != "" {
+		if n, err := strconv.Atoi(length); err == nil && n < minCompressSize {
+			return false
+		}
+	}
+
+	contentType := strings.ToLower(h.Get("Content-Type"))
+	for _, prefix := range incompressibleTypes {
+		if strings.HasPrefix(contentType, prefix) {
+			return false
+		}
+	}
+	return true
+}
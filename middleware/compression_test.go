@@ -0,0 +1,177 @@
+package middleware
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", "gzip"},
+		{"GZIP", "gzip"},
+		{"gzip, br", "br"},
+		{"gzip, br, zstd", "zstd"},
+		{"gzip;q=1.0, br;q=0.5", "gzip"},
+		{"br;q=0.2, gzip;q=0.8", "gzip"},
+		{"*", "zstd"},
+		{"*;q=0.5, gzip", "gzip"},
+		{"zstd;q=0, br;q=0, *", "gzip"},
+		{"gzip;q=0", ""},
+		{"*;q=0", ""},
+		{"identity", ""},
+		{"identity, gzip;q=0.1", "gzip"},
+		{"gzip;q=bogus", ""},
+		{"deflate, compress", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
+
+func TestCompress(t *testing.T) {
+	body := strings.Repeat(`{"id":1,"name":"Dark Magician"}`, 64)
+
+	tests := []struct {
+		name           string
+		method         string
+		acceptEncoding string
+		status         int
+		headers        map[string]string
+		wantEncoding   string
+	}{
+		{name: "JSON is compressed", acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json"}, wantEncoding: "gzip"},
+		{name: "error bodies are compressed", acceptEncoding: "gzip", status: http.StatusNotFound,
+			headers: map[string]string{"Content-Type": "application/json"}, wantEncoding: "gzip"},
+		{name: "sniffed type is compressed", acceptEncoding: "gzip", status: http.StatusOK, wantEncoding: "gzip"},
+		{name: "no Accept-Encoding", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json"}},
+		{name: "HEAD request", method: http.MethodHead, acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json"}},
+		{name: "images are already compressed", acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "image/jpeg"}},
+		{name: "bundles are already compressed", acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/x-sqlite3"}},
+		{name: "body already encoded", acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json", "Content-Encoding": "br"}, wantEncoding: "br"},
+		{name: "small body", acceptEncoding: "gzip", status: http.StatusOK,
+			headers: map[string]string{"Content-Type": "application/json", "Content-Length": "100"}},
+		{name: "no content", acceptEncoding: "gzip", status: http.StatusNoContent},
+		{name: "not modified", acceptEncoding: "gzip", status: http.StatusNotModified,
+			headers: map[string]string{"Content-Type": "application/json"}},
+		{name: "partial content", acceptEncoding: "gzip", status: http.StatusPartialContent,
+			headers: map[string]string{"Content-Type": "application/json", "Content-Range": "bytes 0-99/2048"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for name, value := range tt.headers {
+					w.Header().Set(name, value)
+				}
+				w.WriteHeader(tt.status)
+				if tt.status != http.StatusNoContent && tt.status != http.StatusNotModified {
+					io.WriteString(w, body)
+				}
+			}))
+
+			method := tt.method
+			if method == "" {
+				method = http.MethodGet
+			}
+			req := httptest.NewRequest(method, "/cards", nil)
+			if tt.acceptEncoding != "" {
+				req.Header.Set("Accept-Encoding", tt.acceptEncoding)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if vary := rec.Header().Get("Vary"); vary != "Accept-Encoding" {
+				t.Errorf("Vary = %q, want Accept-Encoding", vary)
+			}
+			if encoding := rec.Header().Get("Content-Encoding"); encoding != tt.wantEncoding {
+				t.Fatalf("Content-Encoding = %q, want %q", encoding, tt.wantEncoding)
+			}
+			if tt.wantEncoding != "gzip" {
+				return
+			}
+			if rec.Header().Get("Content-Length") != "" {
+				t.Error("Content-Length kept on a compressed response")
+			}
+			zr, err := gzip.NewReader(rec.Body)
+			if err != nil {
+				t.Fatalf("invalid gzip body: %v", err)
+			}
+			if decoded, err := io.ReadAll(zr); err != nil || string(decoded) != body {
+				t.Errorf("decoded body = %d bytes, %v; want the original %d bytes", len(decoded), err, len(body))
+			}
+		})
+	}
+}
+
+func TestCompressWeakensETag(t *testing.T) {
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Content-Length", strconv.Itoa(minCompressSize))
+		w.Write(bytes.Repeat([]byte("a"), minCompressSize))
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/cards", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if etag := rec.Header().Get("ETag"); etag != `W/"v1"` {
+		t.Errorf("ETag = %q, want the weak W/\"v1\"", etag)
+	}
+}
+
+// TestCompressFlushesStream checks that a streaming handler's Flush pushes what it
+// has written so far through the encoder, as the sync stream relies on
+func TestCompressFlushesStream(t *testing.T) {
+	const chunk = `{"cards":[{"id":1}`
+	rec := httptest.NewRecorder()
+
+	handler := Compress(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		io.WriteString(w, chunk)
+		w.(http.Flusher).Flush()
+
+		if !rec.Flushed {
+			t.Error("Flush was not passed through to the underlying writer")
+		}
+		zr, err := gzip.NewReader(bytes.NewReader(rec.Body.Bytes()))
+		if err != nil {
+			t.Fatalf("flushed bytes are not a gzip stream: %v", err)
+		}
+		got := make([]byte, len(chunk))
+		if _, err := io.ReadFull(zr, got); err != nil || string(got) != chunk {
+			t.Errorf("flushed data = %q, %v; want %q", got, err, chunk)
+		}
+
+		io.WriteString(w, `]}`)
+	}))
+
+	req := httptest.NewRequest(http.MethodPost, "/cards/sync", nil)
+	req.Header.Set("Accept-Encoding", "gzip")
+	handler.ServeHTTP(rec, req)
+
+	zr, err := gzip.NewReader(rec.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decoded, _ := io.ReadAll(zr); string(decoded) != chunk+`]}` {
+		t.Errorf("body = %q", decoded)
+	}
+}
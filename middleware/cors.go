@@ -0,0 +1,171 @@
+package middleware
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CORSConfig describes the cross-origin policy for a group of routes
+type CORSConfig struct {
+	// AllowedOrigins lists origins such as "https://app.example.com". "*" allows
+	// any origin, and a single "*" inside an origin matches one or more
+	// subdomain labels, as in "https://*.example.com".
+	AllowedOrigins   []string
+	AllowedMethods   []string
+	AllowedHeaders   []string
+	ExposedHeaders   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// CORS applies a cross-origin policy. It only sees requests whose route matched,
+// so routes must accept OPTIONS for their preflight requests to be answered.
+type CORS struct {
+	anyOrigin        bool
+	origins          map[string]bool
+	patterns         []originPattern
+	methods          map[string]bool
+	allowMethods     string
+	allowHeaders     string
+	exposeHeaders    string
+	allowCredentials bool
+	maxAge           string
+}
+
+// originPattern matches origins of the form prefix + subdomains + suffix
+type originPattern struct {
+	prefix, suffix string
+}
+
+// NewCORS validates a policy and creates its middleware. A policy without origins
+// allows no cross-origin requests.
+func NewCORS(cfg CORSConfig) (*CORS, error) {
+	c := &CORS{
+		origins:          make(map[string]bool),
+		methods:          make(map[string]bool),
+		allowMethods:     strings.Join(cfg.AllowedMethods, ", "),
+		allowHeaders:     strings.Join(cfg.AllowedHeaders, ", "),
+		exposeHeaders:    strings.Join(cfg.ExposedHeaders, ", "),
+		allowCredentials: cfg.AllowCredentials,
+	}
+	if cfg.MaxAge > 0 {
+		c.maxAge = strconv.Itoa(int(cfg.MaxAge.Seconds()))
+	}
+	for _, method := range cfg.AllowedMethods {
+		c.methods[strings.ToUpper(method)] = true
+	}
+
+	for _, origin := range cfg.AllowedOrigins {
+		origin = strings.ToLower(strings.TrimSpace(origin))
+		switch strings.Count(origin, "*") {
+		case 0:
+			c.origins[origin] = true
+		case 1:
+			if origin == "*" {
+				c.anyOrigin = true
+				continue
+			}
+			prefix, suffix, _ := strings.Cut(origin, "*")
+			if !strings.HasSuffix(prefix, "://") || !strings.HasPrefix(suffix, ".") {
+				return nil, fmt.Errorf("invalid CORS origin pattern %q, expected a form like https://*.example.com", origin)
+			}
+			c.patterns = append(c.patterns, originPattern{prefix: prefix, suffix: suffix})
+		default:
+			return nil, fmt.Errorf("invalid CORS origin pattern %q, only one * is allowed", origin)
+		}
+	}
+	return c, nil
+}
+
+// Middleware adds CORS headers for allowed origins and answers preflight requests
+func (c *CORS) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := w.Header()
+		h.Add("Vary", "Origin")
+
+		origin := r.Header.Get("Origin")
+		allowed := origin != "" && c.allowsOrigin(origin)
+		preflight := r.Method == http.MethodOptions
+
+		if allowed {
+			if c.anyOrigin && !c.allowCredentials {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.allowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+			if !preflight && c.exposeHeaders != "" {
+				h.Set("Access-Control-Expose-Headers", c.exposeHeaders)
+			}
+		}
+
+		if !preflight {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		// OPTIONS never reaches the route's handler
+		h.Add("Vary", "Access-Control-Request-Method")
+		h.Add("Vary", "Access-Control-Request-Headers")
+		requested := strings.ToUpper(r.Header.Get("Access-Control-Request-Method"))
+		if origin != "" && (!allowed || !c.methods[requested]) {
+			h.Del("Access-Control-Allow-Origin")
+			h.Del("Access-Control-Allow-Credentials")
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		if allowed {
+			h.Set("Access-Control-Allow-Methods", c.allowMethods)
+			if c.allowHeaders != "" {
+				h.Set("Access-Control-Allow-Headers", c.allowHeaders)
+			}
+			if c.maxAge != "" {
+				h.Set("Access-Control-Max-Age", c.maxAge)
+			}
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+}
+
+// allowsOrigin reports whether the policy admits an Origin header value
+func (c *CORS) allowsOrigin(origin string) bool {
+	if c.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if c.origins[origin] {
+		return true
+	}
+	for _, p := range c.patterns {
+		if !strings.HasPrefix(origin, p.prefix) || !strings.HasSuffix(origin, p.suffix) {
+			continue
+		}
+		labels := origin[len(p.prefix) : len(origin)-len(p.suffix)]
+		if labels != "" && isHostLabels(labels) {
+			return true
+		}
+	}
+	return false
+}
+
+// isHostLabels reports whether s is one or more dot-separated DNS labels, so a
+// pattern cannot be satisfied by smuggling a port, path or other host into it
+func isHostLabels(s string) bool {
+	for _, label := range strings.Split(s, ".") {
+		if label == "" {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if !(c >= 'a' && c <= 'z' || c >= '0' && c <= '9' || c == '-') {
+				return false
+			}
+		}
+	}
+	return true
+}
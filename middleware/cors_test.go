@@ -0,0 +1,147 @@
+package middleware
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+)
+
+func TestCORSAllowsOrigin(t *testing.T) {
+	cors, err := NewCORS(CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com", "https://*.indexduel.dev"},
+	})
+	if err != nil {
+		t.Fatalf("NewCORS failed: %v", err)
+	}
+
+	tests := []struct {
+		origin string
+		want   bool
+	}{
+		{"https://app.example.com", true},
+		{"HTTPS://APP.EXAMPLE.COM", true},
+		{"http://app.example.com", false},
+		{"https://evil.example.com", false},
+		{"https://beta.indexduel.dev", true},
+		{"https://a.b.indexduel.dev", true},
+		{"https://indexduel.dev", false},
+		{"https://.indexduel.dev", false},
+		{"https://evil.com/.indexduel.dev", false},
+		{"https://evil.com:443.indexduel.dev", false},
+		{"https://beta.indexduel.dev.evil.com", false},
+	}
+	for _, tt := range tests {
+		if got := cors.allowsOrigin(tt.origin); got != tt.want {
+			t.Errorf("allowsOrigin(%q) = %v, want %v", tt.origin, got, tt.want)
+		}
+	}
+}
+
+func TestNewCORSRejectsBadPatterns(t *testing.T) {
+	for _, origin := range []string{"https://*.*.example.com", "https://app*.example.com", "*.example.com"} {
+		if _, err := NewCORS(CORSConfig{AllowedOrigins: []string{origin}}); err == nil {
+			t.Errorf("NewCORS accepted origin pattern %q", origin)
+		}
+	}
+}
+
+// newCORSRouter mirrors how main wires policies: per route group, with OPTIONS
+// registered on each route
+func newCORSRouter(t *testing.T) http.Handler {
+	t.Helper()
+	public, err := NewCORS(CORSConfig{
+		AllowedOrigins: []string{"*"},
+		AllowedMethods: []string{"GET", "POST"},
+		AllowedHeaders: []string{"Content-Type", "X-API-Key"},
+		ExposedHeaders: []string{"X-Request-ID"},
+		MaxAge:         10 * time.Minute,
+	})
+	if err != nil {
+		t.Fatalf("NewCORS failed: %v", err)
+	}
+	admin, err := NewCORS(CORSConfig{
+		AllowedOrigins:   []string{"https://admin.example.com"},
+		AllowedMethods:   []string{"GET", "DELETE"},
+		AllowCredentials: true,
+	})
+	if err != nil {
+		t.Fatalf("NewCORS failed: %v", err)
+	}
+
+	ok := func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) }
+	router := mux.NewRouter()
+	publicRoutes := router.NewRoute().Subrouter()
+	publicRoutes.Use(public.Middleware)
+	publicRoutes.HandleFunc("/cards/sync", ok).Methods("POST", "OPTIONS")
+	adminRoutes := router.PathPrefix("/admin").Subrouter()
+	adminRoutes.Use(admin.Middleware)
+	adminRoutes.HandleFunc("/apikeys", ok).Methods("GET", "OPTIONS")
+	return router
+}
+
+func TestCORSPolicies(t *testing.T) {
+	router := newCORSRouter(t)
+
+	tests := []struct {
+		name        string
+		method      string
+		path        string
+		origin      string
+		preflightOf string
+		wantStatus  int
+		wantOrigin  string
+		wantCreds   string
+		wantMaxAge  string
+		wantExposed string
+	}{
+		{name: "public preflight", method: "OPTIONS", path: "/cards/sync", origin: "https://any.example",
+			preflightOf: "POST", wantStatus: http.StatusNoContent, wantOrigin: "*", wantMaxAge: "600"},
+		{name: "public request", method: "POST", path: "/cards/sync", origin: "https://any.example",
+			wantStatus: http.StatusOK, wantOrigin: "*", wantExposed: "X-Request-ID"},
+		{name: "public preflight for disallowed method", method: "OPTIONS", path: "/cards/sync", origin: "https://any.example",
+			preflightOf: "DELETE", wantStatus: http.StatusForbidden},
+		{name: "preflight for unknown route", method: "OPTIONS", path: "/nope", origin: "https://any.example",
+			preflightOf: "GET", wantStatus: http.StatusNotFound},
+		{name: "admin preflight from arbitrary origin", method: "OPTIONS", path: "/admin/apikeys", origin: "https://any.example",
+			preflightOf: "GET", wantStatus: http.StatusForbidden},
+		{name: "admin request from arbitrary origin", method: "GET", path: "/admin/apikeys", origin: "https://any.example",
+			wantStatus: http.StatusOK},
+		{name: "admin preflight from admin origin", method: "OPTIONS", path: "/admin/apikeys", origin: "https://admin.example.com",
+			preflightOf: "DELETE", wantStatus: http.StatusNoContent, wantOrigin: "https://admin.example.com", wantCreds: "true"},
+		{name: "same-origin request", method: "POST", path: "/cards/sync", wantStatus: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.path, nil)
+			if tt.origin != "" {
+				req.Header.Set("Origin", tt.origin)
+			}
+			if tt.preflightOf != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.preflightOf)
+			}
+			rec := httptest.NewRecorder()
+			router.ServeHTTP(rec, req)
+
+			h := rec.Header()
+			if rec.Code != tt.wantStatus {
+				t.Errorf("status = %d, want %d", rec.Code, tt.wantStatus)
+			}
+			if got := h.Get("Access-Control-Allow-Origin"); got != tt.wantOrigin {
+				t.Errorf("Access-Control-Allow-Origin = %q, want %q", got, tt.wantOrigin)
+			}
+			if got := h.Get("Access-Control-Allow-Credentials"); got != tt.wantCreds {
+				t.Errorf("Access-Control-Allow-Credentials = %q, want %q", got, tt.wantCreds)
+			}
+			if got := h.Get("Access-Control-Max-Age"); got != tt.wantMaxAge {
+				t.Errorf("Access-Control-Max-Age = %q, want %q", got, tt.wantMaxAge)
+			}
+			if got := h.Get("Access-Control-Expose-Headers"); got != tt.wantExposed {
+				t.Errorf("Access-Control-Expose-Headers = %q, want %q", got, tt.wantExposed)
+			}
+		})
+	}
+}
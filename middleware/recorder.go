@@ -0,0 +1,43 @@
+package middleware
+
+import "net/http"
+
+// ResponseRecorder wraps a ResponseWriter, capturing the status code and body size
+// of the response for access logs and metrics
+type ResponseRecorder struct {
+	http.ResponseWriter
+	Status      int
+	Bytes       int64
+	wroteHeader bool
+}
+
+// NewResponseRecorder wraps w. Status is 200 until the handler sets another.
+func NewResponseRecorder(w http.ResponseWriter) *ResponseRecorder {
+	return &ResponseRecorder{ResponseWriter: w, Status: http.StatusOK}
+}
+
+func (r *ResponseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.Status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *ResponseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.Bytes += int64(n)
+	return n, err
+}
+
+// Flush lets streamed responses such as sync pass through
+func (r *ResponseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *ResponseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// API key scopes
+const (
+	ScopeSyncRead   = "sync:read"
+	ScopeDecksWrite = "decks:write"
+	ScopeAdmin      = "admin"
+)
+
+// KnownScopes lists every scope a key can be granted
+var KnownScopes = []string{ScopeSyncRead, ScopeDecksWrite, ScopeAdmin}
+
+// APIKey represents an issued client key. The key itself is never stored.
+type APIKey struct {
+	ID         int64      `json:"id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"created_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	RevokedAt  *time.Time `json:"revoked_at,omitempty"`
+}
+
+// HasScope reports whether the key grants a scope. The admin scope grants every scope.
+func (k *APIKey) HasScope(scope string) bool {
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin {
+			return true
+		}
+	}
+	return false
+}
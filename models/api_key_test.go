@@ -0,0 +1,28 @@
+package models
+
+import "testing"
+
+func TestHasScope(t *testing.T) {
+	tests := []struct {
+		name   string
+		scopes []string
+		scope  string
+		want   bool
+	}{
+		{"granted", []string{ScopeSyncRead}, ScopeSyncRead, true},
+		{"one of several", []string{ScopeSyncRead, ScopeDecksWrite}, ScopeDecksWrite, true},
+		{"not granted", []string{ScopeSyncRead}, ScopeDecksWrite, false},
+		{"admin grants every scope", []string{ScopeAdmin}, ScopeDecksWrite, true},
+		{"admin only from admin", []string{ScopeSyncRead, ScopeDecksWrite}, ScopeAdmin, false},
+		{"no scopes", nil, ScopeSyncRead, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			key := &APIKey{Scopes: tt.scopes}
+			if got := key.HasScope(tt.scope); got != tt.want {
+				t.Errorf("HasScope(%q) with %v = %v, want %v", tt.scope, tt.scopes, got, tt.want)
+			}
+		})
+	}
+}
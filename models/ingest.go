@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+)
+
+// IngestCheckpoint records how far an ingest run got through an upstream catalogue
+type IngestCheckpoint struct {
+	Source          string    `db:"source"`
+	UpstreamVersion string    `db:"upstream_version"`
+	LastIndex       int       `db:"last_index"`
+	LastCardID      int64     `db:"last_card_id"`
+	Completed       bool      `db:"completed"`
+	UpdatedAt       time.Time `db:"updated_at"`
+}
+
+// Ingest run statuses
+const (
+	IngestRunning   = "running"
+	IngestSucceeded = "succeeded"
+	IngestFailed    = "failed"
+)
+
+// IngestRun records the outcome of one ingest run
+type IngestRun struct {
+	ID             int64      `db:"id"`
+	RunID          string     `db:"run_id"`
+	Source         string     `db:"source"`
+	Status         string     `db:"status"`
+	StartedAt      time.Time  `db:"started_at"`
+	FinishedAt     *time.Time `db:"finished_at"`
+	CardsProcessed int        `db:"cards_processed"`
+	CardsFailed    int        `db:"cards_failed"`
+	Error          *string    `db:"error"`
+}
@@ -0,0 +1,11 @@
+package models
+
+// CardRelations selects which related rows are loaded with each card
+type CardRelations struct {
+	Sets   bool
+	Images bool
+	Prices bool
+}
+
+// AllCardRelations loads sets, images and prices
+var AllCardRelations = CardRelations{Sets: true, Images: true, Prices: true}
@@ -1,11 +1,22 @@
 package models
 
+// SyncRequest asks for the cards changed since a previous sync. Clients should send
+// the seq returned by their last sync as SinceSeq; LastUpdate is the older,
+// timestamp-based cursor and is only used when SinceSeq is absent. It accepts RFC
+// 3339 timestamps and Unix epoch seconds or milliseconds. Leaving both cursors
+// out, null or (for LastUpdate) empty requests a first sync.
 type SyncRequest struct {
 	LastUpdate string `json:"last_update"`
+	SinceSeq   *int64 `json:"since_seq"`
 }
 
+// SyncResponse is the sync payload; handlers stream it field by field
 type SyncResponse struct {
 	Cards      []Card `json:"cards"`
 	LastUpdate string `json:"last_update"`
+	Seq        int64  `json:"seq"`
 	TotalCards int    `json:"total_cards"`
+	// FullResyncRequired is set when the client's cursor was too old to patch up,
+	// and Cards is the whole catalogue to replace its local copy with
+	FullResyncRequired bool `json:"full_resync_required"`
 }
@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// User is an account that owns decks, collections and wishlists
+type User struct {
+	ID           int64     `json:"id"`
+	Email        string    `json:"email"`
+	PasswordHash string    `json:"-"`
+	CreatedAt    time.Time `json:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at"`
+}
+
+// RefreshToken is a stored refresh token. The token itself is never stored.
+type RefreshToken struct {
+	ID        int64
+	UserID    int64
+	FamilyID  string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+	RevokedAt *time.Time
+}
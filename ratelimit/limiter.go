@@ -0,0 +1,108 @@
+// Package ratelimit implements token-bucket rate limiting with pluggable bucket
+// storage, so limits can be enforced per process or shared through Postgres.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Limit is a token bucket: Burst requests at once, refilled at Rate per second
+type Limit struct {
+	Rate  float64
+	Burst int
+}
+
+// ParseLimit parses a limit written as <count>/<s|m|h>, optionally followed by
+// :<burst>. "120/m" allows 120 requests a minute with a burst of 120, and
+// "4/h:2" allows 4 an hour but at most 2 at once.
+func ParseLimit(value string) (Limit, error) {
+	rate, burst, hasBurst := strings.Cut(strings.TrimSpace(value), ":")
+	countStr, unit, ok := strings.Cut(rate, "/")
+	if !ok {
+		return Limit{}, fmt.Errorf("invalid rate limit %q, expected <count>/<s|m|h>[:burst]", value)
+	}
+
+	count, err := strconv.Atoi(countStr)
+	if err != nil || count <= 0 {
+		return Limit{}, fmt.Errorf("invalid rate limit count %q", countStr)
+	}
+	var per time.Duration
+	switch unit {
+	case "s":
+		per = time.Second
+	case "m":
+		per = time.Minute
+	case "h":
+		per = time.Hour
+	default:
+		return Limit{}, fmt.Errorf("invalid rate limit unit %q, expected s, m or h", unit)
+	}
+
+	limit := Limit{Rate: float64(count) / per.Seconds(), Burst: count}
+	if hasBurst {
+		limit.Burst, err = strconv.Atoi(burst)
+		if err != nil || limit.Burst <= 0 {
+			return Limit{}, fmt.Errorf("invalid rate limit burst %q", burst)
+		}
+	}
+	return limit, nil
+}
+
+// String describes the limit for logs
+func (l Limit) String() string {
+	return fmt.Sprintf("%g/s, burst %d", l.Rate, l.Burst)
+}
+
+// Result is the outcome of taking a token from a bucket
+type Result struct {
+	Allowed bool
+	// Limit is the bucket size
+	Limit int
+	// Remaining is the number of whole tokens left after this request
+	Remaining int
+	// RetryAfter is how long until a request would be allowed; zero when allowed
+	RetryAfter time.Duration
+	// Reset is how long until the bucket is full again
+	Reset time.Duration
+}
+
+// Store holds token buckets by key
+type Store interface {
+	// Take removes one token from the bucket for key, creating a full bucket if
+	// there is none
+	Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error)
+}
+
+// take applies one request to a bucket that held tokens at last, returning the
+// bucket's new token count and the result
+func take(tokens float64, last time.Time, limit Limit, now time.Time) (float64, Result) {
+	burst := float64(limit.Burst)
+	if elapsed := now.Sub(last).Seconds(); elapsed > 0 {
+		tokens = math.Min(burst, tokens+elapsed*limit.Rate)
+	}
+
+	result := Result{Limit: limit.Burst}
+	if tokens >= 1 {
+		tokens--
+		result.Allowed = true
+	} else {
+		result.RetryAfter = secondsToDuration((1 - tokens) / limit.Rate)
+	}
+	result.Remaining = int(math.Floor(tokens))
+	result.Reset = secondsToDuration((burst - tokens) / limit.Rate)
+	return tokens, result
+}
+
+// fullAt returns when a bucket holding tokens will be full again
+func fullAt(tokens float64, limit Limit, now time.Time) time.Time {
+	return now.Add(secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate))
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,104 @@
+package ratelimit
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestParseLimit(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    Limit
+		wantErr bool
+	}{
+		{value: "10/s", want: Limit{Rate: 10, Burst: 10}},
+		{value: "120/m", want: Limit{Rate: 2, Burst: 120}},
+		{value: "4/h:2", want: Limit{Rate: 4.0 / 3600, Burst: 2}},
+		{value: " 60/m:5 ", want: Limit{Rate: 1, Burst: 5}},
+		{value: "10", wantErr: true},
+		{value: "0/s", wantErr: true},
+		{value: "10/d", wantErr: true},
+		{value: "10/s:0", wantErr: true},
+		{value: "ten/s", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseLimit(tt.value)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseLimit(%q) = %+v, want an error", tt.value, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("ParseLimit(%q) failed: %v", tt.value, err)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("ParseLimit(%q) = %+v, want %+v", tt.value, got, tt.want)
+		}
+	}
+}
+
+func TestMemoryStoreTokenBucket(t *testing.T) {
+	testTokenBucket(t, NewMemoryStore())
+}
+
+// testTokenBucket checks that store refills, drains and refuses like a token bucket
+// with independent keys
+func testTokenBucket(t *testing.T, store Store) {
+	t.Helper()
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 3}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	steps := []struct {
+		at         time.Duration
+		key        string
+		allowed    bool
+		remaining  int
+		retryAfter time.Duration
+	}{
+		{at: 0, key: "a", allowed: true, remaining: 2},
+		{at: 0, key: "a", allowed: true, remaining: 1},
+		{at: 0, key: "a", allowed: true, remaining: 0},
+		{at: 0, key: "a", allowed: false, remaining: 0, retryAfter: time.Second},
+		{at: 0, key: "b", allowed: true, remaining: 2},
+		{at: 500 * time.Millisecond, key: "a", allowed: false, remaining: 0, retryAfter: 500 * time.Millisecond},
+		{at: time.Second, key: "a", allowed: true, remaining: 0},
+		{at: 10 * time.Second, key: "a", allowed: true, remaining: 2},
+	}
+
+	for i, step := range steps {
+		got, err := store.Take(ctx, step.key, limit, start.Add(step.at))
+		if err != nil {
+			t.Fatalf("step %d: Take failed: %v", i, err)
+		}
+		if got.Allowed != step.allowed || got.Remaining != step.remaining || got.RetryAfter != step.retryAfter {
+			t.Errorf("step %d: got allowed=%v remaining=%d retry_after=%s, want allowed=%v remaining=%d retry_after=%s",
+				i, got.Allowed, got.Remaining, got.RetryAfter, step.allowed, step.remaining, step.retryAfter)
+		}
+		if got.Limit != limit.Burst {
+			t.Errorf("step %d: limit = %d, want %d", i, got.Limit, limit.Burst)
+		}
+	}
+}
+
+func TestMemoryStoreSweepsFullBuckets(t *testing.T) {
+	store := NewMemoryStore()
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	store.Take(ctx, "idle", limit, start)
+	store.Take(ctx, "busy", limit, start.Add(memorySweepInterval))
+	store.Take(ctx, "busy", limit, start.Add(2*memorySweepInterval))
+
+	if _, ok := store.buckets["idle"]; ok {
+		t.Errorf("refilled bucket was not swept")
+	}
+	if _, ok := store.buckets["busy"]; !ok {
+		t.Errorf("bucket in use was swept")
+	}
+}
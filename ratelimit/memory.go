@@ -0,0 +1,63 @@
+package ratelimit
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// memorySweepInterval is how often full buckets are dropped from a MemoryStore
+const memorySweepInterval = time.Minute
+
+type memoryBucket struct {
+	tokens float64
+	last   time.Time
+	fullAt time.Time
+}
+
+// MemoryStore keeps buckets in process memory. Limits are per process, so each
+// replica of the server enforces its own budget.
+type MemoryStore struct {
+	mu        sync.Mutex
+	buckets   map[string]*memoryBucket
+	lastSweep time.Time
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{buckets: make(map[string]*memoryBucket)}
+}
+
+// Take removes one token from the bucket for key
+func (s *MemoryStore) Take(_ context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	b, ok := s.buckets[key]
+	if !ok {
+		b = &memoryBucket{tokens: float64(limit.Burst), last: now}
+		s.buckets[key] = b
+	}
+
+	var result Result
+	b.tokens, result = take(b.tokens, b.last, limit, now)
+	b.last = now
+	b.fullAt = fullAt(b.tokens, limit, now)
+	return result, nil
+}
+
+// sweep drops buckets that have refilled completely, since a missing bucket is
+// treated as full anyway
+func (s *MemoryStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < memorySweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for key, b := range s.buckets {
+		if !now.Before(b.fullAt) {
+			delete(s.buckets, key)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"math"
+	"sync"
+	"time"
+)
+
+// postgresSweepInterval is how often full buckets are deleted from the table
+const postgresSweepInterval = 10 * time.Minute
+
+// PostgresStore keeps buckets in the rate_limit_buckets table, so every replica of
+// the server shares one budget per key
+type PostgresStore struct {
+	db *sql.DB
+
+	mu        sync.Mutex
+	lastSweep time.Time
+}
+
+// NewPostgresStore creates a store backed by the rate_limit_buckets table
+func NewPostgresStore(db *sql.DB) *PostgresStore {
+	return &PostgresStore{db: db}
+}
+
+// refilledTokens is the SQL for a stored bucket's tokens after refilling up to
+// now ($4) at rate ($3) per second, capped at burst ($2)
+const refilledTokens = `LEAST($2::float8, b.tokens + GREATEST(0, EXTRACT(EPOCH FROM $4::timestamptz - b.updated_at)::float8) * $3::float8)`
+
+// takeQuery takes one token from a bucket in a single statement. A new bucket
+// starts full less this request. An existing bucket is only written when it has a
+// token to give, so a refused request returns no row and leaves the bucket as is.
+// The row lock lasts for this one statement rather than a whole transaction.
+// GREATEST keeps the timestamp of a replica whose clock is ahead, so no refill is
+// counted twice.
+const takeQuery = `
+	INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at, full_at)
+	VALUES ($1, $2::float8 - 1, $4, $4 + make_interval(secs => 1 / $3::float8))
+	ON CONFLICT (key) DO UPDATE SET
+		tokens = ` + refilledTokens + ` - 1,
+		updated_at = GREATEST(b.updated_at, $4),
+		full_at = GREATEST(b.updated_at, $4) + make_interval(secs => ($2::float8 - ` + refilledTokens + ` + 1) / $3::float8)
+	WHERE ` + refilledTokens + ` >= 1
+	RETURNING tokens
+`
+
+// Take removes one token from the bucket for key. Concurrent requests for the same
+// key are applied one at a time by the row lock of a single upsert.
+func (s *PostgresStore) Take(ctx context.Context, key string, limit Limit, now time.Time) (Result, error) {
+	s.sweep(ctx, now)
+
+	var tokens float64
+	err := s.db.QueryRowContext(ctx, takeQuery, key, float64(limit.Burst), limit.Rate, now).Scan(&tokens)
+	if err == nil {
+		return Result{
+			Allowed:   true,
+			Limit:     limit.Burst,
+			Remaining: int(math.Floor(tokens)),
+			Reset:     secondsToDuration((float64(limit.Burst) - tokens) / limit.Rate),
+		}, nil
+	}
+	if err != sql.ErrNoRows {
+		return Result{}, fmt.Errorf("failed to take rate limit token: %w", err)
+	}
+
+	// Refused: read the bucket to tell the client when to retry
+	var last time.Time
+	err = s.db.QueryRowContext(ctx, `SELECT tokens, updated_at FROM rate_limit_buckets WHERE key = $1`, key).
+		Scan(&tokens, &last)
+	if err != nil {
+		return Result{}, fmt.Errorf("failed to get rate limit bucket: %w", err)
+	}
+	_, result := take(tokens, last, limit, now)
+	result.Allowed = false
+	return result, nil
+}
+
+// sweep deletes buckets that have refilled completely, at most once per interval
+func (s *PostgresStore) sweep(ctx context.Context, now time.Time) {
+	s.mu.Lock()
+	if now.Sub(s.lastSweep) < postgresSweepInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastSweep = now
+	s.mu.Unlock()
+
+	if _, err := s.db.ExecContext(ctx, `DELETE FROM rate_limit_buckets WHERE full_at < $1`, now); err != nil {
+		slog.WarnContext(ctx, "failed to delete full rate limit buckets", "error", err)
+	}
+}
@@ -0,0 +1,96 @@
+//go:build integration
+
+package ratelimit
+
+import (
+	"context"
+	"index-duel-backend/database/pgtest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m))
+}
+
+func TestPostgresStoreTokenBucket(t *testing.T) {
+	testTokenBucket(t, NewPostgresStore(pgtest.NewDB(t).DB))
+}
+
+func TestPostgresStoreConcurrentTakes(t *testing.T) {
+	store := NewPostgresStore(pgtest.NewDB(t).DB)
+	limit := Limit{Rate: 0.001, Burst: 10}
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	allowed := 0
+	for i := 0; i < 3*limit.Burst; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			result, err := store.Take(context.Background(), "shared", limit, now)
+			if err != nil {
+				t.Errorf("Take failed: %v", err)
+				return
+			}
+			if result.Allowed {
+				mu.Lock()
+				allowed++
+				mu.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+
+	if allowed != limit.Burst {
+		t.Errorf("allowed %d concurrent requests, want %d", allowed, limit.Burst)
+	}
+}
+
+func TestPostgresStoreKeepsClockAheadOfSkewedReplica(t *testing.T) {
+	store := NewPostgresStore(pgtest.NewDB(t).DB)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	store.Take(ctx, "a", limit, start.Add(time.Second))
+	store.Take(ctx, "a", limit, start)
+	// A replica a second behind must not earn the refill of that second again
+	result, err := store.Take(ctx, "a", limit, start.Add(time.Second))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Allowed {
+		t.Errorf("skewed clock refilled the bucket: %+v", result)
+	}
+}
+
+func TestPostgresStoreSweepsFullBuckets(t *testing.T) {
+	db := pgtest.NewDB(t)
+	store := NewPostgresStore(db.DB)
+	ctx := context.Background()
+	limit := Limit{Rate: 1, Burst: 2}
+	start := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+	store.Take(ctx, "idle", limit, start)
+	store.Take(ctx, "busy", limit, start.Add(postgresSweepInterval))
+	store.Take(ctx, "busy", limit, start.Add(2*postgresSweepInterval))
+
+	var keys []string
+	rows, err := db.QueryContext(ctx, `SELECT key FROM rate_limit_buckets ORDER BY key`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var key string
+		rows.Scan(&key)
+		keys = append(keys, key)
+	}
+	if len(keys) != 1 || keys[0] != "busy" {
+		t.Errorf("buckets after sweep = %v, want [busy]", keys)
+	}
+}
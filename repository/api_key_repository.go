@@ -0,0 +1,172 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"index-duel-backend/database"
+	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// APIKeyRepository stores hashed client API keys
+type APIKeyRepository struct {
+	db *database.DB
+}
+
+func NewAPIKeyRepository(db *database.DB) *APIKeyRepository {
+	return &APIKeyRepository{db: db}
+}
+
+const apiKeyColumns = "id, name, prefix, scopes, created_at, last_used_at, revoked_at"
+
+// CreateAPIKey stores a new key under its hash and fills in the generated ID and creation time
+func (r *APIKeyRepository) CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.CreateAPIKey")
+	defer tracing.End(span, &err)
+
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err = r.db.QueryRowContext(ctx, query, key.Name, key.Prefix, hash, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return nil
+}
+
+// GetAPIKeyByHash returns the unrevoked key with the given hash, or nil if there is none
+func (r *APIKeyRepository) GetAPIKeyByHash(ctx context.Context, hash []byte) (_ *models.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetAPIKeyByHash")
+	defer tracing.End(span, &err)
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1 AND revoked_at IS NULL`
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, hash))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+// GetAPIKey returns the key with the given ID, or nil if there is none
+func (r *APIKeyRepository) GetAPIKey(ctx context.Context, id int64) (_ *models.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.GetAPIKey", attribute.Int64("api_key.id", id))
+	defer tracing.End(span, &err)
+
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+	key, err := scanAPIKey(r.db.QueryRowContext(ctx, query, id))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get api key: %w", err)
+	}
+	return key, nil
+}
+
+// ListAPIKeys returns every key, including revoked ones, oldest first
+func (r *APIKeyRepository) ListAPIKeys(ctx context.Context) (_ []models.APIKey, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.ListAPIKeys")
+	defer tracing.End(span, &err)
+
+	rows, err := r.db.QueryContext(ctx, `SELECT `+apiKeyColumns+` FROM api_keys ORDER BY id`)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list api keys: %w", err)
+	}
+	defer rows.Close()
+
+	var keys []models.APIKey
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan api key: %w", err)
+		}
+		keys = append(keys, *key)
+	}
+	return keys, rows.Err()
+}
+
+// RevokeAPIKey marks a key as revoked. It reports whether an active key was revoked.
+func (r *APIKeyRepository) RevokeAPIKey(ctx context.Context, id int64) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.RevokeAPIKey", attribute.Int64("api_key.id", id))
+	defer tracing.End(span, &err)
+
+	result, err := r.db.ExecContext(ctx, `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, id)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	n, err := result.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	return n > 0, nil
+}
+
+// RotateAPIKey revokes a key and stores its replacement in one transaction
+func (r *APIKeyRepository) RotateAPIKey(ctx context.Context, oldID int64, key *models.APIKey, hash []byte) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.RotateAPIKey", attribute.Int64("api_key.id", oldID))
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE api_keys SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, oldID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke api key: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return fmt.Errorf("api key %d is not active", oldID)
+	}
+
+	query := `
+		INSERT INTO api_keys (name, prefix, key_hash, scopes)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, query, key.Name, key.Prefix, hash, pq.Array(key.Scopes)).Scan(&key.ID, &key.CreatedAt); err != nil {
+		return fmt.Errorf("failed to create api key: %w", err)
+	}
+	return tx.Commit()
+}
+
+// TouchAPIKey records that a key was used, at most once a minute per key
+func (r *APIKeyRepository) TouchAPIKey(ctx context.Context, id int64) (err error) {
+	ctx, span := startSpan(ctx, "APIKeyRepository.TouchAPIKey", attribute.Int64("api_key.id", id))
+	defer tracing.End(span, &err)
+
+	query := `
+		UPDATE api_keys SET last_used_at = CURRENT_TIMESTAMP
+		WHERE id = $1 AND (last_used_at IS NULL OR last_used_at < CURRENT_TIMESTAMP - INTERVAL '1 minute')
+	`
+	if _, err := r.db.ExecContext(ctx, query, id); err != nil {
+		return fmt.Errorf("failed to update api key usage: %w", err)
+	}
+	return nil
+}
+
+func scanAPIKey(row interface{ Scan(...interface{}) error }) (*models.APIKey, error) {
+	key := &models.APIKey{}
+	var lastUsed, revoked sql.NullTime
+	err := row.Scan(&key.ID, &key.Name, &key.Prefix, pq.Array(&key.Scopes), &key.CreatedAt, &lastUsed, &revoked)
+	if err != nil {
+		return nil, err
+	}
+	if lastUsed.Valid {
+		key.LastUsedAt = &lastUsed.Time
+	}
+	if revoked.Valid {
+		key.RevokedAt = &revoked.Time
+	}
+	return key, nil
+}
@@ -0,0 +1,101 @@
+package repository
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"time"
+)
+
+// defaultCardPageSize is how many cards an iterator loads per round trip
+const defaultCardPageSize = 500
+
+// CardPageFunc loads up to limit cards, ordered by ID, whose IDs are greater than afterID
+type CardPageFunc func(ctx context.Context, afterID int64, limit int) ([]models.Card, error)
+
+// CardIterator walks a card result set page by page, so callers can stream large
+// results while only holding one page of cards in memory
+type CardIterator struct {
+	ctx      context.Context
+	fetch    CardPageFunc
+	pageSize int
+	page     []models.Card
+	pos      int
+	afterID  int64
+	done     bool
+	err      error
+
+	// updatedThrough is the newest card timestamp visible to the snapshot read
+	updatedThrough time.Time
+}
+
+// NewCardIterator creates an iterator over the pages returned by fetch
+func NewCardIterator(ctx context.Context, fetch CardPageFunc, pageSize int) *CardIterator {
+	if pageSize <= 0 {
+		pageSize = defaultCardPageSize
+	}
+	return &CardIterator{
+		ctx:      ctx,
+		fetch:    fetch,
+		pageSize: pageSize,
+		pos:      -1,
+	}
+}
+
+// UpdatedThrough returns the newest created or updated timestamp of any card in the
+// snapshot the iterator reads, or the zero time when the snapshot held no cards.
+// Unlike the wall clock, it is a safe last_update cursor for a snapshot taken on a
+// lagging replica: cards the replica had not replayed yet are newer.
+func (it *CardIterator) UpdatedThrough() time.Time {
+	return it.updatedThrough
+}
+
+// Next advances to the next card, loading a new page when needed. It returns false
+// when the result set is exhausted or an error occurred; check Err to tell them apart.
+func (it *CardIterator) Next() bool {
+	if it.err != nil {
+		return false
+	}
+
+	it.pos++
+	if it.pos < len(it.page) {
+		return true
+	}
+	if it.done {
+		return false
+	}
+
+	page, err := it.fetch(it.ctx, it.afterID, it.pageSize)
+	if err != nil {
+		it.err = err
+		it.page = nil
+		return false
+	}
+	if len(page) < it.pageSize {
+		it.done = true
+	}
+
+	it.page = page
+	it.pos = 0
+	if len(page) == 0 {
+		return false
+	}
+	it.afterID = page[len(page)-1].ID
+	return true
+}
+
+// Card returns the current card. It is only valid after Next returned true.
+func (it *CardIterator) Card() *models.Card {
+	return &it.page[it.pos]
+}
+
+// Err returns the error that stopped the iteration, if any
+func (it *CardIterator) Err() error {
+	return it.err
+}
+
+// Close releases the current page and stops the iteration
+func (it *CardIterator) Close() error {
+	it.page = nil
+	it.done = true
+	return nil
+}
@@ -1,12 +1,27 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
 	"index-duel-backend/database"
 	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// startSpan starts a span for a repository operation, tagged as a PostgreSQL call
+func startSpan(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name, append(attrs, semconv.DBSystemNamePostgreSQL)...)
+}
+
 type CardRepository struct {
 	db *database.DB
 }
@@ -15,16 +30,25 @@ func NewCardRepository(db *database.DB) *CardRepository {
 	return &CardRepository{db: db}
 }
 
-func (r *CardRepository) CreateCard(card *models.Card) error {
-	tx, err := r.db.Begin()
+// CreateCard stores a card and replaces its related rows. Like every write it runs on
+// the primary.
+func (r *CardRepository) CreateCard(ctx context.Context, card *models.Card) (err error) {
+	ctx, span := startSpan(ctx, "CardRepository.CreateCard", attribute.Int64("card.id", card.ID))
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
 	if err != nil {
 		return fmt.Errorf("failed to begin transaction: %w", err)
 	}
 	defer tx.Rollback()
 
+	if err := lockChangeSeq(tx); err != nil {
+		return err
+	}
+
 	query := `
-		INSERT INTO cards (id, name, type, frame_type, description, atk, def, level, race, attribute)
-		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+		INSERT INTO cards (id, name, type, frame_type, description, atk, def, level, race, attribute, change_seq)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, nextval('card_change_seq'))
 		ON CONFLICT (id) DO UPDATE SET
 			name = EXCLUDED.name,
 			type = EXCLUDED.type,
@@ -35,6 +59,7 @@ func (r *CardRepository) CreateCard(card *models.Card) error {
 			level = EXCLUDED.level,
 			race = EXCLUDED.race,
 			attribute = EXCLUDED.attribute,
+			change_seq = EXCLUDED.change_seq,
 			updated_at = CURRENT_TIMESTAMP
 	`
 
@@ -69,9 +94,211 @@ func (r *CardRepository) CreateCard(card *models.Card) error {
 	return tx.Commit()
 }
 
+// CreateCards upserts a batch of cards and replaces their related rows in a single
+// transaction. Cards go through multi-row INSERTs and related rows through COPY, so a
+// batch costs a handful of statements regardless of its size.
+func (r *CardRepository) CreateCards(ctx context.Context, cards []models.Card) (err error) {
+	cards = dedupeCards(cards)
+	if len(cards) == 0 {
+		return nil
+	}
+
+	ctx, span := startSpan(ctx, "CardRepository.CreateCards", attribute.Int("cards", len(cards)))
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	if err := writeCards(tx, cards); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// writeCards upserts cards and replaces their related rows inside tx
+func writeCards(tx *sql.Tx, cards []models.Card) error {
+	if err := lockChangeSeq(tx); err != nil {
+		return err
+	}
+
+	for start := 0; start < len(cards); start += maxCardsPerInsert {
+		end := start + maxCardsPerInsert
+		if end > len(cards) {
+			end = len(cards)
+		}
+		if err := upsertCards(tx, cards[start:end]); err != nil {
+			return fmt.Errorf("failed to insert cards: %w", err)
+		}
+	}
+
+	ids := make([]int64, len(cards))
+	for i := range cards {
+		ids[i] = cards[i].ID
+	}
+	for _, table := range relatedTables {
+		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE card_id = ANY($1)", table), pq.Array(ids))
+		if err != nil {
+			return fmt.Errorf("failed to delete existing related data from %s: %w", table, err)
+		}
+	}
+
+	if err := copyRows(tx, "card_sets", cardSetColumns, cards, func(card *models.Card, add func(...interface{}) error) error {
+		for _, set := range card.CardSets {
+			if err := add(card.ID, set.SetName, set.SetCode, set.SetRarity, set.SetRarityCode, set.SetPrice); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to copy card sets: %w", err)
+	}
+
+	if err := copyRows(tx, "card_images", cardImageColumns, cards, func(card *models.Card, add func(...interface{}) error) error {
+		for _, image := range card.CardImages {
+			if err := add(card.ID, image.ImageURL, image.ImageURLSmall, image.ImageURLCropped,
+				image.ImageData, image.ImageSmallData, image.ImageCroppedData, image.ContentType, image.FileSize); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to copy card images: %w", err)
+	}
+
+	if err := copyRows(tx, "card_prices", cardPriceColumns, cards, func(card *models.Card, add func(...interface{}) error) error {
+		for _, price := range card.CardPrices {
+			if err := add(card.ID, price.CardMarketPrice, price.TCGPlayerPrice,
+				price.EbayPrice, price.AmazonPrice, price.CoolStuffIncPrice); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		return fmt.Errorf("failed to copy card prices: %w", err)
+	}
+
+	return nil
+}
+
+// changeSeqLockID is the advisory lock key that serialises card writers
+const changeSeqLockID = 7291044
+
+// lockChangeSeq makes the transaction the only card writer until it ends. Writers
+// therefore draw change sequence numbers in commit order, so a reader that has seen
+// sequence N can never later find a newly committed row numbered N or below.
+func lockChangeSeq(tx *sql.Tx) error {
+	if _, err := tx.Exec("SELECT pg_advisory_xact_lock($1)", changeSeqLockID); err != nil {
+		return fmt.Errorf("failed to acquire card write lock: %w", err)
+	}
+	return nil
+}
+
+// maxCardsPerInsert keeps multi-row card INSERTs well below the 65535 bind parameter limit
+const maxCardsPerInsert = 1000
+
+var relatedTables = []string{"card_sets", "card_images", "card_prices"}
+
+var (
+	cardSetColumns   = []string{"card_id", "set_name", "set_code", "set_rarity", "set_rarity_code", "set_price"}
+	cardImageColumns = []string{"card_id", "image_url", "image_url_small", "image_url_cropped",
+		"image_data", "image_small_data", "image_cropped_data", "content_type", "file_size"}
+	cardPriceColumns = []string{"card_id", "cardmarket_price", "tcgplayer_price", "ebay_price", "amazon_price", "coolstuffinc_price"}
+)
+
+// upsertCards writes a batch of cards with one multi-row INSERT ... ON CONFLICT statement
+func upsertCards(tx *sql.Tx, cards []models.Card) error {
+	const columns = 10
+	var values strings.Builder
+	args := make([]interface{}, 0, len(cards)*columns)
+
+	for i := range cards {
+		card := &cards[i]
+		if i > 0 {
+			values.WriteString(", ")
+		}
+		values.WriteString("(")
+		for c := 1; c <= columns; c++ {
+			if c > 1 {
+				values.WriteString(", ")
+			}
+			fmt.Fprintf(&values, "$%d", i*columns+c)
+		}
+		values.WriteString(", nextval('card_change_seq'))")
+		args = append(args, card.ID, card.Name, card.Type, card.FrameType, card.Description,
+			card.ATK, card.DEF, card.Level, card.Race, card.Attribute)
+	}
+
+	query := `
+		INSERT INTO cards (id, name, type, frame_type, description, atk, def, level, race, attribute, change_seq)
+		VALUES ` + values.String() + `
+		ON CONFLICT (id) DO UPDATE SET
+			name = EXCLUDED.name,
+			type = EXCLUDED.type,
+			frame_type = EXCLUDED.frame_type,
+			description = EXCLUDED.description,
+			atk = EXCLUDED.atk,
+			def = EXCLUDED.def,
+			level = EXCLUDED.level,
+			race = EXCLUDED.race,
+			attribute = EXCLUDED.attribute,
+			change_seq = EXCLUDED.change_seq,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err := tx.Exec(query, args...)
+	return err
+}
+
+// copyRows streams the related rows produced by rowsOf into table using COPY FROM STDIN
+func copyRows(tx *sql.Tx, table string, columns []string, cards []models.Card,
+	rowsOf func(card *models.Card, add func(...interface{}) error) error) error {
+	stmt, err := tx.Prepare(pq.CopyIn(table, columns...))
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	add := func(args ...interface{}) error {
+		_, err := stmt.Exec(args...)
+		return err
+	}
+	for i := range cards {
+		if err := rowsOf(&cards[i], add); err != nil {
+			return err
+		}
+	}
+
+	// An Exec without arguments flushes the buffered rows to the server
+	if _, err := stmt.Exec(); err != nil {
+		return err
+	}
+	return stmt.Close()
+}
+
+// dedupeCards drops earlier duplicates of a card ID, since a single upsert statement
+// cannot touch the same row twice
+func dedupeCards(cards []models.Card) []models.Card {
+	last := make(map[int64]int, len(cards))
+	for i := range cards {
+		last[cards[i].ID] = i
+	}
+	if len(last) == len(cards) {
+		return cards
+	}
+
+	unique := make([]models.Card, 0, len(last))
+	for i := range cards {
+		if last[cards[i].ID] == i {
+			unique = append(unique, cards[i])
+		}
+	}
+	return unique
+}
+
 func (r *CardRepository) deleteCardRelatedData(tx *sql.Tx, cardID int64) error {
-	tables := []string{"card_sets", "card_images", "card_prices"}
-	for _, table := range tables {
+	for _, table := range relatedTables {
 		_, err := tx.Exec(fmt.Sprintf("DELETE FROM %s WHERE card_id = $1", table), cardID)
 		if err != nil {
 			return fmt.Errorf("failed to delete from %s: %w", table, err)
@@ -110,13 +337,19 @@ func (r *CardRepository) insertCardPrice(tx *sql.Tx, cardID int64, price *models
 	return err
 }
 
-func (r *CardRepository) GetCard(cardID int64) (*models.Card, error) {
-	card := &models.Card{}
+// GetCard returns a card with the selected related rows, read from a replica when one
+// is in rotation, or nil when it does not exist
+func (r *CardRepository) GetCard(ctx context.Context, cardID int64, relations models.CardRelations) (_ *models.Card, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.GetCard", attribute.Int64("card.id", cardID))
+	defer tracing.End(span, &err)
+
+	card := models.Card{}
 	query := `
 		SELECT id, name, type, frame_type, description, atk, def, level, race, attribute, created_at, updated_at
 		FROM cards WHERE id = $1
 	`
-	err := r.db.QueryRow(query, cardID).Scan(
+	reader := r.db.Reader()
+	err = reader.QueryRowContext(ctx, query, cardID).Scan(
 		&card.ID, &card.Name, &card.Type, &card.FrameType, &card.Description,
 		&card.ATK, &card.DEF, &card.Level, &card.Race, &card.Attribute,
 		&card.CreatedAt, &card.UpdatedAt,
@@ -128,23 +361,88 @@ func (r *CardRepository) GetCard(cardID int64) (*models.Card, error) {
 		return nil, fmt.Errorf("failed to get card: %w", err)
 	}
 
-	if err := r.loadCardSets(card); err != nil {
-		return nil, fmt.Errorf("failed to load card sets: %w", err)
+	cards := []models.Card{card}
+	if err := r.loadRelatedData(ctx, reader, cards, relations); err != nil {
+		return nil, err
+	}
+
+	return &cards[0], nil
+}
+
+// queryer is satisfied by both the connection pool and a transaction
+type queryer interface {
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// queryCards runs a query selecting card columns and loads the selected related rows
+// of every returned card in bulk
+func (r *CardRepository) queryCards(ctx context.Context, q queryer, relations models.CardRelations, query string, args ...interface{}) ([]models.Card, error) {
+	rows, err := q.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var cards []models.Card
+	for rows.Next() {
+		card := models.Card{}
+		err := rows.Scan(
+			&card.ID, &card.Name, &card.Type, &card.FrameType, &card.Description,
+			&card.ATK, &card.DEF, &card.Level, &card.Race, &card.Attribute,
+			&card.CreatedAt, &card.UpdatedAt,
+		)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan card: %w", err)
+		}
+		cards = append(cards, card)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	rows.Close()
+
+	if err := r.loadRelatedData(ctx, q, cards, relations); err != nil {
+		return nil, err
 	}
-	if err := r.loadCardImages(card); err != nil {
-		return nil, fmt.Errorf("failed to load card images: %w", err)
+	return cards, nil
+}
+
+// loadRelatedData fills in the selected sets, images and prices for all cards with
+// one query per table, stitching the rows onto their cards in memory
+func (r *CardRepository) loadRelatedData(ctx context.Context, q queryer, cards []models.Card, relations models.CardRelations) error {
+	if len(cards) == 0 {
+		return nil
 	}
-	if err := r.loadCardPrices(card); err != nil {
-		return nil, fmt.Errorf("failed to load card prices: %w", err)
+
+	ids := make([]int64, len(cards))
+	index := make(map[int64]*models.Card, len(cards))
+	for i := range cards {
+		ids[i] = cards[i].ID
+		index[cards[i].ID] = &cards[i]
 	}
 
-	return card, nil
+	if relations.Sets {
+		if err := r.loadCardSets(ctx, q, ids, index); err != nil {
+			return fmt.Errorf("failed to load card sets: %w", err)
+		}
+	}
+	if relations.Images {
+		if err := r.loadCardImages(ctx, q, ids, index); err != nil {
+			return fmt.Errorf("failed to load card images: %w", err)
+		}
+	}
+	if relations.Prices {
+		if err := r.loadCardPrices(ctx, q, ids, index); err != nil {
+			return fmt.Errorf("failed to load card prices: %w", err)
+		}
+	}
+	return nil
 }
 
-func (r *CardRepository) loadCardSets(card *models.Card) error {
-	query := `SELECT id, set_name, set_code, set_rarity, set_rarity_code, set_price, created_at 
-			 FROM card_sets WHERE card_id = $1`
-	rows, err := r.db.Query(query, card.ID)
+func (r *CardRepository) loadCardSets(ctx context.Context, q queryer, ids []int64, index map[int64]*models.Card) error {
+	query := `SELECT id, card_id, set_name, set_code, set_rarity, set_rarity_code, set_price, created_at 
+			 FROM card_sets WHERE card_id = ANY($1) ORDER BY card_id, id`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
@@ -152,20 +450,22 @@ func (r *CardRepository) loadCardSets(card *models.Card) error {
 
 	for rows.Next() {
 		set := models.CardSet{}
-		err := rows.Scan(&set.ID, &set.SetName, &set.SetCode, &set.SetRarity,
+		err := rows.Scan(&set.ID, &set.CardID, &set.SetName, &set.SetCode, &set.SetRarity,
 			&set.SetRarityCode, &set.SetPrice, &set.CreatedAt)
 		if err != nil {
 			return err
 		}
-		card.CardSets = append(card.CardSets, set)
+		if card, ok := index[set.CardID]; ok {
+			card.CardSets = append(card.CardSets, set)
+		}
 	}
 	return rows.Err()
 }
 
-func (r *CardRepository) loadCardImages(card *models.Card) error {
-	query := `SELECT id, image_url, image_url_small, image_url_cropped, content_type, file_size, created_at 
-			 FROM card_images WHERE card_id = $1`
-	rows, err := r.db.Query(query, card.ID)
+func (r *CardRepository) loadCardImages(ctx context.Context, q queryer, ids []int64, index map[int64]*models.Card) error {
+	query := `SELECT id, card_id, image_url, image_url_small, image_url_cropped, content_type, file_size, created_at 
+			 FROM card_images WHERE card_id = ANY($1) ORDER BY card_id, id`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
@@ -173,20 +473,22 @@ func (r *CardRepository) loadCardImages(card *models.Card) error {
 
 	for rows.Next() {
 		image := models.CardImage{}
-		err := rows.Scan(&image.ID, &image.ImageURL, &image.ImageURLSmall, &image.ImageURLCropped,
+		err := rows.Scan(&image.ID, &image.CardID, &image.ImageURL, &image.ImageURLSmall, &image.ImageURLCropped,
 			&image.ContentType, &image.FileSize, &image.CreatedAt)
 		if err != nil {
 			return err
 		}
-		card.CardImages = append(card.CardImages, image)
+		if card, ok := index[image.CardID]; ok {
+			card.CardImages = append(card.CardImages, image)
+		}
 	}
 	return rows.Err()
 }
 
-func (r *CardRepository) loadCardPrices(card *models.Card) error {
-	query := `SELECT id, cardmarket_price, tcgplayer_price, ebay_price, amazon_price, coolstuffinc_price, created_at, updated_at 
-			 FROM card_prices WHERE card_id = $1`
-	rows, err := r.db.Query(query, card.ID)
+func (r *CardRepository) loadCardPrices(ctx context.Context, q queryer, ids []int64, index map[int64]*models.Card) error {
+	query := `SELECT id, card_id, cardmarket_price, tcgplayer_price, ebay_price, amazon_price, coolstuffinc_price, created_at, updated_at 
+			 FROM card_prices WHERE card_id = ANY($1) ORDER BY card_id, id`
+	rows, err := q.QueryContext(ctx, query, pq.Array(ids))
 	if err != nil {
 		return err
 	}
@@ -194,109 +496,190 @@ func (r *CardRepository) loadCardPrices(card *models.Card) error {
 
 	for rows.Next() {
 		price := models.CardPrice{}
-		err := rows.Scan(&price.ID, &price.CardMarketPrice, &price.TCGPlayerPrice,
+		err := rows.Scan(&price.ID, &price.CardID, &price.CardMarketPrice, &price.TCGPlayerPrice,
 			&price.EbayPrice, &price.AmazonPrice, &price.CoolStuffIncPrice, &price.CreatedAt, &price.UpdatedAt)
 		if err != nil {
 			return err
 		}
-		card.CardPrices = append(card.CardPrices, price)
+		if card, ok := index[price.CardID]; ok {
+			card.CardPrices = append(card.CardPrices, price)
+		}
 	}
 	return rows.Err()
 }
 
 // GetCardCount returns the total number of cards
-func (r *CardRepository) GetCardCount() (int, error) {
+func (r *CardRepository) GetCardCount(ctx context.Context) (_ int, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.GetCardCount")
+	defer tracing.End(span, &err)
+
 	var count int
-	err := r.db.QueryRow("SELECT COUNT(*) FROM cards").Scan(&count)
+	err = r.db.Reader().QueryRowContext(ctx, "SELECT COUNT(*) FROM cards").Scan(&count)
 	if err != nil {
 		return 0, fmt.Errorf("failed to get card count: %w", err)
 	}
 	return count, nil
 }
 
-// GetCardsUpdatedAfter retrieves cards updated after the given timestamp
-func (r *CardRepository) GetCardsUpdatedAfter(lastUpdate string) ([]models.Card, error) {
-	query := `
-		SELECT id, name, type, frame_type, description, atk, def, level, race, attribute, created_at, updated_at
-		FROM cards 
-		WHERE updated_at > $1 OR created_at > $1
-		ORDER BY updated_at DESC
-	`
+// HasCards reports whether the catalogue holds at least one card, without counting them
+func (r *CardRepository) HasCards(ctx context.Context) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.HasCards")
+	defer tracing.End(span, &err)
+
+	var exists bool
+	if err := r.db.Reader().QueryRowContext(ctx, "SELECT EXISTS (SELECT 1 FROM cards)").Scan(&exists); err != nil {
+		return false, fmt.Errorf("failed to check for cards: %w", err)
+	}
+	return exists, nil
+}
+
+// GetCardsUpdatedAfter returns an iterator over cards updated after the given
+// timestamp, along with the change sequence the result is consistent with
+func (r *CardRepository) GetCardsUpdatedAfter(ctx context.Context, lastUpdate time.Time, relations models.CardRelations) (*CardIterator, int64, error) {
+	return r.snapshotCards(ctx, relations, "updated_at > $1 OR created_at > $1", lastUpdate)
+}
+
+// GetCardsChangedSince returns an iterator over cards whose change sequence is greater
+// than sinceSeq, along with the sequence the client should send on its next sync
+func (r *CardRepository) GetCardsChangedSince(ctx context.Context, sinceSeq int64, relations models.CardRelations) (*CardIterator, int64, error) {
+	return r.snapshotCards(ctx, relations, "change_seq > $1", sinceSeq)
+}
+
+// GetAllCardsForFirstSync returns an iterator over all cards for new clients, along
+// with the change sequence the result is consistent with
+func (r *CardRepository) GetAllCardsForFirstSync(ctx context.Context, relations models.CardRelations) (*CardIterator, int64, error) {
+	return r.snapshotCards(ctx, relations, "")
+}
+
+// snapshotCards reads the highest change sequence and the IDs of the cards matching
+// filter from one read-only REPEATABLE READ snapshot, then returns an iterator that
+// loads those cards a page at a time. The snapshot ends before the iterator is
+// returned, so a slow client holds neither a pool connection nor back vacuum for the
+// length of its download. A card written after the snapshot is sent at its newer
+// version, which is harmless: its change sequence is above the one returned, so the
+// next sync sends it again.
+//
+// The snapshot is taken on a replica when one is in rotation, so the sequence and
+// the iterator's UpdatedThrough may trail the primary by up to the allowed lag; both
+// are cursors the client can resume from. Every page is read from the same pool as
+// the snapshot, since another replica may not have the cards it listed yet. Each
+// transaction is short, so none lasts long enough to be cancelled by a replication
+// conflict on a hot standby.
+func (r *CardRepository) snapshotCards(ctx context.Context, relations models.CardRelations, filter string, args ...interface{}) (_ *CardIterator, _ int64, err error) {
+	spanCtx, span := startSpan(ctx, "CardRepository.snapshotCards")
+	defer tracing.End(span, &err)
+
+	reader := r.db.Reader()
+	tx, err := reader.BeginTx(spanCtx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to begin snapshot: %w", err)
+	}
+	defer tx.Rollback()
+
+	var seq int64
+	var updatedThrough sql.NullTime
+	err = tx.QueryRowContext(spanCtx, "SELECT COALESCE(MAX(change_seq), 0), MAX(GREATEST(created_at, updated_at)) FROM cards").
+		Scan(&seq, &updatedThrough)
+	if err != nil {
+		return nil, 0, fmt.Errorf("failed to get change sequence: %w", err)
+	}
+	ids, err := matchingCardIDs(spanCtx, tx, filter, args...)
+	if err != nil {
+		return nil, 0, err
+	}
+	span.SetAttributes(attribute.Int("cards", len(ids)))
+
+	it := NewCardIterator(ctx, r.cardPages(reader, ids, relations), defaultCardPageSize)
+	it.updatedThrough = updatedThrough.Time
+	return it, seq, nil
+}
 
-	rows, err := r.db.Query(query, lastUpdate)
+// matchingCardIDs returns the IDs of the cards matching filter in ascending order. The
+// filter is a WHERE condition whose placeholders start at $1.
+func matchingCardIDs(ctx context.Context, q queryer, filter string, args ...interface{}) ([]int64, error) {
+	query := "SELECT id FROM cards"
+	if filter != "" {
+		query += " WHERE " + filter
+	}
+	rows, err := q.QueryContext(ctx, query+" ORDER BY id", args...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get updated cards: %w", err)
+		return nil, fmt.Errorf("failed to list cards: %w", err)
 	}
 	defer rows.Close()
 
-	var cards []models.Card
+	var ids []int64
 	for rows.Next() {
-		card := models.Card{}
-		err := rows.Scan(
-			&card.ID, &card.Name, &card.Type, &card.FrameType, &card.Description,
-			&card.ATK, &card.DEF, &card.Level, &card.Race, &card.Attribute,
-			&card.CreatedAt, &card.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan card: %w", err)
+		var id int64
+		if err := rows.Scan(&id); err != nil {
+			return nil, fmt.Errorf("failed to scan card id: %w", err)
 		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
 
-		// Load related data for each card
-		if err := r.loadCardSets(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card sets: %w", err)
-		}
-		if err := r.loadCardImages(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card images: %w", err)
+// cardPages builds a page loader over ids, which must be in ascending order. Each
+// page is read in its own short snapshot so a card and its related rows agree.
+func (r *CardRepository) cardPages(db *sql.DB, ids []int64, relations models.CardRelations) CardPageFunc {
+	query := `
+		SELECT id, name, type, frame_type, description, atk, def, level, race, attribute, created_at, updated_at
+		FROM cards
+		WHERE id = ANY($1)
+		ORDER BY id
+	`
+
+	return func(ctx context.Context, afterID int64, limit int) (_ []models.Card, err error) {
+		start := sort.Search(len(ids), func(i int) bool { return ids[i] > afterID })
+		end := min(start+limit, len(ids))
+		if start == end {
+			return nil, nil
 		}
-		if err := r.loadCardPrices(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card prices: %w", err)
+
+		ctx, span := startSpan(ctx, "CardRepository.cardPage", attribute.Int64("after_id", afterID), attribute.Int("limit", limit))
+		defer tracing.End(span, &err)
+
+		tx, err := db.BeginTx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+		if err != nil {
+			return nil, fmt.Errorf("failed to begin page snapshot: %w", err)
 		}
+		defer tx.Rollback()
 
-		cards = append(cards, card)
+		cards, err := r.queryCards(ctx, tx, relations, query, pq.Array(ids[start:end]))
+		if err != nil {
+			return nil, fmt.Errorf("failed to get cards: %w", err)
+		}
+		span.SetAttributes(attribute.Int("cards", len(cards)))
+		return cards, nil
 	}
-
-	return cards, rows.Err()
 }
 
-// GetAllCardsForFirstSync retrieves all cards for new clients
-func (r *CardRepository) GetAllCardsForFirstSync() ([]models.Card, error) {
+// ForEachSmallImage streams the stored small image data of every card image to fn
+func (r *CardRepository) ForEachSmallImage(ctx context.Context, fn func(imageID int, cardID int64, data []byte) error) (err error) {
+	ctx, span := startSpan(ctx, "CardRepository.ForEachSmallImage")
+	defer tracing.End(span, &err)
+
 	query := `
-		SELECT id, name, type, frame_type, description, atk, def, level, race, attribute, created_at, updated_at
-		FROM cards 
-		ORDER BY id
+		SELECT id, card_id, image_small_data
+		FROM card_images
+		WHERE image_small_data IS NOT NULL
+		ORDER BY card_id, id
 	`
-
-	rows, err := r.db.Query(query)
+	rows, err := r.db.Reader().QueryContext(ctx, query)
 	if err != nil {
-		return nil, fmt.Errorf("failed to get all cards: %w", err)
+		return fmt.Errorf("failed to get small images: %w", err)
 	}
 	defer rows.Close()
 
-	var cards []models.Card
 	for rows.Next() {
-		card := models.Card{}
-		err := rows.Scan(
-			&card.ID, &card.Name, &card.Type, &card.FrameType, &card.Description,
-			&card.ATK, &card.DEF, &card.Level, &card.Race, &card.Attribute,
-			&card.CreatedAt, &card.UpdatedAt,
-		)
-		if err != nil {
-			return nil, fmt.Errorf("failed to scan card: %w", err)
-		}
-
-		// Load related data for each card
-		if err := r.loadCardSets(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card sets: %w", err)
+		var imageID int
+		var cardID int64
+		var data []byte
+		if err := rows.Scan(&imageID, &cardID, &data); err != nil {
+			return fmt.Errorf("failed to scan small image: %w", err)
 		}
-		if err := r.loadCardImages(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card images: %w", err)
-		}
-		if err := r.loadCardPrices(&card); err != nil {
-			return nil, fmt.Errorf("failed to load card prices: %w", err)
+		if err := fn(imageID, cardID, data); err != nil {
+			return err
 		}
-
-		cards = append(cards, card)
 	}
-
-	return cards, rows.Err()
+	return rows.Err()
 }
@@ -0,0 +1,81 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/database/pgtest"
+	"index-duel-backend/models"
+	"testing"
+)
+
+// benchBatchSize is the number of cards written per benchmark iteration
+const benchBatchSize = 100
+
+// benchCards builds a batch of cards shaped like the upstream catalogue
+func benchCards(n int) []models.Card {
+	price := "1.23"
+	level := 4
+	size := 2048
+	image := make([]byte, size)
+
+	cards := make([]models.Card, n)
+	for i := range cards {
+		id := int64(10000000 + i)
+		cards[i] = models.Card{
+			ID:          id,
+			Name:        fmt.Sprintf("Bench Card %d", i),
+			Type:        "Effect Monster",
+			FrameType:   "effect",
+			Description: "A card used to benchmark repository writes.",
+			Level:       &level,
+			Race:        "Warrior",
+			Attribute:   "EARTH",
+			CardSets: []models.CardSet{
+				{SetName: "Bench Set", SetCode: fmt.Sprintf("BNCH-%04d", i), SetRarity: "Common", SetRarityCode: "(C)", SetPrice: &price},
+				{SetName: "Bench Set 2", SetCode: fmt.Sprintf("BNC2-%04d", i), SetRarity: "Rare", SetRarityCode: "(R)", SetPrice: &price},
+				{SetName: "Bench Set 3", SetCode: fmt.Sprintf("BNC3-%04d", i), SetRarity: "Super Rare", SetRarityCode: "(SR)", SetPrice: &price},
+			},
+			CardImages: []models.CardImage{
+				{
+					ImageURL:      fmt.Sprintf("https://images.example.com/%d.jpg", id),
+					ImageURLSmall: fmt.Sprintf("https://images.example.com/small/%d.jpg", id),
+					ImageData:     image,
+					ContentType:   "image/jpeg",
+					FileSize:      &size,
+				},
+			},
+			CardPrices: []models.CardPrice{
+				{CardMarketPrice: &price, TCGPlayerPrice: &price, EbayPrice: &price, AmazonPrice: &price, CoolStuffIncPrice: &price},
+			},
+		}
+	}
+	return cards
+}
+
+func BenchmarkCreateCard(b *testing.B) {
+	repo := NewCardRepository(pgtest.NewDB(b))
+	cards := benchCards(benchBatchSize)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		for i := range cards {
+			if err := repo.CreateCard(context.Background(), &cards[i]); err != nil {
+				b.Fatalf("CreateCard failed: %v", err)
+			}
+		}
+	}
+}
+
+func BenchmarkCreateCards(b *testing.B) {
+	repo := NewCardRepository(pgtest.NewDB(b))
+	cards := benchCards(benchBatchSize)
+
+	b.ResetTimer()
+	for n := 0; n < b.N; n++ {
+		if err := repo.CreateCards(context.Background(), cards); err != nil {
+			b.Fatalf("CreateCards failed: %v", err)
+		}
+	}
+}
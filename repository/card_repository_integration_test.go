@@ -0,0 +1,251 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/database"
+	"index-duel-backend/database/pgtest"
+	"index-duel-backend/models"
+	"os"
+	"sort"
+	"testing"
+	"time"
+)
+
+// The tests in this file run against a disposable Postgres started from local
+// binaries: go test -tags integration ./repository/
+func TestMain(m *testing.M) {
+	os.Exit(pgtest.Run(m))
+}
+
+// cardWriters are the two write paths, which must store cards identically
+var cardWriters = []struct {
+	name  string
+	write func(ctx context.Context, repo *CardRepository, cards []models.Card) error
+}{
+	{"CreateCard", func(ctx context.Context, repo *CardRepository, cards []models.Card) error {
+		for i := range cards {
+			if err := repo.CreateCard(ctx, &cards[i]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}},
+	{"CreateCards", func(ctx context.Context, repo *CardRepository, cards []models.Card) error {
+		return repo.CreateCards(ctx, cards)
+	}},
+}
+
+func getCard(t *testing.T, repo *CardRepository, id int64) *models.Card {
+	t.Helper()
+	card, err := repo.GetCard(context.Background(), id, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("GetCard(%d) failed: %v", id, err)
+	}
+	if card == nil {
+		t.Fatalf("card %d not found", id)
+	}
+	return card
+}
+
+func changeSeqOf(t *testing.T, db *database.DB, id int64) int64 {
+	t.Helper()
+	var seq int64
+	if err := db.QueryRow("SELECT change_seq FROM cards WHERE id = $1", id).Scan(&seq); err != nil {
+		t.Fatalf("failed to read change_seq of card %d: %v", id, err)
+	}
+	return seq
+}
+
+func setCodes(card *models.Card) []string {
+	codes := make([]string, len(card.CardSets))
+	for i, set := range card.CardSets {
+		codes[i] = set.SetCode
+	}
+	return codes
+}
+
+func TestMigrationsAreIdempotent(t *testing.T) {
+	db := pgtest.NewDB(t)
+
+	if err := db.Migrate(); err != nil {
+		t.Fatalf("migrating an up-to-date database failed: %v", err)
+	}
+	missing, err := db.MissingTables(context.Background(),
+		[]string{"cards", "card_sets", "card_images", "card_prices", "ingest_checkpoints", "ingest_runs"})
+	if err != nil || len(missing) > 0 {
+		t.Errorf("missing tables = %v, %v", missing, err)
+	}
+}
+
+func TestCreateCardUpsert(t *testing.T) {
+	for _, writer := range cardWriters {
+		t.Run(writer.name, func(t *testing.T) {
+			db := pgtest.NewDB(t)
+			repo := NewCardRepository(db)
+			ctx := context.Background()
+
+			atk := 1800
+			original := seqTestCard(1, "Original")
+			original.ATK = &atk
+			if err := writer.write(ctx, repo, []models.Card{original}); err != nil {
+				t.Fatalf("first write failed: %v", err)
+			}
+			firstSeq := changeSeqOf(t, db, 1)
+
+			// Backdate the row so that a refreshed updated_at is unambiguous
+			if _, err := db.Exec(`UPDATE cards SET created_at = created_at - interval '1 hour',
+				updated_at = updated_at - interval '1 hour' WHERE id = 1`); err != nil {
+				t.Fatal(err)
+			}
+			before := getCard(t, repo, 1)
+
+			updated := seqTestCard(1, "Updated")
+			updated.Description = "Rewritten text"
+			if err := writer.write(ctx, repo, []models.Card{updated}); err != nil {
+				t.Fatalf("second write failed: %v", err)
+			}
+			after := getCard(t, repo, 1)
+
+			if after.Name != "Updated" || after.Description != "Rewritten text" || after.ATK != nil {
+				t.Errorf("card = %q, %q, atk %v; want every column replaced", after.Name, after.Description, after.ATK)
+			}
+			if !after.CreatedAt.Equal(before.CreatedAt) {
+				t.Errorf("created_at changed from %v to %v", before.CreatedAt, after.CreatedAt)
+			}
+			if !after.UpdatedAt.After(before.UpdatedAt) {
+				t.Errorf("updated_at = %v, want later than %v", after.UpdatedAt, before.UpdatedAt)
+			}
+			if seq := changeSeqOf(t, db, 1); seq <= firstSeq {
+				t.Errorf("change_seq = %d, want greater than %d", seq, firstSeq)
+			}
+
+			count, err := repo.GetCardCount(ctx)
+			if err != nil || count != 1 {
+				t.Errorf("GetCardCount = %d, %v; want 1", count, err)
+			}
+		})
+	}
+}
+
+func TestCreateCardReplacesRelatedRows(t *testing.T) {
+	price := "0.50"
+	for _, writer := range cardWriters {
+		t.Run(writer.name, func(t *testing.T) {
+			db := pgtest.NewDB(t)
+			repo := NewCardRepository(db)
+			ctx := context.Background()
+
+			card := seqTestCard(1, "Replaced")
+			card.CardSets = []models.CardSet{{SetCode: "OLD-001"}, {SetCode: "OLD-002"}}
+			card.CardImages = []models.CardImage{{ImageURL: "https://images.example.com/1.jpg", ImageData: []byte("jpeg")}}
+			card.CardPrices = []models.CardPrice{{CardMarketPrice: &price}}
+			neighbour := seqTestCard(2, "Untouched")
+			neighbour.CardSets = []models.CardSet{{SetCode: "KEEP-001"}}
+			if err := writer.write(ctx, repo, []models.Card{card, neighbour}); err != nil {
+				t.Fatalf("first write failed: %v", err)
+			}
+
+			card.CardSets = []models.CardSet{{SetCode: "NEW-001"}}
+			card.CardImages = nil
+			card.CardPrices = []models.CardPrice{{CardMarketPrice: &price}, {TCGPlayerPrice: &price}}
+			if err := writer.write(ctx, repo, []models.Card{card}); err != nil {
+				t.Fatalf("second write failed: %v", err)
+			}
+
+			got := getCard(t, repo, 1)
+			if codes := setCodes(got); len(codes) != 1 || codes[0] != "NEW-001" {
+				t.Errorf("sets = %v, want [NEW-001]", codes)
+			}
+			if len(got.CardImages) != 0 {
+				t.Errorf("images = %d, want the old image removed", len(got.CardImages))
+			}
+			if len(got.CardPrices) != 2 {
+				t.Errorf("prices = %d, want 2", len(got.CardPrices))
+			}
+			if codes := setCodes(getCard(t, repo, 2)); len(codes) != 1 || codes[0] != "KEEP-001" {
+				t.Errorf("neighbour sets = %v, want [KEEP-001]", codes)
+			}
+
+			var orphans int
+			if err := db.QueryRow("SELECT COUNT(*) FROM card_sets WHERE set_code LIKE 'OLD-%'").Scan(&orphans); err != nil || orphans != 0 {
+				t.Errorf("%d old set rows remain (%v)", orphans, err)
+			}
+		})
+	}
+}
+
+func TestCreateCardsKeepsLastDuplicate(t *testing.T) {
+	repo := NewCardRepository(pgtest.NewDB(t))
+
+	first := seqTestCard(1, "First")
+	first.CardSets = []models.CardSet{{SetCode: "FIRST-001"}}
+	last := seqTestCard(1, "Last")
+	last.CardSets = []models.CardSet{{SetCode: "LAST-001"}}
+	if err := repo.CreateCards(context.Background(), []models.Card{first, last}); err != nil {
+		t.Fatalf("CreateCards failed: %v", err)
+	}
+
+	got := getCard(t, repo, 1)
+	if codes := setCodes(got); got.Name != "Last" || len(codes) != 1 || codes[0] != "LAST-001" {
+		t.Errorf("card = %q with sets %v, want Last with [LAST-001]", got.Name, codes)
+	}
+}
+
+func TestGetCardsUpdatedAfterBoundaries(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewCardRepository(db)
+	ctx := context.Background()
+
+	cursor := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	hour, micro := time.Hour, time.Microsecond
+	timestamps := []struct {
+		id               int64
+		created, updated time.Time
+	}{
+		{1, cursor.Add(-hour), cursor.Add(-hour)},
+		{2, cursor.Add(-hour), cursor},
+		{3, cursor.Add(-hour), cursor.Add(micro)},
+		{4, cursor.Add(micro), cursor.Add(-hour)},
+		{5, cursor.Add(hour), cursor.Add(hour)},
+	}
+	for _, ts := range timestamps {
+		if err := repo.CreateCard(ctx, &models.Card{ID: ts.id, Name: fmt.Sprintf("Card %d", ts.id)}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := db.Exec("UPDATE cards SET created_at = $2, updated_at = $3 WHERE id = $1", ts.id, ts.created, ts.updated); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		after   time.Time
+		wantIDs []int64
+	}{
+		{"zero time returns everything", time.Time{}, []int64{1, 2, 3, 4, 5}},
+		{"update exactly at the cursor is excluded", cursor, []int64{3, 4, 5}},
+		{"one microsecond earlier includes it", cursor.Add(-micro), []int64{2, 3, 4, 5}},
+		{"cursor in another time zone", cursor.In(time.FixedZone("JST", 9*60*60)), []int64{3, 4, 5}},
+		{"latest update is excluded", cursor.Add(hour), nil},
+		{"cursor in the future", cursor.Add(24 * hour), nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cards, _, err := repo.GetCardsUpdatedAfter(ctx, tt.after, models.CardRelations{})
+			if err != nil {
+				t.Fatalf("GetCardsUpdatedAfter failed: %v", err)
+			}
+			var ids []int64
+			for id := range drainSync(t, cards) {
+				ids = append(ids, id)
+			}
+			sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+			if fmt.Sprint(ids) != fmt.Sprint(tt.wantIDs) {
+				t.Errorf("cards = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
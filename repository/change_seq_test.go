@@ -0,0 +1,245 @@
+//go:build integration
+
+package repository
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/database/pgtest"
+	"index-duel-backend/models"
+	"sync"
+	"testing"
+	"time"
+)
+
+// drainSync reads every card of a sync result and returns them by ID
+func drainSync(t *testing.T, cards *CardIterator) map[int64]models.Card {
+	t.Helper()
+	defer cards.Close()
+
+	seen := make(map[int64]models.Card)
+	for cards.Next() {
+		card := cards.Card()
+		seen[card.ID] = *card
+	}
+	if err := cards.Err(); err != nil {
+		t.Fatalf("sync iteration failed: %v", err)
+	}
+	return seen
+}
+
+func seqTestCard(id int64, name string) models.Card {
+	return models.Card{ID: id, Name: name, Type: "Spell Card", FrameType: "spell"}
+}
+
+// TestSyncDoesNotMissWriteCommittedDuringSync reproduces the race the timestamp
+// cursor suffers from: a write that started before a sync but commits after it.
+// Its updated_at is the transaction start time, which is older than the cursor the
+// sync hands out, so a timestamp-based client would never see it. The change
+// sequence cursor must deliver it on the next sync.
+func TestSyncDoesNotMissWriteCommittedDuringSync(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewCardRepository(db)
+	ctx := context.Background()
+
+	if err := repo.CreateCards(context.Background(), []models.Card{seqTestCard(1, "Committed Before Sync")}); err != nil {
+		t.Fatalf("CreateCards failed: %v", err)
+	}
+
+	// Start a write and leave it uncommitted while the client syncs
+	tx, err := db.Begin()
+	if err != nil {
+		t.Fatalf("failed to begin writer transaction: %v", err)
+	}
+	defer tx.Rollback()
+	if err := writeCards(tx, []models.Card{seqTestCard(2, "Committed During Sync")}); err != nil {
+		t.Fatalf("writeCards failed: %v", err)
+	}
+
+	cursorTime := time.Now().UTC()
+	cards, seq, err := repo.GetCardsChangedSince(ctx, 0, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("first sync failed: %v", err)
+	}
+	first := drainSync(t, cards)
+	if _, ok := first[1]; !ok {
+		t.Fatalf("first sync is missing card 1")
+	}
+	if _, ok := first[2]; ok {
+		t.Fatalf("first sync returned uncommitted card 2")
+	}
+
+	if err := tx.Commit(); err != nil {
+		t.Fatalf("failed to commit writer transaction: %v", err)
+	}
+
+	cards, nextSeq, err := repo.GetCardsChangedSince(ctx, seq, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("second sync failed: %v", err)
+	}
+	second := drainSync(t, cards)
+	if _, ok := second[2]; !ok {
+		t.Fatalf("second sync since seq %d is missing card 2 committed during the first sync", seq)
+	}
+	if _, ok := second[1]; ok {
+		t.Errorf("second sync returned card 1 again")
+	}
+	if nextSeq <= seq {
+		t.Errorf("seq did not advance: got %d after %d", nextSeq, seq)
+	}
+
+	// The same sequence of events through the timestamp cursor loses the card, since
+	// its updated_at is the writer's transaction start, before the cursor was taken
+	cards, _, err = repo.GetCardsUpdatedAfter(ctx, cursorTime, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("timestamp sync failed: %v", err)
+	}
+	if _, ok := drainSync(t, cards)[2]; ok {
+		t.Errorf("timestamp cursor %s returned card 2; the race it suffers from no longer reproduces",
+			cursorTime.Format(time.RFC3339Nano))
+	}
+}
+
+// TestSyncIteratorHoldsNoConnection checks that a sync a client is still reading
+// does not keep a pool connection, and with it a transaction, open between pages
+func TestSyncIteratorHoldsNoConnection(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewCardRepository(db)
+	ctx := context.Background()
+
+	batch := make([]models.Card, defaultCardPageSize+1)
+	for i := range batch {
+		batch[i] = seqTestCard(int64(i+1), "Card")
+	}
+	if err := repo.CreateCards(ctx, batch); err != nil {
+		t.Fatalf("CreateCards failed: %v", err)
+	}
+
+	cards, _, err := repo.GetAllCardsForFirstSync(ctx, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("sync failed: %v", err)
+	}
+	defer cards.Close()
+	if !cards.Next() {
+		t.Fatalf("sync returned no cards: %v", cards.Err())
+	}
+	if inUse := db.Stats().InUse; inUse != 0 {
+		t.Errorf("%d connections in use while the client reads the first page", inUse)
+	}
+
+	seen := 1
+	for cards.Next() {
+		seen++
+	}
+	if err := cards.Err(); err != nil || seen != len(batch) {
+		t.Errorf("read %d cards, %v; want %d", seen, err, len(batch))
+	}
+}
+
+// TestSyncWithConcurrentIngests keeps syncing while several writers upsert
+// overlapping batches, over both write paths and in opposite card orders. The
+// client must end up with every card at its final version without any sync
+// skipping a change, and every card must keep the related rows of the same write
+// as its card row.
+func TestSyncWithConcurrentIngests(t *testing.T) {
+	db := pgtest.NewDB(t)
+	repo := NewCardRepository(db)
+	ctx := context.Background()
+
+	const (
+		writers = 4
+		rounds  = 20
+		cardsN  = 50
+	)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers)
+	for w := 0; w < writers; w++ {
+		writer := cardWriters[w%len(cardWriters)]
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for round := 0; round < rounds; round++ {
+				batch := make([]models.Card, 0, cardsN/2)
+				for i := (w + round) % 2; i < cardsN; i += 2 {
+					id := int64(i + 1)
+					if w/2%2 == 1 {
+						id = int64(cardsN - i)
+					}
+					card := seqTestCard(id, fmt.Sprintf("writer %d round %d", w, round))
+					card.CardSets = []models.CardSet{{SetCode: fmt.Sprintf("W%d-R%d-%03d", w, round, id)}}
+					card.CardPrices = []models.CardPrice{{}}
+					batch = append(batch, card)
+				}
+				if err := writer.write(context.Background(), repo, batch); err != nil {
+					errs <- fmt.Errorf("%s: %w", writer.name, err)
+					return
+				}
+			}
+		}(w)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	client := make(map[int64]models.Card)
+	var seq int64
+	syncOnce := func() {
+		cards, next, err := repo.GetCardsChangedSince(ctx, seq, models.AllCardRelations)
+		if err != nil {
+			t.Fatalf("sync failed: %v", err)
+		}
+		for id, card := range drainSync(t, cards) {
+			client[id] = card
+		}
+		if next < seq {
+			t.Fatalf("seq went backwards: %d after %d", next, seq)
+		}
+		seq = next
+	}
+
+	for running := true; running; {
+		select {
+		case <-done:
+			running = false
+		default:
+			syncOnce()
+		}
+	}
+	close(errs)
+	for err := range errs {
+		t.Fatalf("concurrent ingest failed: %v", err)
+	}
+	syncOnce()
+
+	all, _, err := repo.GetAllCardsForFirstSync(ctx, models.AllCardRelations)
+	if err != nil {
+		t.Fatalf("full sync failed: %v", err)
+	}
+	server := drainSync(t, all)
+	if len(client) != len(server) {
+		t.Fatalf("client has %d cards, server has %d", len(client), len(server))
+	}
+	for id, card := range server {
+		if client[id].Name != card.Name {
+			t.Errorf("card %d: client has %q, server has %q", id, client[id].Name, card.Name)
+		}
+		var w, round int
+		fmt.Sscanf(card.Name, "writer %d round %d", &w, &round)
+		want := fmt.Sprintf("W%d-R%d-%03d", w, round, id)
+		if codes := setCodes(&card); len(codes) != 1 || codes[0] != want || len(card.CardPrices) != 1 {
+			t.Errorf("card %d from %q has sets %v and %d prices, want [%s] and 1", id, card.Name, codes, len(card.CardPrices), want)
+		}
+	}
+
+	var distinct int
+	if err := db.QueryRow("SELECT COUNT(DISTINCT change_seq) FROM cards").Scan(&distinct); err != nil {
+		t.Fatal(err)
+	}
+	if distinct != len(server) {
+		t.Errorf("%d distinct change sequences for %d cards", distinct, len(server))
+	}
+}
@@ -0,0 +1,53 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+)
+
+// GetIngestCheckpoint returns the stored checkpoint for a source, or nil if there is none
+func (r *CardRepository) GetIngestCheckpoint(ctx context.Context, source string) (_ *models.IngestCheckpoint, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.GetIngestCheckpoint")
+	defer tracing.End(span, &err)
+
+	cp := &models.IngestCheckpoint{}
+	query := `
+		SELECT source, upstream_version, last_index, last_card_id, completed, updated_at
+		FROM ingest_checkpoints WHERE source = $1
+	`
+	err = r.db.QueryRowContext(ctx, query, source).Scan(
+		&cp.Source, &cp.UpstreamVersion, &cp.LastIndex, &cp.LastCardID, &cp.Completed, &cp.UpdatedAt,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ingest checkpoint: %w", err)
+	}
+	return cp, nil
+}
+
+// SaveIngestCheckpoint creates or replaces the checkpoint for a source
+func (r *CardRepository) SaveIngestCheckpoint(ctx context.Context, cp *models.IngestCheckpoint) (err error) {
+	ctx, span := startSpan(ctx, "CardRepository.SaveIngestCheckpoint")
+	defer tracing.End(span, &err)
+
+	query := `
+		INSERT INTO ingest_checkpoints (source, upstream_version, last_index, last_card_id, completed)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (source) DO UPDATE SET
+			upstream_version = EXCLUDED.upstream_version,
+			last_index = EXCLUDED.last_index,
+			last_card_id = EXCLUDED.last_card_id,
+			completed = EXCLUDED.completed,
+			updated_at = CURRENT_TIMESTAMP
+	`
+	_, err = r.db.ExecContext(ctx, query, cp.Source, cp.UpstreamVersion, cp.LastIndex, cp.LastCardID, cp.Completed)
+	if err != nil {
+		return fmt.Errorf("failed to save ingest checkpoint: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+)
+
+const ingestRunColumns = `id, run_id, source, status, started_at, finished_at, cards_processed, cards_failed, error`
+
+// StartIngestRun records that an ingest run has started
+func (r *CardRepository) StartIngestRun(ctx context.Context, runID, source string) (err error) {
+	ctx, span := startSpan(ctx, "CardRepository.StartIngestRun")
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, `INSERT INTO ingest_runs (run_id, source, status) VALUES ($1, $2, $3)`,
+		runID, source, models.IngestRunning)
+	if err != nil {
+		return fmt.Errorf("failed to start ingest run: %w", err)
+	}
+	return nil
+}
+
+// FinishIngestRun stores the final status, counts and error of an ingest run
+func (r *CardRepository) FinishIngestRun(ctx context.Context, run *models.IngestRun) (err error) {
+	ctx, span := startSpan(ctx, "CardRepository.FinishIngestRun")
+	defer tracing.End(span, &err)
+
+	query := `
+		UPDATE ingest_runs
+		SET status = $2, finished_at = CURRENT_TIMESTAMP, cards_processed = $3, cards_failed = $4, error = $5
+		WHERE run_id = $1
+	`
+	_, err = r.db.ExecContext(ctx, query, run.RunID, run.Status, run.CardsProcessed, run.CardsFailed, run.Error)
+	if err != nil {
+		return fmt.Errorf("failed to finish ingest run: %w", err)
+	}
+	return nil
+}
+
+// LastIngestRun returns the most recently started ingest run, or nil if there is none
+func (r *CardRepository) LastIngestRun(ctx context.Context) (_ *models.IngestRun, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.LastIngestRun")
+	defer tracing.End(span, &err)
+
+	return r.getIngestRun(ctx, `SELECT `+ingestRunColumns+` FROM ingest_runs ORDER BY started_at DESC, id DESC LIMIT 1`)
+}
+
+// LastSuccessfulIngestRun returns the most recently finished successful ingest run,
+// or nil if no run has succeeded yet
+func (r *CardRepository) LastSuccessfulIngestRun(ctx context.Context) (_ *models.IngestRun, err error) {
+	ctx, span := startSpan(ctx, "CardRepository.LastSuccessfulIngestRun")
+	defer tracing.End(span, &err)
+
+	return r.getIngestRun(ctx, `SELECT `+ingestRunColumns+` FROM ingest_runs WHERE status = $1 ORDER BY finished_at DESC LIMIT 1`,
+		models.IngestSucceeded)
+}
+
+func (r *CardRepository) getIngestRun(ctx context.Context, query string, args ...interface{}) (*models.IngestRun, error) {
+	run := &models.IngestRun{}
+	err := r.db.QueryRowContext(ctx, query, args...).Scan(
+		&run.ID, &run.RunID, &run.Source, &run.Status, &run.StartedAt, &run.FinishedAt,
+		&run.CardsProcessed, &run.CardsFailed, &run.Error,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get ingest run: %w", err)
+	}
+	return run, nil
+}
@@ -0,0 +1,313 @@
+package repository
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"sort"
+	"sync"
+	"time"
+)
+
+// MemoryCardStore keeps cards, ingest checkpoints and ingest runs in memory. It
+// follows the same rules as CardRepository: writes replace a card's related rows and
+// stamp it with the next change sequence, reads never return image data, and sync
+// iterators see a snapshot taken when they are created. It is meant for tests and
+// local runs without Postgres.
+type MemoryCardStore struct {
+	mu          sync.Mutex
+	now         func() time.Time
+	cards       map[int64]*memoryCard
+	seq         int64
+	relatedID   int
+	checkpoints map[string]models.IngestCheckpoint
+	runs        []models.IngestRun
+}
+
+// memoryCard is a stored card along with its change sequence
+type memoryCard struct {
+	card models.Card
+	seq  int64
+}
+
+// NewMemoryCardStore creates an empty in-memory card store
+func NewMemoryCardStore() *MemoryCardStore {
+	return &MemoryCardStore{
+		now:         time.Now,
+		cards:       make(map[int64]*memoryCard),
+		checkpoints: make(map[string]models.IngestCheckpoint),
+	}
+}
+
+// CreateCard stores a card and replaces its related rows
+func (s *MemoryCardStore) CreateCard(ctx context.Context, card *models.Card) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.upsert(card, s.now())
+	return nil
+}
+
+// CreateCards stores a batch of cards and replaces their related rows. When a batch
+// holds the same card twice, the last copy wins.
+func (s *MemoryCardStore) CreateCards(ctx context.Context, cards []models.Card) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	for _, card := range dedupeCards(cards) {
+		s.upsert(&card, now)
+	}
+	return nil
+}
+
+// upsert writes a card the way the cards upsert does: created_at is kept for
+// existing cards, updated_at is refreshed and related rows get new IDs
+func (s *MemoryCardStore) upsert(card *models.Card, now time.Time) {
+	stored := copyCard(card, models.AllCardRelations, true)
+	stored.CreatedAt, stored.UpdatedAt = now, now
+	if existing, ok := s.cards[card.ID]; ok {
+		stored.CreatedAt = existing.card.CreatedAt
+	}
+
+	for i := range stored.CardSets {
+		s.relatedID++
+		stored.CardSets[i].ID, stored.CardSets[i].CardID, stored.CardSets[i].CreatedAt = s.relatedID, card.ID, now
+	}
+	for i := range stored.CardImages {
+		s.relatedID++
+		stored.CardImages[i].ID, stored.CardImages[i].CardID, stored.CardImages[i].CreatedAt = s.relatedID, card.ID, now
+	}
+	for i := range stored.CardPrices {
+		s.relatedID++
+		price := &stored.CardPrices[i]
+		price.ID, price.CardID, price.CreatedAt, price.UpdatedAt = s.relatedID, card.ID, now, now
+	}
+
+	s.seq++
+	s.cards[card.ID] = &memoryCard{card: stored, seq: s.seq}
+}
+
+// GetCard returns a card with the selected related rows, or nil when it does not exist
+func (s *MemoryCardStore) GetCard(ctx context.Context, cardID int64, relations models.CardRelations) (*models.Card, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	stored, ok := s.cards[cardID]
+	if !ok {
+		return nil, nil
+	}
+	card := copyCard(&stored.card, relations, false)
+	return &card, nil
+}
+
+// GetCardCount returns the total number of cards
+func (s *MemoryCardStore) GetCardCount(ctx context.Context) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.cards), nil
+}
+
+// HasCards reports whether the store holds at least one card
+func (s *MemoryCardStore) HasCards(ctx context.Context) (bool, error) {
+	count, err := s.GetCardCount(ctx)
+	return count > 0, err
+}
+
+// GetCardsUpdatedAfter returns an iterator over cards created or updated after the
+// given timestamp, along with the change sequence the result is consistent with
+func (s *MemoryCardStore) GetCardsUpdatedAfter(ctx context.Context, lastUpdate time.Time, relations models.CardRelations) (*CardIterator, int64, error) {
+	return s.snapshot(ctx, relations, func(stored *memoryCard) bool {
+		return stored.card.UpdatedAt.After(lastUpdate) || stored.card.CreatedAt.After(lastUpdate)
+	})
+}
+
+// GetCardsChangedSince returns an iterator over cards whose change sequence is
+// greater than sinceSeq, along with the sequence to send on the next sync
+func (s *MemoryCardStore) GetCardsChangedSince(ctx context.Context, sinceSeq int64, relations models.CardRelations) (*CardIterator, int64, error) {
+	return s.snapshot(ctx, relations, func(stored *memoryCard) bool {
+		return stored.seq > sinceSeq
+	})
+}
+
+// GetAllCardsForFirstSync returns an iterator over all cards, along with the change
+// sequence the result is consistent with
+func (s *MemoryCardStore) GetAllCardsForFirstSync(ctx context.Context, relations models.CardRelations) (*CardIterator, int64, error) {
+	return s.snapshot(ctx, relations, func(*memoryCard) bool { return true })
+}
+
+// snapshot copies the matching cards in ID order and pages through the copy, so
+// writes made while the iterator is open are not seen
+func (s *MemoryCardStore) snapshot(ctx context.Context, relations models.CardRelations, match func(*memoryCard) bool) (*CardIterator, int64, error) {
+	s.mu.Lock()
+	var cards []models.Card
+	var seq int64
+	var updatedThrough time.Time
+	for _, stored := range s.cards {
+		seq = max(seq, stored.seq)
+		for _, t := range []time.Time{stored.card.CreatedAt, stored.card.UpdatedAt} {
+			if t.After(updatedThrough) {
+				updatedThrough = t
+			}
+		}
+		if match(stored) {
+			cards = append(cards, copyCard(&stored.card, relations, false))
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(cards, func(i, j int) bool { return cards[i].ID < cards[j].ID })
+
+	fetch := func(ctx context.Context, afterID int64, limit int) ([]models.Card, error) {
+		start := sort.Search(len(cards), func(i int) bool { return cards[i].ID > afterID })
+		end := min(start+limit, len(cards))
+		return cards[start:end], nil
+	}
+	it := NewCardIterator(ctx, fetch, defaultCardPageSize)
+	it.updatedThrough = updatedThrough
+	return it, seq, nil
+}
+
+// ForEachSmallImage passes the stored small image data of every card image to fn,
+// ordered by card and image ID
+func (s *MemoryCardStore) ForEachSmallImage(ctx context.Context, fn func(imageID int, cardID int64, data []byte) error) error {
+	s.mu.Lock()
+	var images []models.CardImage
+	for _, stored := range s.cards {
+		for _, image := range stored.card.CardImages {
+			if image.ImageSmallData != nil {
+				images = append(images, image)
+			}
+		}
+	}
+	s.mu.Unlock()
+
+	sort.Slice(images, func(i, j int) bool {
+		if images[i].CardID != images[j].CardID {
+			return images[i].CardID < images[j].CardID
+		}
+		return images[i].ID < images[j].ID
+	})
+	for _, image := range images {
+		if err := fn(image.ID, image.CardID, image.ImageSmallData); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetIngestCheckpoint returns the stored checkpoint for a source, or nil if there is none
+func (s *MemoryCardStore) GetIngestCheckpoint(ctx context.Context, source string) (*models.IngestCheckpoint, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	cp, ok := s.checkpoints[source]
+	if !ok {
+		return nil, nil
+	}
+	return &cp, nil
+}
+
+// SaveIngestCheckpoint creates or replaces the checkpoint for a source
+func (s *MemoryCardStore) SaveIngestCheckpoint(ctx context.Context, cp *models.IngestCheckpoint) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	saved := *cp
+	saved.UpdatedAt = s.now()
+	s.checkpoints[cp.Source] = saved
+	return nil
+}
+
+// StartIngestRun records that an ingest run has started
+func (s *MemoryCardStore) StartIngestRun(ctx context.Context, runID, source string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.runs = append(s.runs, models.IngestRun{
+		ID:        int64(len(s.runs) + 1),
+		RunID:     runID,
+		Source:    source,
+		Status:    models.IngestRunning,
+		StartedAt: s.now(),
+	})
+	return nil
+}
+
+// FinishIngestRun stores the final status, counts and error of an ingest run
+func (s *MemoryCardStore) FinishIngestRun(ctx context.Context, run *models.IngestRun) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i := range s.runs {
+		if s.runs[i].RunID == run.RunID {
+			finished := s.now()
+			s.runs[i].Status = run.Status
+			s.runs[i].FinishedAt = &finished
+			s.runs[i].CardsProcessed = run.CardsProcessed
+			s.runs[i].CardsFailed = run.CardsFailed
+			s.runs[i].Error = run.Error
+		}
+	}
+	return nil
+}
+
+// LastIngestRun returns the most recently started ingest run, or nil if there is none
+func (s *MemoryCardStore) LastIngestRun(ctx context.Context) (*models.IngestRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if len(s.runs) == 0 {
+		return nil, nil
+	}
+	run := s.runs[len(s.runs)-1]
+	return &run, nil
+}
+
+// LastSuccessfulIngestRun returns the most recently finished successful ingest run,
+// or nil if no run has succeeded yet
+func (s *MemoryCardStore) LastSuccessfulIngestRun(ctx context.Context) (*models.IngestRun, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var last *models.IngestRun
+	for i := range s.runs {
+		run := &s.runs[i]
+		if run.Status == models.IngestSucceeded && (last == nil || !run.FinishedAt.Before(*last.FinishedAt)) {
+			last = run
+		}
+	}
+	if last == nil {
+		return nil, nil
+	}
+	found := *last
+	return &found, nil
+}
+
+// copyCard returns a copy of card that shares no slices with it, holding only the
+// selected related rows. Image data is dropped unless withImageData is set, as the
+// repository never reads it back with a card.
+func copyCard(card *models.Card, relations models.CardRelations, withImageData bool) models.Card {
+	c := *card
+	c.CardSets, c.CardImages, c.CardPrices = nil, nil, nil
+
+	if relations.Sets {
+		c.CardSets = append([]models.CardSet(nil), card.CardSets...)
+	}
+	if relations.Images {
+		c.CardImages = append([]models.CardImage(nil), card.CardImages...)
+		for i := range c.CardImages {
+			image := &c.CardImages[i]
+			if withImageData {
+				image.ImageData = append([]byte(nil), image.ImageData...)
+				image.ImageSmallData = append([]byte(nil), image.ImageSmallData...)
+				image.ImageCroppedData = append([]byte(nil), image.ImageCroppedData...)
+			} else {
+				image.ImageData, image.ImageSmallData, image.ImageCroppedData = nil, nil, nil
+			}
+		}
+	}
+	if relations.Prices {
+		c.CardPrices = append([]models.CardPrice(nil), card.CardPrices...)
+	}
+	return c
+}
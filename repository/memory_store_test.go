@@ -0,0 +1,145 @@
+package repository
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"testing"
+	"time"
+)
+
+// collect drains an iterator into the IDs of its cards
+func collect(t *testing.T, it *CardIterator) []int64 {
+	t.Helper()
+	defer it.Close()
+
+	var ids []int64
+	for it.Next() {
+		ids = append(ids, it.Card().ID)
+	}
+	if err := it.Err(); err != nil {
+		t.Fatalf("iteration failed: %v", err)
+	}
+	return ids
+}
+
+func TestMemoryCardStoreUpsert(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCardStore()
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	store.now = func() time.Time { return now }
+
+	card := models.Card{
+		ID:         1,
+		Name:       "Dark Magician",
+		CardSets:   []models.CardSet{{SetCode: "LOB-005"}, {SetCode: "SDY-006"}},
+		CardImages: []models.CardImage{{ImageURL: "https://images.example.com/1.jpg", ImageSmallData: []byte("small")}},
+	}
+	if err := store.CreateCard(ctx, &card); err != nil {
+		t.Fatal(err)
+	}
+
+	now = now.Add(time.Hour)
+	card.Name = "Dark Magician (updated)"
+	card.CardSets = []models.CardSet{{SetCode: "LOB-005"}}
+	if err := store.CreateCards(ctx, []models.Card{card}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := store.GetCard(ctx, 1, models.AllCardRelations)
+	if err != nil || got == nil {
+		t.Fatalf("GetCard = %v, %v", got, err)
+	}
+	if got.Name != "Dark Magician (updated)" {
+		t.Errorf("name = %q, want the updated name", got.Name)
+	}
+	if !got.CreatedAt.Equal(now.Add(-time.Hour)) || !got.UpdatedAt.Equal(now) {
+		t.Errorf("created_at = %s, updated_at = %s, want creation kept and update refreshed", got.CreatedAt, got.UpdatedAt)
+	}
+	if len(got.CardSets) != 1 || got.CardSets[0].CardID != 1 {
+		t.Errorf("card sets = %+v, want the single replacement set", got.CardSets)
+	}
+	if len(got.CardImages) != 1 || got.CardImages[0].ImageSmallData != nil {
+		t.Errorf("card images = %+v, want one image without data", got.CardImages)
+	}
+
+	var images int
+	store.ForEachSmallImage(ctx, func(imageID int, cardID int64, data []byte) error {
+		images++
+		if string(data) != "small" {
+			t.Errorf("small image data = %q", data)
+		}
+		return nil
+	})
+	if images != 1 {
+		t.Errorf("ForEachSmallImage visited %d images, want 1", images)
+	}
+
+	if missing, _ := store.GetCard(ctx, 2, models.AllCardRelations); missing != nil {
+		t.Errorf("GetCard for a missing card = %+v, want nil", missing)
+	}
+}
+
+func TestMemoryCardStoreSnapshots(t *testing.T) {
+	ctx := context.Background()
+	store := NewMemoryCardStore()
+	base := time.Date(2024, 6, 1, 12, 0, 0, 0, time.UTC)
+	now := base
+	store.now = func() time.Time { return now }
+
+	for id := int64(1); id <= 3; id++ {
+		store.CreateCard(ctx, &models.Card{ID: id})
+		now = now.Add(time.Minute)
+	}
+
+	it, seq, err := store.GetAllCardsForFirstSync(ctx, models.CardRelations{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 3 {
+		t.Errorf("seq = %d, want 3", seq)
+	}
+	// Writes after the snapshot was taken must not show up in it
+	store.CreateCard(ctx, &models.Card{ID: 4})
+	if ids := collect(t, it); len(ids) != 3 {
+		t.Errorf("first sync returned %v, want cards 1-3", ids)
+	}
+
+	tests := []struct {
+		name string
+		open func() (*CardIterator, int64, error)
+		want []int64
+	}{
+		{"changed since 2", func() (*CardIterator, int64, error) {
+			return store.GetCardsChangedSince(ctx, 2, models.CardRelations{})
+		}, []int64{3, 4}},
+		{"changed since latest", func() (*CardIterator, int64, error) {
+			return store.GetCardsChangedSince(ctx, 4, models.CardRelations{})
+		}, nil},
+		{"updated after second card", func() (*CardIterator, int64, error) {
+			return store.GetCardsUpdatedAfter(ctx, base.Add(time.Minute), models.CardRelations{})
+		}, []int64{3, 4}},
+		{"updated after exactly the first card", func() (*CardIterator, int64, error) {
+			return store.GetCardsUpdatedAfter(ctx, base, models.CardRelations{})
+		}, []int64{2, 3, 4}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			it, seq, err := tt.open()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if seq != 4 {
+				t.Errorf("seq = %d, want 4", seq)
+			}
+			ids := collect(t, it)
+			if len(ids) != len(tt.want) {
+				t.Fatalf("cards = %v, want %v", ids, tt.want)
+			}
+			for i := range ids {
+				if ids[i] != tt.want[i] {
+					t.Fatalf("cards = %v, want %v", ids, tt.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,177 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"index-duel-backend/database"
+	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+
+	"github.com/lib/pq"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// ErrEmailTaken is returned when registering an email that already has an account
+var ErrEmailTaken = errors.New("email already registered")
+
+// UserRepository stores user accounts and their refresh tokens
+type UserRepository struct {
+	db *database.DB
+}
+
+func NewUserRepository(db *database.DB) *UserRepository {
+	return &UserRepository{db: db}
+}
+
+// CreateUser stores a new user and fills in the generated ID and timestamps
+func (r *UserRepository) CreateUser(ctx context.Context, user *models.User) (err error) {
+	ctx, span := startSpan(ctx, "UserRepository.CreateUser")
+	defer tracing.End(span, &err)
+
+	query := `
+		INSERT INTO users (email, password_hash)
+		VALUES ($1, $2)
+		RETURNING id, created_at, updated_at
+	`
+	err = r.db.QueryRowContext(ctx, query, user.Email, user.PasswordHash).Scan(&user.ID, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return ErrEmailTaken
+		}
+		return fmt.Errorf("failed to create user: %w", err)
+	}
+	return nil
+}
+
+// GetUserByEmail returns the user with the given email, or nil if there is none
+func (r *UserRepository) GetUserByEmail(ctx context.Context, email string) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetUserByEmail")
+	defer tracing.End(span, &err)
+	return r.getUser(ctx, "email = $1", email)
+}
+
+// GetUserByID returns the user with the given ID, or nil if there is none
+func (r *UserRepository) GetUserByID(ctx context.Context, id int64) (_ *models.User, err error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetUserByID", attribute.Int64("user.id", id))
+	defer tracing.End(span, &err)
+	return r.getUser(ctx, "id = $1", id)
+}
+
+func (r *UserRepository) getUser(ctx context.Context, filter string, arg interface{}) (*models.User, error) {
+	user := &models.User{}
+	query := `SELECT id, email, password_hash, created_at, updated_at FROM users WHERE ` + filter
+	err := r.db.QueryRowContext(ctx, query, arg).Scan(&user.ID, &user.Email, &user.PasswordHash, &user.CreatedAt, &user.UpdatedAt)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get user: %w", err)
+	}
+	return user, nil
+}
+
+// CreateRefreshToken stores the hash of a new refresh token
+func (r *UserRepository) CreateRefreshToken(ctx context.Context, token *models.RefreshToken, hash []byte) (err error) {
+	ctx, span := startSpan(ctx, "UserRepository.CreateRefreshToken", attribute.Int64("user.id", token.UserID))
+	defer tracing.End(span, &err)
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	err = r.db.QueryRowContext(ctx, query, token.UserID, token.FamilyID, hash, token.ExpiresAt).Scan(&token.ID, &token.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	return nil
+}
+
+// GetRefreshTokenByHash returns the token with the given hash, revoked or not, or
+// nil if there is none
+func (r *UserRepository) GetRefreshTokenByHash(ctx context.Context, hash []byte) (_ *models.RefreshToken, err error) {
+	ctx, span := startSpan(ctx, "UserRepository.GetRefreshTokenByHash")
+	defer tracing.End(span, &err)
+
+	token := &models.RefreshToken{}
+	var revoked sql.NullTime
+	query := `
+		SELECT id, user_id, family_id, expires_at, created_at, revoked_at
+		FROM refresh_tokens WHERE token_hash = $1
+	`
+	err = r.db.QueryRowContext(ctx, query, hash).Scan(
+		&token.ID, &token.UserID, &token.FamilyID, &token.ExpiresAt, &token.CreatedAt, &revoked,
+	)
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to get refresh token: %w", err)
+	}
+	if revoked.Valid {
+		token.RevokedAt = &revoked.Time
+	}
+	return token, nil
+}
+
+// RotateRefreshToken revokes an active token and stores its successor in one
+// transaction. It reports false, storing nothing, if the old token was already
+// revoked, which happens when two refreshes race with the same token.
+func (r *UserRepository) RotateRefreshToken(ctx context.Context, oldID int64, next *models.RefreshToken, hash []byte) (_ bool, err error) {
+	ctx, span := startSpan(ctx, "UserRepository.RotateRefreshToken", attribute.Int64("user.id", next.UserID))
+	defer tracing.End(span, &err)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, fmt.Errorf("failed to begin transaction: %w", err)
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE id = $1 AND revoked_at IS NULL`, oldID)
+	if err != nil {
+		return false, fmt.Errorf("failed to revoke refresh token: %w", err)
+	}
+	if n, _ := result.RowsAffected(); n == 0 {
+		return false, nil
+	}
+
+	query := `
+		INSERT INTO refresh_tokens (user_id, family_id, token_hash, expires_at)
+		VALUES ($1, $2, $3, $4)
+		RETURNING id, created_at
+	`
+	if err := tx.QueryRowContext(ctx, query, next.UserID, next.FamilyID, hash, next.ExpiresAt).Scan(&next.ID, &next.CreatedAt); err != nil {
+		return false, fmt.Errorf("failed to create refresh token: %w", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("failed to commit refresh token rotation: %w", err)
+	}
+	return true, nil
+}
+
+// RevokeRefreshTokenFamily revokes every active token descended from one login
+func (r *UserRepository) RevokeRefreshTokenFamily(ctx context.Context, familyID string) (err error) {
+	ctx, span := startSpan(ctx, "UserRepository.RevokeRefreshTokenFamily")
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE family_id = $1 AND revoked_at IS NULL`, familyID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// RevokeUserRefreshTokens revokes every active token of a user, ending all sessions
+func (r *UserRepository) RevokeUserRefreshTokens(ctx context.Context, userID int64) (err error) {
+	ctx, span := startSpan(ctx, "UserRepository.RevokeUserRefreshTokens", attribute.Int64("user.id", userID))
+	defer tracing.End(span, &err)
+
+	_, err = r.db.ExecContext(ctx, `UPDATE refresh_tokens SET revoked_at = CURRENT_TIMESTAMP WHERE user_id = $1 AND revoked_at IS NULL`, userID)
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
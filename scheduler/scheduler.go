@@ -1,49 +1,46 @@
 package scheduler
 
 import (
+	"context"
+	"index-duel-backend/bundle"
+	"index-duel-backend/logging"
+	"index-duel-backend/metrics"
 	"index-duel-backend/service"
-	"log"
+	"log/slog"
 	"time"
 )
 
 type Scheduler struct {
-	cardService *service.CardService
-	ticker      *time.Ticker
-	done        chan bool
+	cardService   *service.CardService
+	bundleBuilder *bundle.Builder
+	interval      time.Duration
+	ticker        *time.Ticker
+	done          chan bool
 }
 
-func NewScheduler(cardService *service.CardService) *Scheduler {
+// NewScheduler creates a scheduler synchronizing every interval. When bundleBuilder
+// is not nil, a new catalogue bundle is built after every successful synchronization.
+func NewScheduler(cardService *service.CardService, bundleBuilder *bundle.Builder, interval time.Duration) *Scheduler {
 	return &Scheduler{
-		cardService: cardService,
-		done:        make(chan bool),
+		cardService:   cardService,
+		bundleBuilder: bundleBuilder,
+		interval:      interval,
+		done:          make(chan bool),
 	}
 }
 
-// Start begins the weekly card synchronization
+// Start begins the periodic card synchronization
 func (s *Scheduler) Start() {
 	// Run immediately on startup
-	go func() {
-		log.Println("Running initial card synchronization...")
-		if err := s.cardService.FetchAndStoreAllCards(); err != nil {
-			log.Printf("Error during initial sync: %v", err)
-		} else {
-			log.Println("Initial card synchronization completed")
-		}
-	}()
+	go s.runSync("initial")
 
-	// Schedule weekly updates (every 7 days)
-	s.ticker = time.NewTicker(7 * 24 * time.Hour)
+	s.ticker = time.NewTicker(s.interval)
 
 	go func() {
 		for {
 			select {
 			case <-s.ticker.C:
-				log.Println("Running weekly card synchronization...")
-				if err := s.cardService.FetchAndStoreAllCards(); err != nil {
-					log.Printf("Error during weekly sync: %v", err)
-				} else {
-					log.Println("Weekly card synchronization completed")
-				}
+				s.runSync("scheduled")
 			case <-s.done:
 				s.ticker.Stop()
 				return
@@ -51,7 +48,33 @@ func (s *Scheduler) Start() {
 		}
 	}()
 
-	log.Println("Card synchronization scheduler started (weekly updates)")
+	slog.Info("card synchronization scheduler started", "interval", s.interval)
+}
+
+// runSync ingests the upstream catalogue and, when that succeeds, rebuilds the bundle
+func (s *Scheduler) runSync(trigger string) {
+	ctx := logging.WithAttrs(context.Background(), slog.String("trigger", trigger))
+
+	slog.InfoContext(ctx, "running card synchronization")
+	if err := s.cardService.FetchAndStoreAllCards(ctx); err != nil {
+		slog.ErrorContext(ctx, "card synchronization failed", "error", err)
+		return
+	}
+	slog.InfoContext(ctx, "card synchronization completed")
+	metrics.SchedulerLastSuccess.WithLabelValues("card_sync").SetToCurrentTime()
+	s.buildBundle(ctx)
+}
+
+// buildBundle regenerates the catalogue bundle, if one is configured
+func (s *Scheduler) buildBundle(ctx context.Context) {
+	if s.bundleBuilder == nil {
+		return
+	}
+	if _, err := s.bundleBuilder.Build(ctx); err != nil {
+		slog.ErrorContext(ctx, "failed to build catalogue bundle", "error", err)
+		return
+	}
+	metrics.SchedulerLastSuccess.WithLabelValues("bundle_build").SetToCurrentTime()
 }
 
 // Stop terminates the scheduler
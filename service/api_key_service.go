@@ -0,0 +1,203 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// apiKeyPrefix starts every issued key, so leaked keys are easy to recognise
+const apiKeyPrefix = "idk_"
+
+// ErrCodeInvalidScopes reports a scope list containing unknown scopes
+const ErrCodeInvalidScopes = "invalid_scopes"
+
+// apiKeyTouchInterval is how often a key's last use is written back per process
+const apiKeyTouchInterval = time.Minute
+
+// ErrInvalidAPIKey is returned for keys that are malformed, unknown or revoked
+var ErrInvalidAPIKey = errors.New("invalid api key")
+
+// APIKeyStore is the key storage APIKeyService needs. repository.APIKeyRepository
+// implements it on Postgres.
+type APIKeyStore interface {
+	CreateAPIKey(ctx context.Context, key *models.APIKey, hash []byte) error
+	GetAPIKeyByHash(ctx context.Context, hash []byte) (*models.APIKey, error)
+	GetAPIKey(ctx context.Context, id int64) (*models.APIKey, error)
+	ListAPIKeys(ctx context.Context) ([]models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id int64) (bool, error)
+	RotateAPIKey(ctx context.Context, oldID int64, key *models.APIKey, hash []byte) error
+	TouchAPIKey(ctx context.Context, id int64) error
+}
+
+var _ APIKeyStore = (*repository.APIKeyRepository)(nil)
+
+// APIKeyService issues, rotates, revokes and checks client API keys
+type APIKeyService struct {
+	repo APIKeyStore
+	now  func() time.Time
+
+	mu      sync.Mutex
+	touched map[int64]time.Time
+}
+
+// NewAPIKeyService creates a new API key service
+func NewAPIKeyService(repo APIKeyStore) *APIKeyService {
+	return &APIKeyService{repo: repo, now: time.Now, touched: make(map[int64]time.Time)}
+}
+
+// IssuedAPIKey is a newly created key. Key is the only copy of the secret and
+// cannot be recovered later.
+type IssuedAPIKey struct {
+	Key    string         `json:"key"`
+	APIKey *models.APIKey `json:"api_key"`
+}
+
+// CreateAPIKey issues a new key with the given name and scopes
+func (s *APIKeyService) CreateAPIKey(ctx context.Context, name string, scopes []string) (*IssuedAPIKey, error) {
+	if strings.TrimSpace(name) == "" {
+		return nil, &InvalidRequestError{Code: "invalid_name", Message: "name must not be empty"}
+	}
+	if err := validateScopes(scopes); err != nil {
+		return nil, err
+	}
+
+	plaintext, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{Name: name, Prefix: prefix, Scopes: scopes}
+	if err := s.repo.CreateAPIKey(ctx, key, hashAPIKey(plaintext)); err != nil {
+		return nil, err
+	}
+	return &IssuedAPIKey{Key: plaintext, APIKey: key}, nil
+}
+
+// RotateAPIKey revokes a key and issues a replacement with the same name and scopes.
+// It returns nil if there is no active key with that ID.
+func (s *APIKeyService) RotateAPIKey(ctx context.Context, id int64) (*IssuedAPIKey, error) {
+	old, err := s.repo.GetAPIKey(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if old == nil || old.RevokedAt != nil {
+		return nil, nil
+	}
+
+	plaintext, prefix, err := generateAPIKey()
+	if err != nil {
+		return nil, err
+	}
+	key := &models.APIKey{Name: old.Name, Prefix: prefix, Scopes: old.Scopes}
+	if err := s.repo.RotateAPIKey(ctx, id, key, hashAPIKey(plaintext)); err != nil {
+		return nil, err
+	}
+	return &IssuedAPIKey{Key: plaintext, APIKey: key}, nil
+}
+
+// RevokeAPIKey revokes a key. It reports whether an active key was revoked.
+func (s *APIKeyService) RevokeAPIKey(ctx context.Context, id int64) (bool, error) {
+	return s.repo.RevokeAPIKey(ctx, id)
+}
+
+// ListAPIKeys returns every issued key without its secret
+func (s *APIKeyService) ListAPIKeys(ctx context.Context) ([]models.APIKey, error) {
+	return s.repo.ListAPIKeys(ctx)
+}
+
+// Authenticate returns the active key matching plaintext, or ErrInvalidAPIKey
+func (s *APIKeyService) Authenticate(ctx context.Context, plaintext string) (*models.APIKey, error) {
+	if !strings.HasPrefix(plaintext, apiKeyPrefix) {
+		return nil, ErrInvalidAPIKey
+	}
+
+	key, err := s.repo.GetAPIKeyByHash(ctx, hashAPIKey(plaintext))
+	if err != nil {
+		return nil, err
+	}
+	if key == nil {
+		return nil, ErrInvalidAPIKey
+	}
+
+	if s.shouldTouch(key.ID) {
+		if err := s.repo.TouchAPIKey(ctx, key.ID); err != nil {
+			slog.WarnContext(ctx, "failed to record api key usage", "api_key_id", key.ID, "error", err)
+		}
+	}
+	return key, nil
+}
+
+// shouldTouch reports whether a key's last use is due to be written back. Usage is
+// only shown at minute precision, so every request need not pay for an UPDATE.
+func (s *APIKeyService) shouldTouch(id int64) bool {
+	now := s.now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if last, ok := s.touched[id]; ok && now.Sub(last) < apiKeyTouchInterval {
+		return false
+	}
+	// Drop entries that have expired, so revoked keys do not linger
+	for other, last := range s.touched {
+		if now.Sub(last) >= apiKeyTouchInterval {
+			delete(s.touched, other)
+		}
+	}
+	s.touched[id] = now
+	return true
+}
+
+// generateAPIKey returns a new key of the form idk_<prefix>_<secret> and its
+// displayable prefix
+func generateAPIKey() (string, string, error) {
+	var id [4]byte
+	var secret [32]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+	if _, err := rand.Read(secret[:]); err != nil {
+		return "", "", fmt.Errorf("failed to generate api key: %w", err)
+	}
+
+	prefix := apiKeyPrefix + hex.EncodeToString(id[:])
+	return prefix + "_" + hex.EncodeToString(secret[:]), prefix, nil
+}
+
+// hashAPIKey hashes a key for storage. Keys carry 256 bits of randomness, so a
+// fast hash is enough to make a leaked table useless.
+func hashAPIKey(plaintext string) []byte {
+	sum := sha256.Sum256([]byte(plaintext))
+	return sum[:]
+}
+
+// validateScopes rejects empty and unknown scopes
+func validateScopes(scopes []string) error {
+	if len(scopes) == 0 {
+		return &InvalidRequestError{Code: ErrCodeInvalidScopes, Message: "at least one scope is required"}
+	}
+	for _, scope := range scopes {
+		known := false
+		for _, k := range models.KnownScopes {
+			if scope == k {
+				known = true
+				break
+			}
+		}
+		if !known {
+			return &InvalidRequestError{
+				Code:    ErrCodeInvalidScopes,
+				Message: fmt.Sprintf("unknown scope %q, expected one of %s", scope, strings.Join(models.KnownScopes, ", ")),
+			}
+		}
+	}
+	return nil
+}
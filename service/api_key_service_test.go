@@ -0,0 +1,60 @@
+package service
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"testing"
+	"time"
+)
+
+// touchCountingStore returns every hash as key 1 and counts usage writes. Methods
+// the tests do not use are left to the nil embedded interface.
+type touchCountingStore struct {
+	APIKeyStore
+	touches int
+}
+
+func (s *touchCountingStore) GetAPIKeyByHash(ctx context.Context, hash []byte) (*models.APIKey, error) {
+	return &models.APIKey{ID: 1, Scopes: []string{models.ScopeSyncRead}}, nil
+}
+
+func (s *touchCountingStore) TouchAPIKey(ctx context.Context, id int64) error {
+	s.touches++
+	return nil
+}
+
+func TestAuthenticateThrottlesUsageWrites(t *testing.T) {
+	ctx := context.Background()
+	store := &touchCountingStore{}
+	svc := NewAPIKeyService(store)
+	now := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	svc.now = func() time.Time { return now }
+
+	authenticate := func() {
+		t.Helper()
+		if _, err := svc.Authenticate(ctx, apiKeyPrefix+"abc_secret"); err != nil {
+			t.Fatalf("Authenticate failed: %v", err)
+		}
+	}
+
+	authenticate()
+	authenticate()
+	now = now.Add(apiKeyTouchInterval - time.Second)
+	authenticate()
+	if store.touches != 1 {
+		t.Fatalf("touches within the interval = %d, want 1", store.touches)
+	}
+
+	now = now.Add(time.Second)
+	authenticate()
+	if store.touches != 2 {
+		t.Errorf("touches after the interval = %d, want 2", store.touches)
+	}
+}
+
+func TestAuthenticateRejectsMalformedKey(t *testing.T) {
+	svc := NewAPIKeyService(&touchCountingStore{})
+	if _, err := svc.Authenticate(context.Background(), "not-a-key"); err != ErrInvalidAPIKey {
+		t.Errorf("Authenticate() error = %v, want ErrInvalidAPIKey", err)
+	}
+}
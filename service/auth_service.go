@@ -0,0 +1,315 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"index-duel-backend/config"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"log/slog"
+	"net/mail"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// jwtIssuer identifies access tokens issued by this server
+const jwtIssuer = "index-duel-backend"
+
+// Password length bounds; bcrypt ignores everything past 72 bytes
+const (
+	minPasswordLength = 8
+	maxPasswordLength = 72
+)
+
+// Error codes for rejected registration input
+const (
+	ErrCodeInvalidEmail    = "invalid_email"
+	ErrCodeInvalidPassword = "invalid_password"
+)
+
+var (
+	// ErrEmailTaken is returned when registering an email that already has an account
+	ErrEmailTaken = repository.ErrEmailTaken
+	// ErrInvalidCredentials is returned when an email and password do not match an account
+	ErrInvalidCredentials = errors.New("invalid email or password")
+	// ErrInvalidToken is returned for access or refresh tokens that are malformed,
+	// expired, revoked or unknown
+	ErrInvalidToken = errors.New("invalid token")
+)
+
+// UserStore is the account and session storage AuthService needs.
+// repository.UserRepository implements it on Postgres.
+type UserStore interface {
+	CreateUser(ctx context.Context, user *models.User) error
+	GetUserByEmail(ctx context.Context, email string) (*models.User, error)
+	GetUserByID(ctx context.Context, id int64) (*models.User, error)
+	CreateRefreshToken(ctx context.Context, token *models.RefreshToken, hash []byte) error
+	GetRefreshTokenByHash(ctx context.Context, hash []byte) (*models.RefreshToken, error)
+	RotateRefreshToken(ctx context.Context, oldID int64, next *models.RefreshToken, hash []byte) (bool, error)
+	RevokeRefreshTokenFamily(ctx context.Context, familyID string) error
+	RevokeUserRefreshTokens(ctx context.Context, userID int64) error
+}
+
+var _ UserStore = (*repository.UserRepository)(nil)
+
+// AuthService registers users and manages their sessions
+type AuthService struct {
+	repo            UserStore
+	secret          []byte
+	accessTokenTTL  time.Duration
+	refreshTokenTTL time.Duration
+	dummyHash       []byte
+}
+
+// AuthConfig configures token signing and lifetimes
+type AuthConfig struct {
+	Secret          string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+}
+
+// NewAuthService creates a new auth service signing access tokens with cfg.Secret
+func NewAuthService(repo UserStore, cfg AuthConfig) (*AuthService, error) {
+	if len(cfg.Secret) < config.MinJWTSecretLength {
+		return nil, fmt.Errorf("JWT secret must be at least %d characters", config.MinJWTSecretLength)
+	}
+
+	// Compared against when an email is unknown, so logins take as long either way
+	dummyHash, err := bcrypt.GenerateFromPassword([]byte("index-duel-dummy-password"), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to prepare password hashing: %w", err)
+	}
+
+	return &AuthService{
+		repo:            repo,
+		secret:          []byte(cfg.Secret),
+		accessTokenTTL:  cfg.AccessTokenTTL,
+		refreshTokenTTL: cfg.RefreshTokenTTL,
+		dummyHash:       dummyHash,
+	}, nil
+}
+
+// TokenPair is what a login or refresh returns to the client
+type TokenPair struct {
+	AccessToken  string    `json:"access_token"`
+	TokenType    string    `json:"token_type"`
+	ExpiresIn    int       `json:"expires_in"`
+	RefreshToken string    `json:"refresh_token"`
+	RefreshUntil time.Time `json:"refresh_expires_at"`
+}
+
+// Register creates an account for email with a bcrypt hash of password
+func (s *AuthService) Register(ctx context.Context, email, password string) (*models.User, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, err
+	}
+	if len(password) < minPasswordLength || len(password) > maxPasswordLength {
+		return nil, &InvalidRequestError{
+			Code:    ErrCodeInvalidPassword,
+			Message: fmt.Sprintf("password must be between %d and %d characters", minPasswordLength, maxPasswordLength),
+		}
+	}
+
+	hash, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+	if err != nil {
+		return nil, fmt.Errorf("failed to hash password: %w", err)
+	}
+
+	user := &models.User{Email: email, PasswordHash: string(hash)}
+	if err := s.repo.CreateUser(ctx, user); err != nil {
+		return nil, err
+	}
+	return user, nil
+}
+
+// Login checks a user's password and starts a new session
+func (s *AuthService) Login(ctx context.Context, email, password string) (*TokenPair, error) {
+	email, err := normalizeEmail(email)
+	if err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		bcrypt.CompareHashAndPassword(s.dummyHash, []byte(password))
+		return nil, ErrInvalidCredentials
+	}
+	if err := bcrypt.CompareHashAndPassword([]byte(user.PasswordHash), []byte(password)); err != nil {
+		return nil, ErrInvalidCredentials
+	}
+
+	familyID, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	return s.issueTokens(user.ID, func(refresh *models.RefreshToken, hash []byte) error {
+		refresh.FamilyID = familyID
+		return s.repo.CreateRefreshToken(ctx, refresh, hash)
+	})
+}
+
+// Refresh exchanges a refresh token for a new token pair, revoking the presented
+// token. Presenting a token that was already rotated revokes its whole family,
+// since either the client or an attacker holds a stolen copy.
+func (s *AuthService) Refresh(ctx context.Context, refreshToken string) (*TokenPair, error) {
+	current, err := s.repo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil {
+		return nil, err
+	}
+	if current == nil || time.Now().After(current.ExpiresAt) {
+		return nil, ErrInvalidToken
+	}
+	if current.RevokedAt != nil {
+		slog.WarnContext(ctx, "revoked refresh token reused, revoking its session", "user_id", current.UserID)
+		if err := s.repo.RevokeRefreshTokenFamily(ctx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+
+	var rotated bool
+	pair, err := s.issueTokens(current.UserID, func(next *models.RefreshToken, hash []byte) error {
+		next.FamilyID = current.FamilyID
+		var rotateErr error
+		rotated, rotateErr = s.repo.RotateRefreshToken(ctx, current.ID, next, hash)
+		return rotateErr
+	})
+	if err != nil {
+		return nil, err
+	}
+	if !rotated {
+		// Another refresh with the same token won the race; treat this one as reuse
+		if err := s.repo.RevokeRefreshTokenFamily(ctx, current.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrInvalidToken
+	}
+	return pair, nil
+}
+
+// Logout ends the session a refresh token belongs to. Unknown tokens are ignored.
+func (s *AuthService) Logout(ctx context.Context, refreshToken string) error {
+	current, err := s.repo.GetRefreshTokenByHash(ctx, hashToken(refreshToken))
+	if err != nil || current == nil {
+		return err
+	}
+	return s.repo.RevokeRefreshTokenFamily(ctx, current.FamilyID)
+}
+
+// LogoutAll ends every session of a user. Access tokens already issued stay valid
+// until they expire.
+func (s *AuthService) LogoutAll(ctx context.Context, userID int64) error {
+	return s.repo.RevokeUserRefreshTokens(ctx, userID)
+}
+
+// Authenticate validates an access token and returns the user it was issued to
+func (s *AuthService) Authenticate(ctx context.Context, accessToken string) (*models.User, error) {
+	userID, err := s.parseAccessToken(accessToken)
+	if err != nil {
+		return nil, err
+	}
+	user, err := s.repo.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+	if user == nil {
+		return nil, ErrInvalidToken
+	}
+	return user, nil
+}
+
+// parseAccessToken checks an access token's signature, issuer and expiry and
+// returns the user ID it was issued to
+func (s *AuthService) parseAccessToken(accessToken string) (int64, error) {
+	claims := &jwt.RegisteredClaims{}
+	_, err := jwt.ParseWithClaims(accessToken, claims, func(*jwt.Token) (interface{}, error) {
+		return s.secret, nil
+	},
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(jwtIssuer),
+		jwt.WithExpirationRequired(),
+	)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+
+	userID, err := strconv.ParseInt(claims.Subject, 10, 64)
+	if err != nil {
+		return 0, ErrInvalidToken
+	}
+	return userID, nil
+}
+
+// issueTokens signs an access token for userID and stores a new refresh token
+// through store, which fills in the token family
+func (s *AuthService) issueTokens(userID int64, store func(*models.RefreshToken, []byte) error) (*TokenPair, error) {
+	now := time.Now()
+	jti, err := randomToken(16)
+	if err != nil {
+		return nil, err
+	}
+	claims := jwt.RegisteredClaims{
+		Issuer:    jwtIssuer,
+		Subject:   strconv.FormatInt(userID, 10),
+		IssuedAt:  jwt.NewNumericDate(now),
+		ExpiresAt: jwt.NewNumericDate(now.Add(s.accessTokenTTL)),
+		ID:        jti,
+	}
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign access token: %w", err)
+	}
+
+	refreshToken, err := randomToken(32)
+	if err != nil {
+		return nil, err
+	}
+	refresh := &models.RefreshToken{UserID: userID, ExpiresAt: now.Add(s.refreshTokenTTL)}
+	if err := store(refresh, hashToken(refreshToken)); err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.accessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		RefreshUntil: refresh.ExpiresAt.UTC(),
+	}, nil
+}
+
+// normalizeEmail validates an email address and lowercases it
+func normalizeEmail(email string) (string, error) {
+	addr, err := mail.ParseAddress(strings.TrimSpace(email))
+	if err != nil || addr.Name != "" {
+		return "", &InvalidRequestError{Code: ErrCodeInvalidEmail, Message: "email must be a valid address"}
+	}
+	return strings.ToLower(addr.Address), nil
+}
+
+// randomToken returns n random bytes, base64url encoded
+func randomToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// hashToken hashes a refresh token for storage
+func hashToken(token string) []byte {
+	sum := sha256.Sum256([]byte(token))
+	return sum[:]
+}
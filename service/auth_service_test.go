@@ -0,0 +1,298 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/config"
+	"index-duel-backend/models"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func testAuthService(ttl time.Duration) *AuthService {
+	return &AuthService{
+		secret:          []byte(strings.Repeat("s", config.MinJWTSecretLength)),
+		accessTokenTTL:  ttl,
+		refreshTokenTTL: time.Hour,
+	}
+}
+
+// issueTestTokens issues tokens without storing the refresh token
+func issueTestTokens(t *testing.T, s *AuthService, userID int64) *TokenPair {
+	t.Helper()
+	pair, err := s.issueTokens(userID, func(*models.RefreshToken, []byte) error { return nil })
+	if err != nil {
+		t.Fatalf("issueTokens failed: %v", err)
+	}
+	return pair
+}
+
+func TestParseAccessToken(t *testing.T) {
+	s := testAuthService(time.Minute)
+	valid := issueTestTokens(t, s, 42).AccessToken
+
+	expired := issueTestTokens(t, testAuthService(-time.Minute), 42).AccessToken
+	otherSecret := testAuthService(time.Minute)
+	otherSecret.secret = []byte(strings.Repeat("x", config.MinJWTSecretLength))
+	forged := issueTestTokens(t, otherSecret, 42).AccessToken
+
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.RegisteredClaims{
+		Issuer:    jwtIssuer,
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+	noExpiry, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:  jwtIssuer,
+		Subject: "42",
+	}).SignedString(s.secret)
+	wrongIssuer, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.RegisteredClaims{
+		Issuer:    "someone-else",
+		Subject:   "42",
+		ExpiresAt: jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}).SignedString(s.secret)
+
+	tests := []struct {
+		name   string
+		token  string
+		wantID int64
+	}{
+		{"valid", valid, 42},
+		{"expired", expired, 0},
+		{"signed with another secret", forged, 0},
+		{"alg none", unsigned, 0},
+		{"no expiry", noExpiry, 0},
+		{"wrong issuer", wrongIssuer, 0},
+		{"garbage", "not-a-jwt", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := s.parseAccessToken(tt.token)
+			if tt.wantID == 0 {
+				if !errors.Is(err, ErrInvalidToken) {
+					t.Fatalf("parseAccessToken() error = %v, want ErrInvalidToken", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseAccessToken() failed: %v", err)
+			}
+			if got != tt.wantID {
+				t.Errorf("parseAccessToken() = %d, want %d", got, tt.wantID)
+			}
+		})
+	}
+}
+
+func TestNormalizeEmail(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		ok    bool
+	}{
+		{"duelist@example.com", "duelist@example.com", true},
+		{"  Duelist@Example.COM ", "duelist@example.com", true},
+		{"Yugi <yugi@example.com>", "", false},
+		{"not-an-email", "", false},
+		{"", "", false},
+	}
+
+	for _, tt := range tests {
+		got, err := normalizeEmail(tt.input)
+		if tt.ok != (err == nil) {
+			t.Errorf("normalizeEmail(%q) error = %v, want ok=%v", tt.input, err, tt.ok)
+			continue
+		}
+		if got != tt.want {
+			t.Errorf("normalizeEmail(%q) = %q, want %q", tt.input, got, tt.want)
+		}
+	}
+}
+
+// memoryUserStore keeps users and refresh tokens in memory. loseRotation makes
+// RotateRefreshToken report that another refresh revoked the token first.
+type memoryUserStore struct {
+	mu           sync.Mutex
+	users        []*models.User
+	tokens       map[string]*models.RefreshToken
+	loseRotation bool
+}
+
+func newMemoryUserStore() *memoryUserStore {
+	return &memoryUserStore{tokens: make(map[string]*models.RefreshToken)}
+}
+
+func (m *memoryUserStore) CreateUser(ctx context.Context, user *models.User) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	user.ID = int64(len(m.users) + 1)
+	m.users = append(m.users, user)
+	return nil
+}
+
+func (m *memoryUserStore) GetUserByEmail(ctx context.Context, email string) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, u := range m.users {
+		if u.Email == email {
+			return u, nil
+		}
+	}
+	return nil, nil
+}
+
+func (m *memoryUserStore) GetUserByID(ctx context.Context, id int64) (*models.User, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if id < 1 || int(id) > len(m.users) {
+		return nil, nil
+	}
+	return m.users[id-1], nil
+}
+
+func (m *memoryUserStore) CreateRefreshToken(ctx context.Context, token *models.RefreshToken, hash []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	token.ID = int64(len(m.tokens) + 1)
+	stored := *token
+	m.tokens[string(hash)] = &stored
+	return nil
+}
+
+func (m *memoryUserStore) GetRefreshTokenByHash(ctx context.Context, hash []byte) (*models.RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if token, ok := m.tokens[string(hash)]; ok {
+		copied := *token
+		return &copied, nil
+	}
+	return nil, nil
+}
+
+func (m *memoryUserStore) RotateRefreshToken(ctx context.Context, oldID int64, next *models.RefreshToken, hash []byte) (bool, error) {
+	m.mu.Lock()
+	if m.loseRotation {
+		m.mu.Unlock()
+		return false, nil
+	}
+	for _, token := range m.tokens {
+		if token.ID == oldID {
+			if token.RevokedAt != nil {
+				m.mu.Unlock()
+				return false, nil
+			}
+			now := time.Now()
+			token.RevokedAt = &now
+		}
+	}
+	m.mu.Unlock()
+	return true, m.CreateRefreshToken(ctx, next, hash)
+}
+
+func (m *memoryUserStore) RevokeRefreshTokenFamily(ctx context.Context, familyID string) error {
+	return m.revoke(func(token *models.RefreshToken) bool { return token.FamilyID == familyID })
+}
+
+func (m *memoryUserStore) RevokeUserRefreshTokens(ctx context.Context, userID int64) error {
+	return m.revoke(func(token *models.RefreshToken) bool { return token.UserID == userID })
+}
+
+func (m *memoryUserStore) revoke(match func(*models.RefreshToken) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	now := time.Now()
+	for _, token := range m.tokens {
+		if token.RevokedAt == nil && match(token) {
+			token.RevokedAt = &now
+		}
+	}
+	return nil
+}
+
+// loginTestUser registers a user in a fresh auth service and logs them in
+func loginTestUser(t *testing.T) (*AuthService, *memoryUserStore, *TokenPair) {
+	t.Helper()
+	ctx := context.Background()
+	store := newMemoryUserStore()
+	s, err := NewAuthService(store, AuthConfig{
+		Secret:          strings.Repeat("s", config.MinJWTSecretLength),
+		AccessTokenTTL:  time.Minute,
+		RefreshTokenTTL: time.Hour,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.Register(ctx, "duelist@example.com", "heart-of-the-cards"); err != nil {
+		t.Fatalf("Register failed: %v", err)
+	}
+	pair, err := s.Login(ctx, "duelist@example.com", "heart-of-the-cards")
+	if err != nil {
+		t.Fatalf("Login failed: %v", err)
+	}
+	return s, store, pair
+}
+
+func TestRefreshRotatesToken(t *testing.T) {
+	ctx := context.Background()
+	s, _, login := loginTestUser(t)
+
+	refreshed, err := s.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	if refreshed.RefreshToken == login.RefreshToken {
+		t.Error("Refresh returned the presented refresh token")
+	}
+	if user, err := s.Authenticate(ctx, refreshed.AccessToken); err != nil || user.Email != "duelist@example.com" {
+		t.Errorf("Authenticate() = %+v, %v", user, err)
+	}
+	if _, err := s.Refresh(ctx, refreshed.RefreshToken); err != nil {
+		t.Errorf("refreshing with the rotated token failed: %v", err)
+	}
+	if _, err := s.Refresh(ctx, "unknown"); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("Refresh(unknown) error = %v, want ErrInvalidToken", err)
+	}
+}
+
+func TestRefreshReuseRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	s, _, login := loginTestUser(t)
+	other, err := s.Login(ctx, "duelist@example.com", "heart-of-the-cards")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	refreshed, err := s.Refresh(ctx, login.RefreshToken)
+	if err != nil {
+		t.Fatalf("Refresh failed: %v", err)
+	}
+	// Presenting the rotated token again means a copy leaked
+	if _, err := s.Refresh(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("reused token error = %v, want ErrInvalidToken", err)
+	}
+	if _, err := s.Refresh(ctx, refreshed.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("token of the reused session still refreshes: %v", err)
+	}
+	if _, err := s.Refresh(ctx, other.RefreshToken); err != nil {
+		t.Errorf("another session was revoked: %v", err)
+	}
+}
+
+func TestRefreshLosingRotationRevokesSession(t *testing.T) {
+	ctx := context.Background()
+	s, store, login := loginTestUser(t)
+
+	store.loseRotation = true
+	if _, err := s.Refresh(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Refresh error = %v, want ErrInvalidToken", err)
+	}
+
+	store.loseRotation = false
+	if _, err := s.Refresh(ctx, login.RefreshToken); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("session survived a lost rotation race: %v", err)
+	}
+}
@@ -1,105 +1,262 @@
 package service
 
 import (
-	"encoding/json"
+	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"index-duel-backend/logging"
+	"index-duel-backend/metrics"
 	"index-duel-backend/models"
 	"index-duel-backend/repository"
-	"io"
-	"log"
-	"net/http"
-	"os"
-	"strings"
+	"index-duel-backend/tracing"
+	"index-duel-backend/upstream"
+	"log/slog"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// CardServiceConfig configures ingest and sync
+type CardServiceConfig struct {
+	// BatchSize is how many cards are stored per transaction
+	BatchSize int
+	// BatchDelay is the pause between batches, to be respectful to image servers
+	BatchDelay time.Duration
+	// FullResyncHorizon is how old a last_update cursor may be before the client is
+	// told to discard its local catalogue and take a full copy instead
+	FullResyncHorizon time.Duration
+}
+
 // CardService handles card-related business logic
 type CardService struct {
-	repo   *repository.CardRepository
-	client *http.Client
-	apiURL string
+	repo              CardStore
+	source            upstream.CardSource
+	batchSize         int
+	batchDelay        time.Duration
+	fullResyncHorizon time.Duration
 }
 
-// NewCardService creates a new card service
-func NewCardService(repo *repository.CardRepository) *CardService {
+// NewCardService creates a new card service. source may be nil, in which case
+// ingest fails.
+func NewCardService(repo CardStore, source upstream.CardSource, cfg CardServiceConfig) *CardService {
 	return &CardService{
-		repo: repo,
-		client: &http.Client{
-			Timeout: 30 * time.Second,
-		},
-		apiURL: os.Getenv("API"),
+		repo:              repo,
+		source:            source,
+		batchSize:         cfg.BatchSize,
+		batchDelay:        cfg.BatchDelay,
+		fullResyncHorizon: cfg.FullResyncHorizon,
 	}
 }
 
-// FetchAndStoreAllCards fetches all cards from the API and stores them in the database
-func (s *CardService) FetchAndStoreAllCards() error {
-	if s.apiURL == "" {
-		return fmt.Errorf("API environment variable is not set")
+// FetchAndStoreAllCards fetches all cards from the upstream source and stores them.
+// Every run gets an ID that is attached to its log records and its ingest_runs row.
+func (s *CardService) FetchAndStoreAllCards(ctx context.Context) (err error) {
+	if s.source == nil {
+		return fmt.Errorf("upstream card source is not configured")
 	}
 
-	log.Printf("Fetching cards from API: %s", s.apiURL)
-
-	resp, err := s.client.Get(s.apiURL)
+	runID, err := newRunID()
 	if err != nil {
-		return fmt.Errorf("failed to fetch cards from API: %w", err)
+		return err
 	}
-	defer resp.Body.Close()
+	ctx = logging.WithAttrs(ctx, slog.String("ingest_run_id", runID))
+
+	ctx, span := tracing.Start(ctx, "CardService.FetchAndStoreAllCards", attribute.String("ingest.run_id", runID))
+	defer tracing.End(span, &err)
 
-	if resp.StatusCode != http.StatusOK {
-		return fmt.Errorf("API returned status code: %d", resp.StatusCode)
+	// Record the run so readiness checks can report the age and outcome of the last ingest
+	recorded := true
+	if err := s.repo.StartIngestRun(ctx, runID, s.source.Name()); err != nil {
+		slog.WarnContext(ctx, "failed to record ingest run", "error", err)
+		recorded = false
 	}
 
-	body, err := io.ReadAll(resp.Body)
+	started := time.Now()
+	processed, failed := 0, 0
+	defer func() {
+		result := "success"
+		run := &models.IngestRun{
+			RunID:          runID,
+			Status:         models.IngestSucceeded,
+			CardsProcessed: processed,
+			CardsFailed:    failed,
+		}
+		if err != nil {
+			result = "error"
+			message := err.Error()
+			run.Status = models.IngestFailed
+			run.Error = &message
+		}
+		metrics.IngestDuration.WithLabelValues(result).Observe(time.Since(started).Seconds())
+		slog.InfoContext(ctx, "ingest run finished", "result", result, "duration", time.Since(started),
+			"cards_processed", processed, "cards_failed", failed)
+
+		if recorded {
+			// The run's own context may be cancelled by now; the outcome should still be stored
+			if finishErr := s.repo.FinishIngestRun(context.WithoutCancel(ctx), run); finishErr != nil {
+				slog.WarnContext(ctx, "failed to record ingest run result", "error", finishErr)
+			}
+		}
+	}()
+
+	slog.InfoContext(ctx, "fetching cards from upstream", "source", s.source.Name())
+
+	catalogue, err := s.source.FetchCatalogue(ctx)
 	if err != nil {
-		return fmt.Errorf("failed to read response body: %w", err)
+		return err
 	}
+	cards := catalogue.Cards
+
+	total := len(cards)
+	slog.InfoContext(ctx, "found cards to process", "cards", total)
 
-	var apiResponse models.APIResponse
-	if err := json.Unmarshal(body, &apiResponse); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON response: %w", err)
+	version := catalogue.Version
+	start := s.resumeIndex(ctx, cards, version)
+	if start > 0 {
+		slog.InfoContext(ctx, "resuming ingest", "version", version, "card", start+1, "cards", total)
 	}
 
-	log.Printf("Found %d cards to process", len(apiResponse.Data))
+	// Once a card fails, the checkpoint stays before it for the rest of the run, so
+	// that a resumed run retries it
+	stalled := false
 
 	// Process cards in batches to avoid overwhelming the system
-	batchSize := 10
-	for i := 0; i < len(apiResponse.Data); i += batchSize {
-		end := i + batchSize
-		if end > len(apiResponse.Data) {
-			end = len(apiResponse.Data)
+	for i := start; i < len(cards); i += s.batchSize {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		end := i + s.batchSize
+		if end > len(cards) {
+			end = len(cards)
+		}
+
+		batch := cards[i:end]
+		slog.DebugContext(ctx, "processing batch", "first", i+1, "last", end, "cards", total)
+
+		for j := range batch {
+			s.downloadCardImages(ctx, &batch[j])
 		}
 
-		batch := apiResponse.Data[i:end]
-		log.Printf("Processing batch %d-%d of %d cards", i+1, end, len(apiResponse.Data))
+		firstFailed := -1
+		if err := s.repo.CreateCards(ctx, batch); err != nil {
+			// Fall back to per-card writes so one bad card does not drop the whole batch
+			slog.WarnContext(ctx, "failed to store batch, retrying card by card", "first", i+1, "last", end, "error", err)
+			for j := range batch {
+				card := &batch[j]
+				if err := s.repo.CreateCard(ctx, card); err != nil {
+					// Continue processing other cards even if one fails
+					slog.ErrorContext(ctx, "failed to store card", "card_id", card.ID, "card_name", card.Name, "error", err)
+					metrics.IngestCardsFailed.Inc()
+					failed++
+					if firstFailed < 0 {
+						firstFailed = i + j
+					}
+					continue
+				}
+				metrics.IngestCardsProcessed.Inc()
+				processed++
+			}
+		} else {
+			metrics.IngestCardsProcessed.Add(float64(len(batch)))
+			processed += len(batch)
+		}
+		slog.InfoContext(ctx, "stored batch", "first", i+1, "last", end, "cards", total)
+
+		// Drop the downloaded image data so it is not held for the rest of the run
+		for j := range batch {
+			batch[j].CardImages = nil
+		}
 
-		for _, card := range batch {
-			if err := s.ProcessCard(&card); err != nil {
-				log.Printf("Error processing card %d (%s): %v", card.ID, card.Name, err)
-				// Continue processing other cards even if one fails
-				continue
+		if !stalled {
+			last := end - 1
+			if firstFailed >= 0 {
+				last = firstFailed - 1
+				stalled = true
+			}
+			if last >= i {
+				s.saveCheckpoint(ctx, version, cards, last)
 			}
-			log.Printf("Successfully processed card: %s (ID: %d)", card.Name, card.ID)
 		}
 
 		// Add a small delay between batches to be respectful to image servers
-		time.Sleep(1 * time.Second)
+		if end < len(cards) {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(s.batchDelay):
+			}
+		}
 	}
 
-	log.Printf("Completed processing all cards")
+	// A run that stored nothing must not count as a fresh ingest for readiness
+	// checks, nor trigger a bundle rebuild
+	if failed > 0 && processed == 0 {
+		return fmt.Errorf("failed to store any of %d cards", failed)
+	}
 	return nil
 }
 
-// ProcessCard processes a single card, downloads images, and stores in database
-func (s *CardService) ProcessCard(card *models.Card) error {
-	// Download images for the card
+// saveCheckpoint records that every card up to and including cards[last] is stored
+func (s *CardService) saveCheckpoint(ctx context.Context, version string, cards []models.Card, last int) {
+	checkpoint := &models.IngestCheckpoint{
+		Source:          s.source.Name(),
+		UpstreamVersion: version,
+		LastIndex:       last,
+		LastCardID:      cards[last].ID,
+		Completed:       last == len(cards)-1,
+	}
+	if err := s.repo.SaveIngestCheckpoint(ctx, checkpoint); err != nil {
+		slog.WarnContext(ctx, "failed to save ingest checkpoint", "card", last+1, "error", err)
+	}
+}
+
+// resumeIndex returns the index to start ingesting from, based on the stored checkpoint.
+// A run only resumes when the checkpoint belongs to the same catalogue version and the
+// card it recorded is still found where it was left.
+func (s *CardService) resumeIndex(ctx context.Context, cards []models.Card, version string) int {
+	cp, err := s.repo.GetIngestCheckpoint(ctx, s.source.Name())
+	if err != nil {
+		slog.WarnContext(ctx, "failed to load ingest checkpoint, starting from the beginning", "error", err)
+		return 0
+	}
+	if cp == nil || cp.Completed || cp.UpstreamVersion != version {
+		return 0
+	}
+
+	if cp.LastIndex >= 0 && cp.LastIndex < len(cards) && cards[cp.LastIndex].ID == cp.LastCardID {
+		return cp.LastIndex + 1
+	}
+	for i := range cards {
+		if cards[i].ID == cp.LastCardID {
+			return i + 1
+		}
+	}
+
+	slog.WarnContext(ctx, "ingest checkpoint card not found in catalogue, starting from the beginning", "card_id", cp.LastCardID)
+	return 0
+}
+
+// newRunID returns a random ID for an ingest run
+func newRunID() (string, error) {
+	var b [8]byte
+	if _, err := rand.Read(b[:]); err != nil {
+		return "", fmt.Errorf("failed to generate run id: %w", err)
+	}
+	return hex.EncodeToString(b[:]), nil
+}
+
+// downloadCardImages fills in the image data for every image of the card.
+// Failed downloads are logged and leave the corresponding data empty.
+func (s *CardService) downloadCardImages(ctx context.Context, card *models.Card) {
 	for i := range card.CardImages {
 		image := &card.CardImages[i]
 
 		// Download main image
 		if image.ImageURL != "" {
-			data, contentType, size, err := s.downloadImage(image.ImageURL)
+			data, contentType, size, err := s.downloadImage(ctx, image.ImageURL)
 			if err != nil {
-				log.Printf("Failed to download main image for card %d: %v", card.ID, err)
+				slog.WarnContext(ctx, "failed to download image", "card_id", card.ID, "variant", "main", "error", err)
 			} else {
 				image.ImageData = data
 				image.ContentType = contentType
@@ -109,9 +266,9 @@ func (s *CardService) ProcessCard(card *models.Card) error {
 
 		// Download small image
 		if image.ImageURLSmall != "" {
-			data, _, _, err := s.downloadImage(image.ImageURLSmall)
+			data, _, _, err := s.downloadImage(ctx, image.ImageURLSmall)
 			if err != nil {
-				log.Printf("Failed to download small image for card %d: %v", card.ID, err)
+				slog.WarnContext(ctx, "failed to download image", "card_id", card.ID, "variant", "small", "error", err)
 			} else {
 				image.ImageSmallData = data
 			}
@@ -119,79 +276,131 @@ func (s *CardService) ProcessCard(card *models.Card) error {
 
 		// Download cropped image
 		if image.ImageURLCropped != "" {
-			data, _, _, err := s.downloadImage(image.ImageURLCropped)
+			data, _, _, err := s.downloadImage(ctx, image.ImageURLCropped)
 			if err != nil {
-				log.Printf("Failed to download cropped image for card %d: %v", card.ID, err)
+				slog.WarnContext(ctx, "failed to download image", "card_id", card.ID, "variant", "cropped", "error", err)
 			} else {
 				image.ImageCroppedData = data
 			}
 		}
 	}
-
-	// Store the card in the database
-	return s.repo.CreateCard(card)
 }
 
-// downloadImage downloads an image from a URL and returns its data
-func (s *CardService) downloadImage(url string) ([]byte, string, int, error) {
-	resp, err := s.client.Get(url)
+// downloadImage downloads an image from the source and returns its data
+func (s *CardService) downloadImage(ctx context.Context, url string) ([]byte, string, int, error) {
+	data, contentType, err := s.source.FetchImage(ctx, url)
 	if err != nil {
-		return nil, "", 0, fmt.Errorf("failed to download image: %w", err)
+		metrics.ImageDownloadErrors.Inc()
+		return nil, "", 0, err
 	}
-	defer resp.Body.Close()
+	metrics.ImageDownloadBytes.Add(float64(len(data)))
+	return data, contentType, len(data), nil
+}
 
-	if resp.StatusCode != http.StatusOK {
-		return nil, "", 0, fmt.Errorf("image download returned status code: %d", resp.StatusCode)
-	}
+// GetCard returns a single card with the selected related rows, or nil if it does not exist
+func (s *CardService) GetCard(ctx context.Context, cardID int64, relations models.CardRelations) (_ *models.Card, err error) {
+	ctx, span := tracing.Start(ctx, "CardService.GetCard", attribute.Int64("card.id", cardID))
+	defer tracing.End(span, &err)
 
-	data, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, "", 0, fmt.Errorf("failed to read image data: %w", err)
-	}
+	return s.repo.GetCard(ctx, cardID, relations)
+}
 
-	contentType := resp.Header.Get("Content-Type")
-	if contentType == "" {
-		// Try to determine content type from URL extension
-		if strings.HasSuffix(strings.ToLower(url), ".jpg") || strings.HasSuffix(strings.ToLower(url), ".jpeg") {
-			contentType = "image/jpeg"
-		} else if strings.HasSuffix(strings.ToLower(url), ".png") {
-			contentType = "image/png"
-		} else {
-			contentType = "image/jpeg" // default
-		}
-	}
+// GetCardCount returns the total count of cards
+func (s *CardService) GetCardCount(ctx context.Context) (_ int, err error) {
+	ctx, span := tracing.Start(ctx, "CardService.GetCardCount")
+	defer tracing.End(span, &err)
 
-	return data, contentType, len(data), nil
+	return s.repo.GetCardCount(ctx)
 }
 
-// GetCardCount returns the total count of cards
-func (s *CardService) GetCardCount() (int, error) {
-	return s.repo.GetCardCount()
+// SyncResult is the outcome of a sync request. Cards must be closed by the caller.
+type SyncResult struct {
+	Cards      *repository.CardIterator
+	LastUpdate string
+	Seq        int64
+	// FullResyncRequired tells the client that Cards holds the whole catalogue and
+	// should replace its local copy rather than be merged into it
+	FullResyncRequired bool
 }
 
-// SyncCards handles card synchronization requests from mobile app
-func (s *CardService) SyncCards(lastUpdate string) ([]models.Card, string, error) {
-	currentTime := time.Now().UTC().Format(time.RFC3339)
+// IsFullSync reports whether a sync request will be answered with the whole
+// catalogue: a first sync, or a last_update cursor past the full resync horizon.
+// Malformed cursors report false, since SyncCards rejects them.
+func (s *CardService) IsFullSync(req models.SyncRequest) bool {
+	if req.SinceSeq != nil {
+		return false
+	}
+	if req.LastUpdate == "" {
+		return true
+	}
+	lastUpdate, err := ParseLastUpdate(req.LastUpdate)
+	return err == nil && s.pastResyncHorizon(lastUpdate, time.Now().UTC())
+}
 
-	var cards []models.Card
-	var err error
+// pastResyncHorizon reports whether a client last synced too long ago to be patched up
+func (s *CardService) pastResyncHorizon(lastUpdate, now time.Time) bool {
+	return now.Sub(lastUpdate) > s.fullResyncHorizon
+}
 
-	if lastUpdate == "" {
-		// New client - send all cards
-		log.Println("New client detected, sending all cards")
-		cards, err = s.repo.GetAllCardsForFirstSync()
-		if err != nil {
-			return nil, "", fmt.Errorf("failed to get all cards: %w", err)
+// SyncCards handles card synchronization requests from mobile app. The returned
+// iterator streams the matching cards, and Seq is the cursor for the next sync.
+// A missing, null or empty last_update without since_seq means a first sync.
+// Malformed cursors are reported as *InvalidRequestError. Only the selected related
+// rows are loaded with each card.
+func (s *CardService) SyncCards(ctx context.Context, req models.SyncRequest, relations models.CardRelations) (_ *SyncResult, err error) {
+	// The iterator outlives this call, so its queries are traced under ctx rather
+	// than under this span
+	_, span := tracing.Start(ctx, "CardService.SyncCards")
+	defer tracing.End(span, &err)
+
+	now := time.Now().UTC()
+
+	var lastUpdate time.Time
+	fullResync := false
+	if req.SinceSeq != nil {
+		if err := ValidateSinceSeq(*req.SinceSeq); err != nil {
+			return nil, err
 		}
-	} else {
-		// Existing client - send only updated cards
-		log.Printf("Existing client detected, sending cards updated after: %s", lastUpdate)
-		cards, err = s.repo.GetCardsUpdatedAfter(lastUpdate)
+	} else if req.LastUpdate != "" {
+		parsed, err := ParseLastUpdate(req.LastUpdate)
 		if err != nil {
-			return nil, "", fmt.Errorf("failed to get updated cards: %w", err)
+			return nil, err
 		}
+		lastUpdate = parsed
+		fullResync = s.pastResyncHorizon(lastUpdate, now)
+	}
+
+	var cards *repository.CardIterator
+	var seq int64
+
+	switch {
+	case fullResync:
+		// Client is too far behind to patch up - send all cards
+		slog.InfoContext(ctx, "client is past the full resync horizon, sending all cards",
+			"last_update", lastUpdate.Format(time.RFC3339), "horizon", s.fullResyncHorizon)
+		cards, seq, err = s.repo.GetAllCardsForFirstSync(ctx, relations)
+	case req.SinceSeq != nil:
+		// Existing client - send cards changed since its last sequence number
+		slog.DebugContext(ctx, "sending cards changed since sequence", "since_seq", *req.SinceSeq)
+		cards, seq, err = s.repo.GetCardsChangedSince(ctx, *req.SinceSeq, relations)
+	case req.LastUpdate == "":
+		// New client - send all cards
+		slog.DebugContext(ctx, "new client, sending all cards")
+		cards, seq, err = s.repo.GetAllCardsForFirstSync(ctx, relations)
+	default:
+		// Legacy client - send only cards updated after its timestamp
+		slog.DebugContext(ctx, "sending cards updated after timestamp", "last_update", lastUpdate.Format(time.RFC3339Nano))
+		cards, seq, err = s.repo.GetCardsUpdatedAfter(ctx, lastUpdate, relations)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to get cards: %w", err)
 	}
+	span.SetAttributes(attribute.Bool("sync.full_resync", fullResync), attribute.Int64("sync.seq", seq))
 
-	log.Printf("Returning %d cards to client", len(cards))
-	return cards, currentTime, nil
+	return &SyncResult{
+		Cards:              cards,
+		LastUpdate:         lastUpdateCursor(cards),
+		Seq:                seq,
+		FullResyncRequired: fullResync,
+	}, nil
 }
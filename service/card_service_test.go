@@ -0,0 +1,318 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"index-duel-backend/upstream"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// stubSource serves a fixed catalogue, and every image as the bytes of its URL
+type stubSource struct {
+	catalogue *upstream.Catalogue
+	err       error
+}
+
+func (s *stubSource) Name() string { return "stub" }
+
+func (s *stubSource) FetchCatalogue(ctx context.Context) (*upstream.Catalogue, error) {
+	if s.err != nil {
+		return nil, s.err
+	}
+	return s.catalogue, nil
+}
+
+func (s *stubSource) FetchImage(ctx context.Context, url string) ([]byte, string, error) {
+	return []byte(url), "image/jpeg", nil
+}
+
+func stubCatalogue(n int) *upstream.Catalogue {
+	cards := make([]models.Card, n)
+	for i := range cards {
+		id := int64(1000 + i)
+		cards[i] = models.Card{
+			ID:         id,
+			Name:       "Card " + strconv.Itoa(i),
+			CardImages: []models.CardImage{{ImageURLSmall: "https://images.example.com/small/" + strconv.Itoa(i) + ".jpg"}},
+		}
+	}
+	return &upstream.Catalogue{Cards: cards, Version: "v1"}
+}
+
+func TestFetchAndStoreAllCards(t *testing.T) {
+	tests := []struct {
+		name         string
+		checkpoint   *models.IngestCheckpoint
+		sourceErr    error
+		rejectWrites bool
+		wantCards    int
+		wantStatus   string
+	}{
+		{name: "fresh ingest", wantCards: 5, wantStatus: models.IngestSucceeded},
+		{name: "resumes same version",
+			checkpoint: &models.IngestCheckpoint{Source: "stub", UpstreamVersion: "v1", LastIndex: 2, LastCardID: 1002},
+			wantCards:  2, wantStatus: models.IngestSucceeded},
+		{name: "restarts on a new version",
+			checkpoint: &models.IngestCheckpoint{Source: "stub", UpstreamVersion: "v0", LastIndex: 2, LastCardID: 1002},
+			wantCards:  5, wantStatus: models.IngestSucceeded},
+		{name: "restarts after a completed run",
+			checkpoint: &models.IngestCheckpoint{Source: "stub", UpstreamVersion: "v1", LastIndex: 4, LastCardID: 1004, Completed: true},
+			wantCards:  5, wantStatus: models.IngestSucceeded},
+		{name: "source failure", sourceErr: errors.New("upstream returned 502"), wantStatus: models.IngestFailed},
+		{name: "store rejects every write", rejectWrites: true, wantStatus: models.IngestFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			memory := repository.NewMemoryCardStore()
+			var store CardStore = memory
+			if tt.rejectWrites {
+				store = &rejectingStore{memory}
+			}
+			if tt.checkpoint != nil {
+				memory.SaveIngestCheckpoint(ctx, tt.checkpoint)
+			}
+			// Ingest drops image data from stored cards, so every run gets its own catalogue
+			source := &stubSource{catalogue: stubCatalogue(5), err: tt.sourceErr}
+			svc := NewCardService(store, source, CardServiceConfig{BatchSize: 2})
+
+			err := svc.FetchAndStoreAllCards(ctx)
+			if (err != nil) != (tt.wantStatus == models.IngestFailed) {
+				t.Fatalf("FetchAndStoreAllCards error = %v", err)
+			}
+
+			if count, _ := memory.GetCardCount(ctx); count != tt.wantCards {
+				t.Errorf("stored %d cards, want %d", count, tt.wantCards)
+			}
+			run, _ := memory.LastIngestRun(ctx)
+			if run == nil || run.Status != tt.wantStatus || run.CardsProcessed != tt.wantCards {
+				t.Fatalf("last run = %+v, want status %s with %d cards", run, tt.wantStatus, tt.wantCards)
+			}
+			if tt.wantStatus == models.IngestFailed {
+				return
+			}
+
+			cp, _ := memory.GetIngestCheckpoint(ctx, "stub")
+			if cp == nil || !cp.Completed || cp.LastCardID != 1004 || cp.UpstreamVersion != "v1" {
+				t.Errorf("checkpoint = %+v, want the completed v1 catalogue", cp)
+			}
+			var images int
+			memory.ForEachSmallImage(ctx, func(int, int64, []byte) error { images++; return nil })
+			if images != tt.wantCards {
+				t.Errorf("stored %d small images, want %d", images, tt.wantCards)
+			}
+		})
+	}
+}
+
+// rejectingStore fails every card write, as happens when the database goes down
+// mid-ingest
+type rejectingStore struct {
+	*repository.MemoryCardStore
+}
+
+func (s *rejectingStore) CreateCard(ctx context.Context, card *models.Card) error {
+	return errCardRejected
+}
+
+func (s *rejectingStore) CreateCards(ctx context.Context, cards []models.Card) error {
+	return errCardRejected
+}
+
+// flakyStore fails every write that includes the card failID
+type flakyStore struct {
+	*repository.MemoryCardStore
+	failID int64
+}
+
+var errCardRejected = errors.New("card rejected")
+
+func (s *flakyStore) CreateCard(ctx context.Context, card *models.Card) error {
+	if card.ID == s.failID {
+		return errCardRejected
+	}
+	return s.MemoryCardStore.CreateCard(ctx, card)
+}
+
+func (s *flakyStore) CreateCards(ctx context.Context, cards []models.Card) error {
+	for i := range cards {
+		if cards[i].ID == s.failID {
+			return errCardRejected
+		}
+	}
+	return s.MemoryCardStore.CreateCards(ctx, cards)
+}
+
+// TestFailedCardIsRetriedOnResume checks that the checkpoint never passes a card
+// that failed to store, so the next run picks up from it
+func TestFailedCardIsRetriedOnResume(t *testing.T) {
+	ctx := context.Background()
+	store := &flakyStore{MemoryCardStore: repository.NewMemoryCardStore(), failID: 1002}
+	svc := NewCardService(store, &stubSource{catalogue: stubCatalogue(5)}, CardServiceConfig{BatchSize: 2})
+
+	if err := svc.FetchAndStoreAllCards(ctx); err != nil {
+		t.Fatalf("first run failed: %v", err)
+	}
+	cp, _ := store.GetIngestCheckpoint(ctx, "stub")
+	if cp == nil || cp.Completed || cp.LastIndex != 1 || cp.LastCardID != 1001 {
+		t.Fatalf("checkpoint = %+v, want it held at card 1001 before the failure", cp)
+	}
+	if run, _ := store.LastIngestRun(ctx); run == nil || run.CardsFailed != 1 || run.CardsProcessed != 4 {
+		t.Fatalf("first run = %+v, want 4 processed and 1 failed", run)
+	}
+
+	store.failID = 0
+	svc = NewCardService(store, &stubSource{catalogue: stubCatalogue(5)}, CardServiceConfig{BatchSize: 2})
+	if err := svc.FetchAndStoreAllCards(ctx); err != nil {
+		t.Fatalf("second run failed: %v", err)
+	}
+	if run, _ := store.LastIngestRun(ctx); run == nil || run.CardsProcessed != 3 || run.CardsFailed != 0 {
+		t.Errorf("second run = %+v, want it to resume at the failed card and store 3", run)
+	}
+	if card, _ := store.GetCard(ctx, 1002, models.CardRelations{}); card == nil {
+		t.Error("failed card was not retried")
+	}
+	if cp, _ := store.GetIngestCheckpoint(ctx, "stub"); cp == nil || !cp.Completed {
+		t.Errorf("checkpoint = %+v, want completed", cp)
+	}
+}
+
+// cancellingStore cancels the ingest once its first batch is stored
+type cancellingStore struct {
+	*repository.MemoryCardStore
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStore) CreateCards(ctx context.Context, cards []models.Card) error {
+	defer s.cancel()
+	return s.MemoryCardStore.CreateCards(ctx, cards)
+}
+
+func TestFetchAndStoreAllCardsStopsWhenCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	store := &cancellingStore{MemoryCardStore: repository.NewMemoryCardStore(), cancel: cancel}
+	svc := NewCardService(store, &stubSource{catalogue: stubCatalogue(5)}, CardServiceConfig{BatchSize: 2, BatchDelay: time.Hour})
+
+	if err := svc.FetchAndStoreAllCards(ctx); !errors.Is(err, context.Canceled) {
+		t.Fatalf("FetchAndStoreAllCards error = %v, want context.Canceled", err)
+	}
+	if run, _ := store.LastIngestRun(ctx); run == nil || run.Status != models.IngestFailed || run.CardsProcessed != 2 {
+		t.Errorf("last run = %+v, want a failed run that stored 2 cards", run)
+	}
+}
+
+func TestFetchAndStoreAllCardsWithoutSource(t *testing.T) {
+	svc := NewCardService(repository.NewMemoryCardStore(), nil, CardServiceConfig{BatchSize: 1})
+	if err := svc.FetchAndStoreAllCards(context.Background()); err == nil {
+		t.Error("ingest without a source succeeded")
+	}
+}
+
+func TestSyncCards(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryCardStore()
+	for id := int64(1); id <= 3; id++ {
+		store.CreateCard(ctx, &models.Card{ID: id, Name: "Card " + strconv.FormatInt(id, 10)})
+	}
+	svc := NewCardService(store, nil, CardServiceConfig{FullResyncHorizon: 90 * 24 * time.Hour})
+
+	seq := func(n int64) *int64 { return &n }
+	now := time.Now().UTC()
+
+	tests := []struct {
+		name        string
+		req         models.SyncRequest
+		wantCards   int
+		wantFull    bool
+		wantErrCode string
+	}{
+		{"first sync", models.SyncRequest{}, 3, false, ""},
+		{"since zero", models.SyncRequest{SinceSeq: seq(0)}, 3, false, ""},
+		{"since first card", models.SyncRequest{SinceSeq: seq(1)}, 2, false, ""},
+		{"up to date", models.SyncRequest{SinceSeq: seq(3)}, 0, false, ""},
+		{"since_seq wins over last_update", models.SyncRequest{SinceSeq: seq(2), LastUpdate: "garbage"}, 1, false, ""},
+		{"recent last_update", models.SyncRequest{LastUpdate: now.Add(-time.Hour).Format(time.RFC3339)}, 3, false, ""},
+		{"future last_update", models.SyncRequest{LastUpdate: now.Add(time.Hour).Format(time.RFC3339)}, 0, false, ""},
+		{"epoch seconds", models.SyncRequest{LastUpdate: strconv.FormatInt(now.Add(-time.Hour).Unix(), 10)}, 3, false, ""},
+		{"past resync horizon", models.SyncRequest{LastUpdate: now.AddDate(-1, 0, 0).Format(time.RFC3339)}, 3, true, ""},
+		{"negative since_seq", models.SyncRequest{SinceSeq: seq(-1)}, 0, false, ErrCodeInvalidSinceSeq},
+		{"malformed last_update", models.SyncRequest{LastUpdate: "yesterday"}, 0, false, ErrCodeInvalidLastUpdate},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := svc.SyncCards(ctx, tt.req, models.AllCardRelations)
+			if tt.wantErrCode != "" {
+				var invalid *InvalidRequestError
+				if !errors.As(err, &invalid) || invalid.Code != tt.wantErrCode {
+					t.Fatalf("error = %v, want %s", err, tt.wantErrCode)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("SyncCards failed: %v", err)
+			}
+			defer result.Cards.Close()
+
+			cards := 0
+			for result.Cards.Next() {
+				cards++
+			}
+			if cards != tt.wantCards {
+				t.Errorf("cards = %d, want %d", cards, tt.wantCards)
+			}
+			if result.Seq != 3 {
+				t.Errorf("seq = %d, want 3", result.Seq)
+			}
+			if result.FullResyncRequired != tt.wantFull {
+				t.Errorf("full_resync_required = %t, want %t", result.FullResyncRequired, tt.wantFull)
+			}
+			if full := svc.IsFullSync(tt.req); full != (tt.wantFull || tt.req == models.SyncRequest{}) {
+				t.Errorf("IsFullSync = %t", full)
+			}
+		})
+	}
+}
+
+func TestSyncLastUpdateComesFromSnapshot(t *testing.T) {
+	ctx := context.Background()
+	store := repository.NewMemoryCardStore()
+	svc := NewCardService(store, nil, CardServiceConfig{FullResyncHorizon: 90 * 24 * time.Hour})
+
+	result, err := svc.SyncCards(ctx, models.SyncRequest{}, models.AllCardRelations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Cards.Close()
+	if result.LastUpdate != "" {
+		t.Errorf("last_update for an empty catalogue = %q, want empty so the next sync starts over", result.LastUpdate)
+	}
+
+	store.CreateCard(ctx, &models.Card{ID: 1, Name: "Card 1"})
+	card, err := store.GetCard(ctx, 1, models.CardRelations{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	result, err = svc.SyncCards(ctx, models.SyncRequest{}, models.AllCardRelations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	result.Cards.Close()
+	if want := card.UpdatedAt.UTC().Format(time.RFC3339); result.LastUpdate != want {
+		t.Errorf("last_update = %q, want the newest card timestamp %q", result.LastUpdate, want)
+	}
+
+	// The cursor is truncated to the second, so resuming from it resends rather than skips
+	result, err = svc.SyncCards(ctx, models.SyncRequest{LastUpdate: result.LastUpdate}, models.AllCardRelations)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer result.Cards.Close()
+	if !result.Cards.Next() {
+		t.Error("resuming from last_update skipped the card it was derived from")
+	}
+}
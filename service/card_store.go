@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"time"
+)
+
+// CardStore is the card storage CardService needs. repository.CardRepository
+// implements it on Postgres and repository.MemoryCardStore in memory.
+type CardStore interface {
+	CreateCard(ctx context.Context, card *models.Card) error
+	CreateCards(ctx context.Context, cards []models.Card) error
+	GetCard(ctx context.Context, cardID int64, relations models.CardRelations) (*models.Card, error)
+	GetCardCount(ctx context.Context) (int, error)
+
+	GetCardsUpdatedAfter(ctx context.Context, lastUpdate time.Time, relations models.CardRelations) (*repository.CardIterator, int64, error)
+	GetCardsChangedSince(ctx context.Context, sinceSeq int64, relations models.CardRelations) (*repository.CardIterator, int64, error)
+	GetAllCardsForFirstSync(ctx context.Context, relations models.CardRelations) (*repository.CardIterator, int64, error)
+
+	GetIngestCheckpoint(ctx context.Context, source string) (*models.IngestCheckpoint, error)
+	SaveIngestCheckpoint(ctx context.Context, cp *models.IngestCheckpoint) error
+	StartIngestRun(ctx context.Context, runID, source string) error
+	FinishIngestRun(ctx context.Context, run *models.IngestRun) error
+}
+
+var (
+	_ CardStore = (*repository.CardRepository)(nil)
+	_ CardStore = (*repository.MemoryCardStore)(nil)
+)
+
+// CatalogueStatus is the card storage HealthService reads to judge readiness
+type CatalogueStatus interface {
+	HasCards(ctx context.Context) (bool, error)
+	LastIngestRun(ctx context.Context) (*models.IngestRun, error)
+	LastSuccessfulIngestRun(ctx context.Context) (*models.IngestRun, error)
+}
+
+var (
+	_ CatalogueStatus = (*repository.CardRepository)(nil)
+	_ CatalogueStatus = (*repository.MemoryCardStore)(nil)
+)
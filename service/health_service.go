@@ -0,0 +1,240 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/database"
+	"index-duel-backend/models"
+	"time"
+)
+
+// Check statuses, from best to worst. A warning is reported but does not make the
+// service unready.
+const (
+	CheckOK   = "ok"
+	CheckWarn = "warn"
+	CheckFail = "fail"
+)
+
+// requiredTables are the tables the service cannot run without
+var requiredTables = []string{
+	"cards", "card_sets", "card_images", "card_prices",
+	"ingest_checkpoints", "ingest_runs", "api_keys", "users", "refresh_tokens",
+	"rate_limit_buckets",
+}
+
+// CheckResult is the outcome of a single readiness check
+type CheckResult struct {
+	Status     string                 `json:"status"`
+	Message    string                 `json:"message,omitempty"`
+	Details    map[string]interface{} `json:"details,omitempty"`
+	DurationMS float64                `json:"duration_ms"`
+}
+
+// ReadinessReport is the overall readiness status along with every check result
+type ReadinessReport struct {
+	Status string                 `json:"status"`
+	Checks map[string]CheckResult `json:"checks"`
+}
+
+// Ready reports whether no check failed
+func (r *ReadinessReport) Ready() bool {
+	return r.Status != CheckFail
+}
+
+// HealthConfig holds the readiness thresholds
+type HealthConfig struct {
+	// CheckTimeout bounds each check
+	CheckTimeout time.Duration
+	// IngestWarnAge is how old the last successful ingest may be before readiness warns
+	IngestWarnAge time.Duration
+	// IngestMaxAge is how old it may be before readiness fails; zero never fails
+	IngestMaxAge time.Duration
+}
+
+// HealthDB is the database access HealthService needs. database.DB implements it.
+type HealthDB interface {
+	PingContext(ctx context.Context) error
+	MissingTables(ctx context.Context, names []string) ([]string, error)
+	Replicas() []*database.Replica
+}
+
+var _ HealthDB = (*database.DB)(nil)
+
+// HealthService checks whether the service's dependencies are usable
+type HealthService struct {
+	db   HealthDB
+	repo CatalogueStatus
+	cfg  HealthConfig
+}
+
+// NewHealthService creates a new health service
+func NewHealthService(db HealthDB, repo CatalogueStatus, cfg HealthConfig) *HealthService {
+	return &HealthService{
+		db:   db,
+		repo: repo,
+		cfg:  cfg,
+	}
+}
+
+// Readiness runs every check in turn. Later checks are skipped when the database
+// cannot be reached, since they would only repeat the same failure.
+func (s *HealthService) Readiness(ctx context.Context) *ReadinessReport {
+	report := &ReadinessReport{Checks: make(map[string]CheckResult)}
+
+	report.Checks["database"] = s.run(ctx, s.checkDatabase)
+	if report.Checks["database"].Status == CheckFail {
+		skipped := CheckResult{Status: CheckFail, Message: "skipped, database unreachable"}
+		report.Checks["schema"] = skipped
+		report.Checks["ingest"] = skipped
+		report.Checks["catalogue"] = skipped
+	} else {
+		report.Checks["schema"] = s.run(ctx, s.checkSchema)
+		report.Checks["ingest"] = s.run(ctx, s.checkIngest)
+		report.Checks["catalogue"] = s.run(ctx, s.checkCatalogue)
+	}
+	if replicas := s.db.Replicas(); len(replicas) > 0 {
+		statuses := make([]database.ReplicaStatus, len(replicas))
+		for i, r := range replicas {
+			statuses[i] = r.Status()
+		}
+		report.Checks["replicas"] = evaluateReplicas(statuses)
+	}
+
+	report.Status = worstStatus(report.Checks)
+	return report
+}
+
+// run executes a check under the check timeout and records how long it took
+func (s *HealthService) run(ctx context.Context, check func(context.Context) CheckResult) CheckResult {
+	ctx, cancel := context.WithTimeout(ctx, s.cfg.CheckTimeout)
+	defer cancel()
+
+	started := time.Now()
+	result := check(ctx)
+	result.DurationMS = float64(time.Since(started).Microseconds()) / 1000
+	return result
+}
+
+func (s *HealthService) checkDatabase(ctx context.Context) CheckResult {
+	if err := s.db.PingContext(ctx); err != nil {
+		return CheckResult{Status: CheckFail, Message: fmt.Sprintf("ping failed: %v", err)}
+	}
+	return CheckResult{Status: CheckOK}
+}
+
+func (s *HealthService) checkSchema(ctx context.Context) CheckResult {
+	missing, err := s.db.MissingTables(ctx, requiredTables)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: err.Error()}
+	}
+	if len(missing) > 0 {
+		return CheckResult{
+			Status:  CheckFail,
+			Message: "required tables are missing",
+			Details: map[string]interface{}{"missing_tables": missing},
+		}
+	}
+	return CheckResult{Status: CheckOK}
+}
+
+func (s *HealthService) checkIngest(ctx context.Context) CheckResult {
+	last, err := s.repo.LastIngestRun(ctx)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: err.Error()}
+	}
+	lastSuccess, err := s.repo.LastSuccessfulIngestRun(ctx)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: err.Error()}
+	}
+	return evaluateIngest(last, lastSuccess, time.Now(), s.cfg.IngestWarnAge, s.cfg.IngestMaxAge)
+}
+
+func (s *HealthService) checkCatalogue(ctx context.Context) CheckResult {
+	hasCards, err := s.repo.HasCards(ctx)
+	if err != nil {
+		return CheckResult{Status: CheckFail, Message: err.Error()}
+	}
+	if !hasCards {
+		// Serving an empty catalogue would make first syncs store nothing
+		return CheckResult{Status: CheckFail, Message: "catalogue is empty"}
+	}
+	return CheckResult{Status: CheckOK}
+}
+
+// evaluateIngest grades the ingest history: the age of the last successful run is
+// compared against warnAge and maxAge, and a failed latest run is a warning
+func evaluateIngest(last, lastSuccess *models.IngestRun, now time.Time, warnAge, maxAge time.Duration) CheckResult {
+	result := CheckResult{Status: CheckOK, Details: map[string]interface{}{}}
+	if last != nil {
+		result.Details["last_run_id"] = last.RunID
+		result.Details["last_status"] = last.Status
+		result.Details["last_started_at"] = last.StartedAt.UTC().Format(time.RFC3339)
+		if last.Error != nil {
+			result.Details["last_error"] = *last.Error
+		}
+	}
+
+	if lastSuccess == nil || lastSuccess.FinishedAt == nil {
+		result.Status = CheckWarn
+		result.Message = "no successful ingest recorded"
+		return result
+	}
+
+	age := now.Sub(*lastSuccess.FinishedAt)
+	result.Details["last_success_at"] = lastSuccess.FinishedAt.UTC().Format(time.RFC3339)
+	result.Details["age_seconds"] = int64(age.Seconds())
+
+	switch {
+	case maxAge > 0 && age > maxAge:
+		result.Status = CheckFail
+		result.Message = fmt.Sprintf("last successful ingest is older than %s", maxAge)
+	case age > warnAge:
+		result.Status = CheckWarn
+		result.Message = fmt.Sprintf("last successful ingest is older than %s", warnAge)
+	case last != nil && last.Status == models.IngestFailed:
+		result.Status = CheckWarn
+		result.Message = "last ingest failed"
+	}
+	return result
+}
+
+// evaluateReplicas grades the latest replica lag checks. A replica out of rotation is
+// only a warning, since its reads fall back to the primary.
+func evaluateReplicas(statuses []database.ReplicaStatus) CheckResult {
+	result := CheckResult{Status: CheckOK, Details: map[string]interface{}{}}
+	var unusable []string
+	for _, status := range statuses {
+		detail := map[string]interface{}{
+			"usable":      status.Usable,
+			"lag_seconds": status.Lag.Seconds(),
+		}
+		if status.Error != "" {
+			detail["error"] = status.Error
+		}
+		result.Details[status.Name] = detail
+		if !status.Usable {
+			unusable = append(unusable, status.Name)
+		}
+	}
+
+	if len(unusable) > 0 {
+		result.Status = CheckWarn
+		result.Message = fmt.Sprintf("reads fall back to the primary for %d of %d replicas", len(unusable), len(statuses))
+	}
+	return result
+}
+
+// worstStatus returns the most severe status among checks
+func worstStatus(checks map[string]CheckResult) string {
+	status := CheckOK
+	for _, check := range checks {
+		switch check.Status {
+		case CheckFail:
+			return CheckFail
+		case CheckWarn:
+			status = CheckWarn
+		}
+	}
+	return status
+}
@@ -0,0 +1,175 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"index-duel-backend/database"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"os"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestEvaluateIngest(t *testing.T) {
+	now := time.Date(2024, 6, 10, 12, 0, 0, 0, time.UTC)
+	finished := func(age time.Duration) *time.Time {
+		at := now.Add(-age)
+		return &at
+	}
+	message := "upstream returned 502"
+
+	succeeded := &models.IngestRun{RunID: "a", Status: models.IngestSucceeded, FinishedAt: finished(time.Hour)}
+	stale := &models.IngestRun{RunID: "b", Status: models.IngestSucceeded, FinishedAt: finished(10 * 24 * time.Hour)}
+	failed := &models.IngestRun{RunID: "c", Status: models.IngestFailed, FinishedAt: finished(time.Minute), Error: &message}
+
+	tests := []struct {
+		name        string
+		last        *models.IngestRun
+		lastSuccess *models.IngestRun
+		maxAge      time.Duration
+		want        string
+	}{
+		{"recent success", succeeded, succeeded, 0, CheckOK},
+		{"never ingested", nil, nil, 0, CheckWarn},
+		{"only failures", failed, nil, 0, CheckWarn},
+		{"last run failed", failed, succeeded, 0, CheckWarn},
+		{"stale success", stale, stale, 0, CheckWarn},
+		{"past max age", stale, stale, 9 * 24 * time.Hour, CheckFail},
+		{"within max age", succeeded, succeeded, 9 * 24 * time.Hour, CheckOK},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateIngest(tt.last, tt.lastSuccess, now, 8*24*time.Hour, tt.maxAge)
+			if got.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", got.Status, got.Message, tt.want)
+			}
+		})
+	}
+}
+
+func TestWorstStatus(t *testing.T) {
+	tests := []struct {
+		statuses []string
+		want     string
+	}{
+		{nil, CheckOK},
+		{[]string{CheckOK, CheckOK}, CheckOK},
+		{[]string{CheckOK, CheckWarn}, CheckWarn},
+		{[]string{CheckWarn, CheckFail, CheckOK}, CheckFail},
+	}
+	for _, tt := range tests {
+		checks := make(map[string]CheckResult)
+		for i, status := range tt.statuses {
+			checks[string(rune('a'+i))] = CheckResult{Status: status}
+		}
+		if got := worstStatus(checks); got != tt.want {
+			t.Errorf("worstStatus(%v) = %s, want %s", tt.statuses, got, tt.want)
+		}
+	}
+}
+
+func TestEvaluateReplicas(t *testing.T) {
+	caughtUp := database.ReplicaStatus{Name: "replica-a", Usable: true, Lag: 200 * time.Millisecond}
+	lagging := database.ReplicaStatus{Name: "replica-b", Lag: time.Minute, Error: "replication lag 1m0s exceeds 10s"}
+
+	tests := []struct {
+		name     string
+		statuses []database.ReplicaStatus
+		want     string
+	}{
+		{"all in rotation", []database.ReplicaStatus{caughtUp}, CheckOK},
+		{"one lagging", []database.ReplicaStatus{caughtUp, lagging}, CheckWarn},
+		{"all lagging", []database.ReplicaStatus{lagging}, CheckWarn},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := evaluateReplicas(tt.statuses)
+			if got.Status != tt.want {
+				t.Errorf("status = %s (%s), want %s", got.Status, got.Message, tt.want)
+			}
+			if len(got.Details) != len(tt.statuses) {
+				t.Errorf("details = %v, want one entry per replica", got.Details)
+			}
+		})
+	}
+}
+
+// fakeHealthDB answers pings with pingErr and reports missing as the missing tables
+type fakeHealthDB struct {
+	pingErr error
+	missing []string
+}
+
+func (f *fakeHealthDB) PingContext(ctx context.Context) error { return f.pingErr }
+
+func (f *fakeHealthDB) MissingTables(ctx context.Context, names []string) ([]string, error) {
+	return f.missing, nil
+}
+
+func (f *fakeHealthDB) Replicas() []*database.Replica { return nil }
+
+func TestReadiness(t *testing.T) {
+	ctx := context.Background()
+	ingested := repository.NewMemoryCardStore()
+	ingested.CreateCard(ctx, &models.Card{ID: 1, Name: "Card"})
+	ingested.StartIngestRun(ctx, "run-1", "test")
+	ingested.FinishIngestRun(ctx, &models.IngestRun{RunID: "run-1", Status: models.IngestSucceeded})
+
+	tests := []struct {
+		name       string
+		db         *fakeHealthDB
+		store      CatalogueStatus
+		wantStatus string
+		wantChecks map[string]string
+	}{
+		{name: "ready", db: &fakeHealthDB{}, store: ingested, wantStatus: CheckOK,
+			wantChecks: map[string]string{"database": CheckOK, "schema": CheckOK, "ingest": CheckOK, "catalogue": CheckOK}},
+		{name: "database unreachable", db: &fakeHealthDB{pingErr: errors.New("connection refused")}, store: ingested,
+			wantStatus: CheckFail, wantChecks: map[string]string{"database": CheckFail, "schema": CheckFail, "catalogue": CheckFail}},
+		{name: "table missing", db: &fakeHealthDB{missing: []string{"rate_limit_buckets"}}, store: ingested,
+			wantStatus: CheckFail, wantChecks: map[string]string{"schema": CheckFail, "catalogue": CheckOK}},
+		{name: "never ingested", db: &fakeHealthDB{}, store: repository.NewMemoryCardStore(),
+			wantStatus: CheckFail, wantChecks: map[string]string{"ingest": CheckWarn, "catalogue": CheckFail}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := NewHealthService(tt.db, tt.store, HealthConfig{CheckTimeout: time.Second, IngestWarnAge: time.Hour})
+			report := s.Readiness(ctx)
+			if report.Status != tt.wantStatus {
+				t.Errorf("status = %s, want %s", report.Status, tt.wantStatus)
+			}
+			for name, want := range tt.wantChecks {
+				if got := report.Checks[name]; got.Status != want {
+					t.Errorf("%s check = %s (%s), want %s", name, got.Status, got.Message, want)
+				}
+			}
+			if _, ok := report.Checks["replicas"]; ok {
+				t.Error("replicas check reported without replicas")
+			}
+		})
+	}
+}
+
+func TestRequiredTablesCoverMigrations(t *testing.T) {
+	files, err := filepath.Glob("../database/migrations/*.sql")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("no migrations found: %v", err)
+	}
+	createTable := regexp.MustCompile(`(?i)CREATE TABLE (?:IF NOT EXISTS )?(\w+)`)
+	for _, file := range files {
+		script, err := os.ReadFile(file)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, match := range createTable.FindAllStringSubmatch(string(script), -1) {
+			if !slices.Contains(requiredTables, match[1]) {
+				t.Errorf("%s creates table %s, which requiredTables lacks", filepath.Base(file), match[1])
+			}
+		}
+	}
+}
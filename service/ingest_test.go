@@ -0,0 +1,124 @@
+package service
+
+import (
+	"context"
+	"index-duel-backend/models"
+	"index-duel-backend/repository"
+	"index-duel-backend/upstream"
+	"index-duel-backend/upstream/upstreamtest"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestIngestFromFakeUpstream runs whole ingests over HTTP against a fake upstream
+func TestIngestFromFakeUpstream(t *testing.T) {
+	cards := make([]models.Card, 3)
+	for i := range cards {
+		cards[i] = models.Card{ID: int64(i + 1), Name: "Card", CardImages: []models.CardImage{{}}}
+	}
+
+	tests := []struct {
+		name            string
+		failure         *upstreamtest.Failure
+		latency         time.Duration
+		wantErr         bool
+		wantCards       int
+		wantSmallImages int
+	}{
+		{name: "healthy upstream", wantCards: 3, wantSmallImages: 3},
+		{name: "catalogue unavailable",
+			failure: &upstreamtest.Failure{Prefix: upstreamtest.CataloguePath, Status: http.StatusServiceUnavailable},
+			wantErr: true},
+		{name: "connection dropped",
+			failure: &upstreamtest.Failure{Prefix: upstreamtest.CataloguePath},
+			wantErr: true},
+		{name: "upstream slower than the timeout", latency: 300 * time.Millisecond, wantErr: true},
+		{name: "small images unavailable",
+			failure:   &upstreamtest.Failure{Prefix: "/images/small/", Status: http.StatusNotFound},
+			wantCards: 3, wantSmallImages: 0},
+		{name: "one image request fails",
+			failure:   &upstreamtest.Failure{Prefix: "/images/small/", Status: http.StatusInternalServerError, Times: 1},
+			wantCards: 3, wantSmallImages: 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := upstreamtest.NewServer(cards)
+			defer server.Close()
+			if tt.failure != nil {
+				server.Fail(*tt.failure)
+			}
+			server.SetLatency(tt.latency)
+
+			ctx := context.Background()
+			store := repository.NewMemoryCardStore()
+			source := upstream.NewHTTPSource(server.CatalogueURL(), 100*time.Millisecond)
+			svc := NewCardService(store, source, CardServiceConfig{BatchSize: 2})
+
+			err := svc.FetchAndStoreAllCards(ctx)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("FetchAndStoreAllCards error = %v, want error %t", err, tt.wantErr)
+			}
+
+			if count, _ := store.GetCardCount(ctx); count != tt.wantCards {
+				t.Errorf("stored %d cards, want %d", count, tt.wantCards)
+			}
+			var smallImages int
+			store.ForEachSmallImage(ctx, func(int, int64, []byte) error { smallImages++; return nil })
+			if smallImages != tt.wantSmallImages {
+				t.Errorf("stored %d small images, want %d", smallImages, tt.wantSmallImages)
+			}
+
+			run, _ := store.LastIngestRun(ctx)
+			wantStatus := models.IngestSucceeded
+			if tt.wantErr {
+				wantStatus = models.IngestFailed
+			}
+			if run == nil || run.Status != wantStatus || run.Source != server.CatalogueURL() {
+				t.Errorf("last run = %+v, want %s from %s", run, wantStatus, server.CatalogueURL())
+			}
+		})
+	}
+}
+
+// TestIngestRetriesAfterCatalogueChange checks that a catalogue change upstream
+// restarts an interrupted ingest instead of resuming it
+func TestIngestRetriesAfterCatalogueChange(t *testing.T) {
+	ctx := context.Background()
+	server := upstreamtest.NewServer([]models.Card{{ID: 1}, {ID: 2}, {ID: 3}})
+	defer server.Close()
+
+	// Card 2 fails to store, leaving the old version's checkpoint held at card 1
+	store := &flakyStore{MemoryCardStore: repository.NewMemoryCardStore(), failID: 2}
+	source := upstream.NewHTTPSource(server.CatalogueURL(), time.Second)
+	svc := NewCardService(store, source, CardServiceConfig{BatchSize: 1})
+
+	if err := svc.FetchAndStoreAllCards(ctx); err != nil {
+		t.Fatal(err)
+	}
+	first, _ := store.GetIngestCheckpoint(ctx, source.Name())
+	if first == nil || first.Completed || first.LastCardID != 1 {
+		t.Fatalf("checkpoint = %+v, want an interrupted run held at card 1", first)
+	}
+
+	store.failID = 0
+	server.SetCards([]models.Card{{ID: 1}, {ID: 2}, {ID: 3}, {ID: 4}})
+	if err := svc.FetchAndStoreAllCards(ctx); err != nil {
+		t.Fatal(err)
+	}
+	second, _ := store.GetIngestCheckpoint(ctx, source.Name())
+
+	// Resuming would have skipped card 1 and processed only 3 cards
+	if run, _ := store.LastIngestRun(ctx); run == nil || run.CardsProcessed != 4 {
+		t.Errorf("second run = %+v, want it to restart and process all 4 cards", run)
+	}
+	if count, _ := store.GetCardCount(ctx); count != 4 {
+		t.Errorf("stored %d cards, want 4", count)
+	}
+	if second == nil || second.UpstreamVersion == first.UpstreamVersion || !second.Completed {
+		t.Errorf("checkpoints = %+v then %+v, want a completed run of a new version", first, second)
+	}
+	if got := server.Requests(upstreamtest.CataloguePath); got != 2 {
+		t.Errorf("catalogue requested %d times, want 2", got)
+	}
+}
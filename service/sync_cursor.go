@@ -0,0 +1,96 @@
+package service
+
+import (
+	"fmt"
+	"index-duel-backend/repository"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Error codes reported for malformed sync cursors
+const (
+	ErrCodeInvalidLastUpdate = "invalid_last_update"
+	ErrCodeInvalidSinceSeq   = "invalid_since_seq"
+)
+
+// epochMillisDigits is the length from which a numeric last_update is read as
+// milliseconds rather than seconds since the Unix epoch
+const epochMillisDigits = 12
+
+// InvalidRequestError reports client input the server does not accept
+type InvalidRequestError struct {
+	Code    string
+	Message string
+}
+
+func (e *InvalidRequestError) Error() string {
+	return e.Message
+}
+
+// ParseLastUpdate parses a last_update cursor. It accepts RFC 3339 timestamps with
+// an explicit offset, and Unix epoch times in seconds or milliseconds.
+func ParseLastUpdate(value string) (time.Time, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return time.Time{}, &InvalidRequestError{
+			Code:    ErrCodeInvalidLastUpdate,
+			Message: "last_update must not be blank",
+		}
+	}
+
+	if isDigits(value) {
+		n, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			return time.Time{}, &InvalidRequestError{
+				Code:    ErrCodeInvalidLastUpdate,
+				Message: "last_update epoch value is out of range",
+			}
+		}
+		if len(value) >= epochMillisDigits {
+			return time.UnixMilli(n).UTC(), nil
+		}
+		return time.Unix(n, 0).UTC(), nil
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, value)
+	if err != nil {
+		return time.Time{}, &InvalidRequestError{
+			Code:    ErrCodeInvalidLastUpdate,
+			Message: fmt.Sprintf("last_update %q is neither an RFC 3339 timestamp nor a Unix epoch time", value),
+		}
+	}
+	return t.UTC(), nil
+}
+
+// ValidateSinceSeq checks a since_seq cursor
+func ValidateSinceSeq(seq int64) error {
+	if seq < 0 {
+		return &InvalidRequestError{
+			Code:    ErrCodeInvalidSinceSeq,
+			Message: "since_seq must not be negative",
+		}
+	}
+	return nil
+}
+
+func isDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// lastUpdateCursor returns the last_update to hand out with a sync result: the newest
+// card timestamp its snapshot saw, truncated to the second, so a snapshot from a
+// lagging replica cannot move a client past cards it has not seen. It is empty when
+// the snapshot held no cards, making the next sync a first sync.
+func lastUpdateCursor(cards *repository.CardIterator) string {
+	through := cards.UpdatedThrough()
+	if through.IsZero() {
+		return ""
+	}
+	return through.UTC().Format(time.RFC3339)
+}
@@ -0,0 +1,62 @@
+package service
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseLastUpdate(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{"rfc3339 utc", "2024-05-01T12:00:00Z", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"rfc3339 offset", "2024-05-01T14:00:00+02:00", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"rfc3339 fractional", "2024-05-01T12:00:00.250Z", time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC), false},
+		{"epoch seconds", "1714564800", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"epoch milliseconds", "1714564800250", time.Date(2024, 5, 1, 12, 0, 0, 250e6, time.UTC), false},
+		{"surrounding whitespace", " 1714564800 ", time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC), false},
+		{"blank", "   ", time.Time{}, true},
+		{"missing offset", "2024-05-01T12:00:00", time.Time{}, true},
+		{"date only", "2024-05-01", time.Time{}, true},
+		{"sql injection", "2024-05-01' OR 1=1 --", time.Time{}, true},
+		{"negative epoch", "-1714564800", time.Time{}, true},
+		{"epoch overflow", "99999999999999999999", time.Time{}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseLastUpdate(tt.value)
+			if tt.wantErr {
+				var invalid *InvalidRequestError
+				if !errors.As(err, &invalid) || invalid.Code != ErrCodeInvalidLastUpdate {
+					t.Fatalf("ParseLastUpdate(%q) error = %v, want %s", tt.value, err, ErrCodeInvalidLastUpdate)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseLastUpdate(%q) returned error: %v", tt.value, err)
+			}
+			if !got.Equal(tt.want) {
+				t.Errorf("ParseLastUpdate(%q) = %s, want %s", tt.value, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateSinceSeq(t *testing.T) {
+	if err := ValidateSinceSeq(0); err != nil {
+		t.Errorf("ValidateSinceSeq(0) returned error: %v", err)
+	}
+	if err := ValidateSinceSeq(42); err != nil {
+		t.Errorf("ValidateSinceSeq(42) returned error: %v", err)
+	}
+
+	var invalid *InvalidRequestError
+	if err := ValidateSinceSeq(-1); !errors.As(err, &invalid) || invalid.Code != ErrCodeInvalidSinceSeq {
+		t.Errorf("ValidateSinceSeq(-1) error = %v, want %s", err, ErrCodeInvalidSinceSeq)
+	}
+}
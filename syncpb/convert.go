@@ -0,0 +1,150 @@
+// Package syncpb holds the Protocol Buffers wire format of the sync endpoint.
+package syncpb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative sync.proto
+
+import (
+	"index-duel-backend/models"
+	"time"
+
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// FromCard converts a card into its protobuf form
+func FromCard(card *models.Card) *Card {
+	pb := &Card{
+		Id:        card.ID,
+		Name:      card.Name,
+		Type:      card.Type,
+		FrameType: card.FrameType,
+		Desc:      card.Description,
+		Atk:       toInt32Ptr(card.ATK),
+		Def:       toInt32Ptr(card.DEF),
+		Level:     toInt32Ptr(card.Level),
+		Race:      card.Race,
+		Attribute: card.Attribute,
+		CreatedAt: timestamppb.New(card.CreatedAt),
+		UpdatedAt: timestamppb.New(card.UpdatedAt),
+	}
+
+	for i := range card.CardSets {
+		set := &card.CardSets[i]
+		pb.CardSets = append(pb.CardSets, &CardSet{
+			Id:            int32(set.ID),
+			SetName:       set.SetName,
+			SetCode:       set.SetCode,
+			SetRarity:     set.SetRarity,
+			SetRarityCode: set.SetRarityCode,
+			SetPrice:      set.SetPrice,
+			CreatedAt:     timestamppb.New(set.CreatedAt),
+		})
+	}
+
+	for i := range card.CardImages {
+		image := &card.CardImages[i]
+		pb.CardImages = append(pb.CardImages, &CardImage{
+			Id:              int32(image.ID),
+			ImageUrl:        image.ImageURL,
+			ImageUrlSmall:   image.ImageURLSmall,
+			ImageUrlCropped: image.ImageURLCropped,
+			CreatedAt:       timestamppb.New(image.CreatedAt),
+		})
+	}
+
+	for i := range card.CardPrices {
+		price := &card.CardPrices[i]
+		pb.CardPrices = append(pb.CardPrices, &CardPrice{
+			Id:                int32(price.ID),
+			CardmarketPrice:   price.CardMarketPrice,
+			TcgplayerPrice:    price.TCGPlayerPrice,
+			EbayPrice:         price.EbayPrice,
+			AmazonPrice:       price.AmazonPrice,
+			CoolstuffincPrice: price.CoolStuffIncPrice,
+			CreatedAt:         timestamppb.New(price.CreatedAt),
+			UpdatedAt:         timestamppb.New(price.UpdatedAt),
+		})
+	}
+
+	return pb
+}
+
+// ToCard converts a protobuf card back into the model type
+func ToCard(pb *Card) models.Card {
+	card := models.Card{
+		ID:          pb.GetId(),
+		Name:        pb.GetName(),
+		Type:        pb.GetType(),
+		FrameType:   pb.GetFrameType(),
+		Description: pb.GetDesc(),
+		ATK:         toIntPtr(pb.Atk),
+		DEF:         toIntPtr(pb.Def),
+		Level:       toIntPtr(pb.Level),
+		Race:        pb.GetRace(),
+		Attribute:   pb.GetAttribute(),
+		CreatedAt:   toTime(pb.GetCreatedAt()),
+		UpdatedAt:   toTime(pb.GetUpdatedAt()),
+	}
+
+	for _, set := range pb.GetCardSets() {
+		card.CardSets = append(card.CardSets, models.CardSet{
+			ID:            int(set.GetId()),
+			CardID:        card.ID,
+			SetName:       set.GetSetName(),
+			SetCode:       set.GetSetCode(),
+			SetRarity:     set.GetSetRarity(),
+			SetRarityCode: set.GetSetRarityCode(),
+			SetPrice:      set.SetPrice,
+			CreatedAt:     toTime(set.GetCreatedAt()),
+		})
+	}
+
+	for _, image := range pb.GetCardImages() {
+		card.CardImages = append(card.CardImages, models.CardImage{
+			ID:              int(image.GetId()),
+			CardID:          card.ID,
+			ImageURL:        image.GetImageUrl(),
+			ImageURLSmall:   image.GetImageUrlSmall(),
+			ImageURLCropped: image.GetImageUrlCropped(),
+			CreatedAt:       toTime(image.GetCreatedAt()),
+		})
+	}
+
+	for _, price := range pb.GetCardPrices() {
+		card.CardPrices = append(card.CardPrices, models.CardPrice{
+			ID:                int(price.GetId()),
+			CardID:            card.ID,
+			CardMarketPrice:   price.CardmarketPrice,
+			TCGPlayerPrice:    price.TcgplayerPrice,
+			EbayPrice:         price.EbayPrice,
+			AmazonPrice:       price.AmazonPrice,
+			CoolStuffIncPrice: price.CoolstuffincPrice,
+			CreatedAt:         toTime(price.GetCreatedAt()),
+			UpdatedAt:         toTime(price.GetUpdatedAt()),
+		})
+	}
+
+	return card
+}
+
+func toInt32Ptr(v *int) *int32 {
+	if v == nil {
+		return nil
+	}
+	n := int32(*v)
+	return &n
+}
+
+func toIntPtr(v *int32) *int {
+	if v == nil {
+		return nil
+	}
+	n := int(*v)
+	return &n
+}
+
+func toTime(ts *timestamppb.Timestamp) time.Time {
+	if ts == nil {
+		return time.Time{}
+	}
+	return ts.AsTime()
+}
@@ -0,0 +1,674 @@
+// Code generated by protoc-gen-go. DO NOT EDIT.
+// versions:
+// 	protoc-gen-go v1.36.9
+// 	protoc        (unknown)
+// source: sync.proto
+
+package syncpb
+
+import (
+	protoreflect "google.golang.org/protobuf/reflect/protoreflect"
+	protoimpl "google.golang.org/protobuf/runtime/protoimpl"
+	timestamppb "google.golang.org/protobuf/types/known/timestamppb"
+	reflect "reflect"
+	sync "sync"
+	unsafe "unsafe"
+)
+
+const (
+	// Verify that this generated code is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(20 - protoimpl.MinVersion)
+	// Verify that runtime/protoimpl is sufficiently up-to-date.
+	_ = protoimpl.EnforceVersion(protoimpl.MaxVersion - 20)
+)
+
+// Card mirrors models.Card as returned by the sync endpoint.
+type Card struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int64                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	Name          string                 `protobuf:"bytes,2,opt,name=name,proto3" json:"name,omitempty"`
+	Type          string                 `protobuf:"bytes,3,opt,name=type,proto3" json:"type,omitempty"`
+	FrameType     string                 `protobuf:"bytes,4,opt,name=frame_type,json=frameType,proto3" json:"frame_type,omitempty"`
+	Desc          string                 `protobuf:"bytes,5,opt,name=desc,proto3" json:"desc,omitempty"`
+	Atk           *int32                 `protobuf:"varint,6,opt,name=atk,proto3,oneof" json:"atk,omitempty"`
+	Def           *int32                 `protobuf:"varint,7,opt,name=def,proto3,oneof" json:"def,omitempty"`
+	Level         *int32                 `protobuf:"varint,8,opt,name=level,proto3,oneof" json:"level,omitempty"`
+	Race          string                 `protobuf:"bytes,9,opt,name=race,proto3" json:"race,omitempty"`
+	Attribute     string                 `protobuf:"bytes,10,opt,name=attribute,proto3" json:"attribute,omitempty"`
+	CardSets      []*CardSet             `protobuf:"bytes,11,rep,name=card_sets,json=cardSets,proto3" json:"card_sets,omitempty"`
+	CardImages    []*CardImage           `protobuf:"bytes,12,rep,name=card_images,json=cardImages,proto3" json:"card_images,omitempty"`
+	CardPrices    []*CardPrice           `protobuf:"bytes,13,rep,name=card_prices,json=cardPrices,proto3" json:"card_prices,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,14,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt     *timestamppb.Timestamp `protobuf:"bytes,15,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Card) Reset() {
+	*x = Card{}
+	mi := &file_sync_proto_msgTypes[0]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Card) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Card) ProtoMessage() {}
+
+func (x *Card) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_proto_msgTypes[0]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Card.ProtoReflect.Descriptor instead.
+func (*Card) Descriptor() ([]byte, []int) {
+	return file_sync_proto_rawDescGZIP(), []int{0}
+}
+
+func (x *Card) GetId() int64 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *Card) GetName() string {
+	if x != nil {
+		return x.Name
+	}
+	return ""
+}
+
+func (x *Card) GetType() string {
+	if x != nil {
+		return x.Type
+	}
+	return ""
+}
+
+func (x *Card) GetFrameType() string {
+	if x != nil {
+		return x.FrameType
+	}
+	return ""
+}
+
+func (x *Card) GetDesc() string {
+	if x != nil {
+		return x.Desc
+	}
+	return ""
+}
+
+func (x *Card) GetAtk() int32 {
+	if x != nil && x.Atk != nil {
+		return *x.Atk
+	}
+	return 0
+}
+
+func (x *Card) GetDef() int32 {
+	if x != nil && x.Def != nil {
+		return *x.Def
+	}
+	return 0
+}
+
+func (x *Card) GetLevel() int32 {
+	if x != nil && x.Level != nil {
+		return *x.Level
+	}
+	return 0
+}
+
+func (x *Card) GetRace() string {
+	if x != nil {
+		return x.Race
+	}
+	return ""
+}
+
+func (x *Card) GetAttribute() string {
+	if x != nil {
+		return x.Attribute
+	}
+	return ""
+}
+
+func (x *Card) GetCardSets() []*CardSet {
+	if x != nil {
+		return x.CardSets
+	}
+	return nil
+}
+
+func (x *Card) GetCardImages() []*CardImage {
+	if x != nil {
+		return x.CardImages
+	}
+	return nil
+}
+
+func (x *Card) GetCardPrices() []*CardPrice {
+	if x != nil {
+		return x.CardPrices
+	}
+	return nil
+}
+
+func (x *Card) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *Card) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// CardSet mirrors models.CardSet.
+type CardSet struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Id            int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	SetName       string                 `protobuf:"bytes,2,opt,name=set_name,json=setName,proto3" json:"set_name,omitempty"`
+	SetCode       string                 `protobuf:"bytes,3,opt,name=set_code,json=setCode,proto3" json:"set_code,omitempty"`
+	SetRarity     string                 `protobuf:"bytes,4,opt,name=set_rarity,json=setRarity,proto3" json:"set_rarity,omitempty"`
+	SetRarityCode string                 `protobuf:"bytes,5,opt,name=set_rarity_code,json=setRarityCode,proto3" json:"set_rarity_code,omitempty"`
+	SetPrice      *string                `protobuf:"bytes,6,opt,name=set_price,json=setPrice,proto3,oneof" json:"set_price,omitempty"`
+	CreatedAt     *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CardSet) Reset() {
+	*x = CardSet{}
+	mi := &file_sync_proto_msgTypes[1]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardSet) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardSet) ProtoMessage() {}
+
+func (x *CardSet) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_proto_msgTypes[1]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardSet.ProtoReflect.Descriptor instead.
+func (*CardSet) Descriptor() ([]byte, []int) {
+	return file_sync_proto_rawDescGZIP(), []int{1}
+}
+
+func (x *CardSet) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CardSet) GetSetName() string {
+	if x != nil {
+		return x.SetName
+	}
+	return ""
+}
+
+func (x *CardSet) GetSetCode() string {
+	if x != nil {
+		return x.SetCode
+	}
+	return ""
+}
+
+func (x *CardSet) GetSetRarity() string {
+	if x != nil {
+		return x.SetRarity
+	}
+	return ""
+}
+
+func (x *CardSet) GetSetRarityCode() string {
+	if x != nil {
+		return x.SetRarityCode
+	}
+	return ""
+}
+
+func (x *CardSet) GetSetPrice() string {
+	if x != nil && x.SetPrice != nil {
+		return *x.SetPrice
+	}
+	return ""
+}
+
+func (x *CardSet) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// CardImage mirrors models.CardImage. Image bytes are never sent over sync.
+type CardImage struct {
+	state           protoimpl.MessageState `protogen:"open.v1"`
+	Id              int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	ImageUrl        string                 `protobuf:"bytes,2,opt,name=image_url,json=imageUrl,proto3" json:"image_url,omitempty"`
+	ImageUrlSmall   string                 `protobuf:"bytes,3,opt,name=image_url_small,json=imageUrlSmall,proto3" json:"image_url_small,omitempty"`
+	ImageUrlCropped string                 `protobuf:"bytes,4,opt,name=image_url_cropped,json=imageUrlCropped,proto3" json:"image_url_cropped,omitempty"`
+	CreatedAt       *timestamppb.Timestamp `protobuf:"bytes,5,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	unknownFields   protoimpl.UnknownFields
+	sizeCache       protoimpl.SizeCache
+}
+
+func (x *CardImage) Reset() {
+	*x = CardImage{}
+	mi := &file_sync_proto_msgTypes[2]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardImage) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardImage) ProtoMessage() {}
+
+func (x *CardImage) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_proto_msgTypes[2]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardImage.ProtoReflect.Descriptor instead.
+func (*CardImage) Descriptor() ([]byte, []int) {
+	return file_sync_proto_rawDescGZIP(), []int{2}
+}
+
+func (x *CardImage) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CardImage) GetImageUrl() string {
+	if x != nil {
+		return x.ImageUrl
+	}
+	return ""
+}
+
+func (x *CardImage) GetImageUrlSmall() string {
+	if x != nil {
+		return x.ImageUrlSmall
+	}
+	return ""
+}
+
+func (x *CardImage) GetImageUrlCropped() string {
+	if x != nil {
+		return x.ImageUrlCropped
+	}
+	return ""
+}
+
+func (x *CardImage) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+// CardPrice mirrors models.CardPrice.
+type CardPrice struct {
+	state             protoimpl.MessageState `protogen:"open.v1"`
+	Id                int32                  `protobuf:"varint,1,opt,name=id,proto3" json:"id,omitempty"`
+	CardmarketPrice   *string                `protobuf:"bytes,2,opt,name=cardmarket_price,json=cardmarketPrice,proto3,oneof" json:"cardmarket_price,omitempty"`
+	TcgplayerPrice    *string                `protobuf:"bytes,3,opt,name=tcgplayer_price,json=tcgplayerPrice,proto3,oneof" json:"tcgplayer_price,omitempty"`
+	EbayPrice         *string                `protobuf:"bytes,4,opt,name=ebay_price,json=ebayPrice,proto3,oneof" json:"ebay_price,omitempty"`
+	AmazonPrice       *string                `protobuf:"bytes,5,opt,name=amazon_price,json=amazonPrice,proto3,oneof" json:"amazon_price,omitempty"`
+	CoolstuffincPrice *string                `protobuf:"bytes,6,opt,name=coolstuffinc_price,json=coolstuffincPrice,proto3,oneof" json:"coolstuffinc_price,omitempty"`
+	CreatedAt         *timestamppb.Timestamp `protobuf:"bytes,7,opt,name=created_at,json=createdAt,proto3" json:"created_at,omitempty"`
+	UpdatedAt         *timestamppb.Timestamp `protobuf:"bytes,8,opt,name=updated_at,json=updatedAt,proto3" json:"updated_at,omitempty"`
+	unknownFields     protoimpl.UnknownFields
+	sizeCache         protoimpl.SizeCache
+}
+
+func (x *CardPrice) Reset() {
+	*x = CardPrice{}
+	mi := &file_sync_proto_msgTypes[3]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CardPrice) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CardPrice) ProtoMessage() {}
+
+func (x *CardPrice) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_proto_msgTypes[3]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CardPrice.ProtoReflect.Descriptor instead.
+func (*CardPrice) Descriptor() ([]byte, []int) {
+	return file_sync_proto_rawDescGZIP(), []int{3}
+}
+
+func (x *CardPrice) GetId() int32 {
+	if x != nil {
+		return x.Id
+	}
+	return 0
+}
+
+func (x *CardPrice) GetCardmarketPrice() string {
+	if x != nil && x.CardmarketPrice != nil {
+		return *x.CardmarketPrice
+	}
+	return ""
+}
+
+func (x *CardPrice) GetTcgplayerPrice() string {
+	if x != nil && x.TcgplayerPrice != nil {
+		return *x.TcgplayerPrice
+	}
+	return ""
+}
+
+func (x *CardPrice) GetEbayPrice() string {
+	if x != nil && x.EbayPrice != nil {
+		return *x.EbayPrice
+	}
+	return ""
+}
+
+func (x *CardPrice) GetAmazonPrice() string {
+	if x != nil && x.AmazonPrice != nil {
+		return *x.AmazonPrice
+	}
+	return ""
+}
+
+func (x *CardPrice) GetCoolstuffincPrice() string {
+	if x != nil && x.CoolstuffincPrice != nil {
+		return *x.CoolstuffincPrice
+	}
+	return ""
+}
+
+func (x *CardPrice) GetCreatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.CreatedAt
+	}
+	return nil
+}
+
+func (x *CardPrice) GetUpdatedAt() *timestamppb.Timestamp {
+	if x != nil {
+		return x.UpdatedAt
+	}
+	return nil
+}
+
+// SyncResponse mirrors models.SyncResponse. The server streams the cards
+// field entry by entry, followed by the remaining fields.
+type SyncResponse struct {
+	state      protoimpl.MessageState `protogen:"open.v1"`
+	Cards      []*Card                `protobuf:"bytes,1,rep,name=cards,proto3" json:"cards,omitempty"`
+	LastUpdate string                 `protobuf:"bytes,2,opt,name=last_update,json=lastUpdate,proto3" json:"last_update,omitempty"`
+	TotalCards int32                  `protobuf:"varint,3,opt,name=total_cards,json=totalCards,proto3" json:"total_cards,omitempty"`
+	// seq is the change sequence to send as since_seq on the next sync.
+	Seq int64 `protobuf:"varint,4,opt,name=seq,proto3" json:"seq,omitempty"`
+	// full_resync_required is set when the client's cursor was too old to patch
+	// up, and cards is the whole catalogue to replace its local copy with.
+	FullResyncRequired bool `protobuf:"varint,5,opt,name=full_resync_required,json=fullResyncRequired,proto3" json:"full_resync_required,omitempty"`
+	unknownFields      protoimpl.UnknownFields
+	sizeCache          protoimpl.SizeCache
+}
+
+func (x *SyncResponse) Reset() {
+	*x = SyncResponse{}
+	mi := &file_sync_proto_msgTypes[4]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *SyncResponse) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*SyncResponse) ProtoMessage() {}
+
+func (x *SyncResponse) ProtoReflect() protoreflect.Message {
+	mi := &file_sync_proto_msgTypes[4]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use SyncResponse.ProtoReflect.Descriptor instead.
+func (*SyncResponse) Descriptor() ([]byte, []int) {
+	return file_sync_proto_rawDescGZIP(), []int{4}
+}
+
+func (x *SyncResponse) GetCards() []*Card {
+	if x != nil {
+		return x.Cards
+	}
+	return nil
+}
+
+func (x *SyncResponse) GetLastUpdate() string {
+	if x != nil {
+		return x.LastUpdate
+	}
+	return ""
+}
+
+func (x *SyncResponse) GetTotalCards() int32 {
+	if x != nil {
+		return x.TotalCards
+	}
+	return 0
+}
+
+func (x *SyncResponse) GetSeq() int64 {
+	if x != nil {
+		return x.Seq
+	}
+	return 0
+}
+
+func (x *SyncResponse) GetFullResyncRequired() bool {
+	if x != nil {
+		return x.FullResyncRequired
+	}
+	return false
+}
+
+var File_sync_proto protoreflect.FileDescriptor
+
+const file_sync_proto_rawDesc = "" +
+	"\n" +
+	"\n" +
+	"sync.proto\x12\x11indexduel.sync.v1\x1a\x1fgoogle/protobuf/timestamp.proto\"\xb3\x04\n" +
+	"\x04Card\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x03R\x02id\x12\x12\n" +
+	"\x04name\x18\x02 \x01(\tR\x04name\x12\x12\n" +
+	"\x04type\x18\x03 \x01(\tR\x04type\x12\x1d\n" +
+	"\n" +
+	"frame_type\x18\x04 \x01(\tR\tframeType\x12\x12\n" +
+	"\x04desc\x18\x05 \x01(\tR\x04desc\x12\x15\n" +
+	"\x03atk\x18\x06 \x01(\x05H\x00R\x03atk\x88\x01\x01\x12\x15\n" +
+	"\x03def\x18\a \x01(\x05H\x01R\x03def\x88\x01\x01\x12\x19\n" +
+	"\x05level\x18\b \x01(\x05H\x02R\x05level\x88\x01\x01\x12\x12\n" +
+	"\x04race\x18\t \x01(\tR\x04race\x12\x1c\n" +
+	"\tattribute\x18\n" +
+	" \x01(\tR\tattribute\x127\n" +
+	"\tcard_sets\x18\v \x03(\v2\x1a.indexduel.sync.v1.CardSetR\bcardSets\x12=\n" +
+	"\vcard_images\x18\f \x03(\v2\x1c.indexduel.sync.v1.CardImageR\n" +
+	"cardImages\x12=\n" +
+	"\vcard_prices\x18\r \x03(\v2\x1c.indexduel.sync.v1.CardPriceR\n" +
+	"cardPrices\x129\n" +
+	"\n" +
+	"created_at\x18\x0e \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\x0f \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\x06\n" +
+	"\x04_atkB\x06\n" +
+	"\x04_defB\b\n" +
+	"\x06_level\"\x81\x02\n" +
+	"\aCardSet\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x19\n" +
+	"\bset_name\x18\x02 \x01(\tR\asetName\x12\x19\n" +
+	"\bset_code\x18\x03 \x01(\tR\asetCode\x12\x1d\n" +
+	"\n" +
+	"set_rarity\x18\x04 \x01(\tR\tsetRarity\x12&\n" +
+	"\x0fset_rarity_code\x18\x05 \x01(\tR\rsetRarityCode\x12 \n" +
+	"\tset_price\x18\x06 \x01(\tH\x00R\bsetPrice\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAtB\f\n" +
+	"\n" +
+	"_set_price\"\xc7\x01\n" +
+	"\tCardImage\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12\x1b\n" +
+	"\timage_url\x18\x02 \x01(\tR\bimageUrl\x12&\n" +
+	"\x0fimage_url_small\x18\x03 \x01(\tR\rimageUrlSmall\x12*\n" +
+	"\x11image_url_cropped\x18\x04 \x01(\tR\x0fimageUrlCropped\x129\n" +
+	"\n" +
+	"created_at\x18\x05 \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\"\xcf\x03\n" +
+	"\tCardPrice\x12\x0e\n" +
+	"\x02id\x18\x01 \x01(\x05R\x02id\x12.\n" +
+	"\x10cardmarket_price\x18\x02 \x01(\tH\x00R\x0fcardmarketPrice\x88\x01\x01\x12,\n" +
+	"\x0ftcgplayer_price\x18\x03 \x01(\tH\x01R\x0etcgplayerPrice\x88\x01\x01\x12\"\n" +
+	"\n" +
+	"ebay_price\x18\x04 \x01(\tH\x02R\tebayPrice\x88\x01\x01\x12&\n" +
+	"\famazon_price\x18\x05 \x01(\tH\x03R\vamazonPrice\x88\x01\x01\x122\n" +
+	"\x12coolstuffinc_price\x18\x06 \x01(\tH\x04R\x11coolstuffincPrice\x88\x01\x01\x129\n" +
+	"\n" +
+	"created_at\x18\a \x01(\v2\x1a.google.protobuf.TimestampR\tcreatedAt\x129\n" +
+	"\n" +
+	"updated_at\x18\b \x01(\v2\x1a.google.protobuf.TimestampR\tupdatedAtB\x13\n" +
+	"\x11_cardmarket_priceB\x12\n" +
+	"\x10_tcgplayer_priceB\r\n" +
+	"\v_ebay_priceB\x0f\n" +
+	"\r_amazon_priceB\x15\n" +
+	"\x13_coolstuffinc_price\"\xc3\x01\n" +
+	"\fSyncResponse\x12-\n" +
+	"\x05cards\x18\x01 \x03(\v2\x17.indexduel.sync.v1.CardR\x05cards\x12\x1f\n" +
+	"\vlast_update\x18\x02 \x01(\tR\n" +
+	"lastUpdate\x12\x1f\n" +
+	"\vtotal_cards\x18\x03 \x01(\x05R\n" +
+	"totalCards\x12\x10\n" +
+	"\x03seq\x18\x04 \x01(\x03R\x03seq\x120\n" +
+	"\x14full_resync_required\x18\x05 \x01(\bR\x12fullResyncRequiredB\x1bZ\x19index-duel-backend/syncpbb\x06proto3"
+
+var (
+	file_sync_proto_rawDescOnce sync.Once
+	file_sync_proto_rawDescData []byte
+)
+
+func file_sync_proto_rawDescGZIP() []byte {
+	file_sync_proto_rawDescOnce.Do(func() {
+		file_sync_proto_rawDescData = protoimpl.X.CompressGZIP(unsafe.Slice(unsafe.StringData(file_sync_proto_rawDesc), len(file_sync_proto_rawDesc)))
+	})
+	return file_sync_proto_rawDescData
+}
+
+var file_sync_proto_msgTypes = make([]protoimpl.MessageInfo, 5)
+var file_sync_proto_goTypes = []any{
+	(*Card)(nil),                  // 0: indexduel.sync.v1.Card
+	(*CardSet)(nil),               // 1: indexduel.sync.v1.CardSet
+	(*CardImage)(nil),             // 2: indexduel.sync.v1.CardImage
+	(*CardPrice)(nil),             // 3: indexduel.sync.v1.CardPrice
+	(*SyncResponse)(nil),          // 4: indexduel.sync.v1.SyncResponse
+	(*timestamppb.Timestamp)(nil), // 5: google.protobuf.Timestamp
+}
+var file_sync_proto_depIdxs = []int32{
+	1,  // 0: indexduel.sync.v1.Card.card_sets:type_name -> indexduel.sync.v1.CardSet
+	2,  // 1: indexduel.sync.v1.Card.card_images:type_name -> indexduel.sync.v1.CardImage
+	3,  // 2: indexduel.sync.v1.Card.card_prices:type_name -> indexduel.sync.v1.CardPrice
+	5,  // 3: indexduel.sync.v1.Card.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 4: indexduel.sync.v1.Card.updated_at:type_name -> google.protobuf.Timestamp
+	5,  // 5: indexduel.sync.v1.CardSet.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 6: indexduel.sync.v1.CardImage.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 7: indexduel.sync.v1.CardPrice.created_at:type_name -> google.protobuf.Timestamp
+	5,  // 8: indexduel.sync.v1.CardPrice.updated_at:type_name -> google.protobuf.Timestamp
+	0,  // 9: indexduel.sync.v1.SyncResponse.cards:type_name -> indexduel.sync.v1.Card
+	10, // [10:10] is the sub-list for method output_type
+	10, // [10:10] is the sub-list for method input_type
+	10, // [10:10] is the sub-list for extension type_name
+	10, // [10:10] is the sub-list for extension extendee
+	0,  // [0:10] is the sub-list for field type_name
+}
+
+func init() { file_sync_proto_init() }
+func file_sync_proto_init() {
+	if File_sync_proto != nil {
+		return
+	}
+	file_sync_proto_msgTypes[0].OneofWrappers = []any{}
+	file_sync_proto_msgTypes[1].OneofWrappers = []any{}
+	file_sync_proto_msgTypes[3].OneofWrappers = []any{}
+	type x struct{}
+	out := protoimpl.TypeBuilder{
+		File: protoimpl.DescBuilder{
+			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
+			RawDescriptor: unsafe.Slice(unsafe.StringData(file_sync_proto_rawDesc), len(file_sync_proto_rawDesc)),
+			NumEnums:      0,
+			NumMessages:   5,
+			NumExtensions: 0,
+			NumServices:   0,
+		},
+		GoTypes:           file_sync_proto_goTypes,
+		DependencyIndexes: file_sync_proto_depIdxs,
+		MessageInfos:      file_sync_proto_msgTypes,
+	}.Build()
+	File_sync_proto = out.File
+	file_sync_proto_goTypes = nil
+	file_sync_proto_depIdxs = nil
+}
@@ -0,0 +1,152 @@
+// Package tracing configures OpenTelemetry tracing and provides helpers for
+// starting spans and instrumenting HTTP servers and clients.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/logging"
+	"log/slog"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// instrumentationName identifies the spans created by this service
+const instrumentationName = "index-duel-backend"
+
+// untracedPaths are polled by infrastructure often enough that tracing them would
+// drown out real traffic
+var untracedPaths = map[string]bool{"/metrics": true, "/livez": true, "/readyz": true}
+
+// tracer resolves to the provider installed by Setup, even when used before it
+var tracer = otel.Tracer(instrumentationName)
+
+// Config selects where spans are exported
+type Config struct {
+	// Exporter is "otlp" to send spans over OTLP/HTTP, configured through the standard
+	// OTEL_EXPORTER_OTLP_* variables, "stdout" to print them, or "none" (the default)
+	Exporter string
+	// ServiceName is reported as service.name unless OTEL_SERVICE_NAME overrides it
+	ServiceName string
+	// SampleRatio is the fraction of new traces to record; traces started upstream
+	// follow the caller's sampling decision
+	SampleRatio float64
+}
+
+// Setup installs W3C trace context propagation and, unless tracing is disabled, a
+// tracer provider exporting spans as configured. The returned function flushes
+// pending spans and must be called before exiting.
+func Setup(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch strings.ToLower(cfg.Exporter) {
+	case "", "none":
+		return func(context.Context) error { return nil }, nil
+	case "otlp":
+		exporter, err = otlptracehttp.New(ctx)
+	case "stdout":
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout), stdouttrace.WithPrettyPrint())
+	default:
+		return nil, fmt.Errorf("invalid trace exporter %q, expected otlp, stdout or none", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	if cfg.SampleRatio < 0 || cfg.SampleRatio > 1 {
+		return nil, fmt.Errorf("invalid trace sample ratio %v, expected a value between 0 and 1", cfg.SampleRatio)
+	}
+
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(cfg.ServiceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create trace resource: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// Start starts a span named name as a child of the span in ctx, if any
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End marks span as failed when *errp holds an error, then ends it. It is meant to
+// be deferred with a pointer to a named error result.
+func End(span trace.Span, errp *error) {
+	if errp != nil && *errp != nil {
+		span.RecordError(*errp)
+		span.SetStatus(codes.Error, (*errp).Error())
+	}
+	span.End()
+}
+
+// Middleware starts a server span for every request, continuing any trace context
+// sent by the client, and attaches the trace ID to the request's log records.
+// Metric scrapes and probes are not traced.
+func Middleware(next http.Handler) http.Handler {
+	withTraceID := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if sc := trace.SpanContextFromContext(r.Context()); sc.IsValid() {
+			r = r.WithContext(logging.WithAttrs(r.Context(), slog.String("trace_id", sc.TraceID().String())))
+		}
+		next.ServeHTTP(w, r)
+	})
+	return otelhttp.NewHandler(withTraceID, "http.server",
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return r.Method }),
+		otelhttp.WithFilter(func(r *http.Request) bool { return !untracedPaths[r.URL.Path] }),
+	)
+}
+
+// RouteMiddleware names the server span after the matched mux route template, so
+// spans group by endpoint rather than by raw path. It must run inside Middleware.
+func RouteMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if current := mux.CurrentRoute(r); current != nil {
+			if template, err := current.GetPathTemplate(); err == nil {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + template)
+				span.SetAttributes(semconv.HTTPRoute(template))
+			}
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Transport wraps base so outbound requests get a client span and carry the trace
+// context of the request's context
+func Transport(base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return otelhttp.NewTransport(base,
+		otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string { return "HTTP " + r.Method + " " + r.URL.Host }),
+	)
+}
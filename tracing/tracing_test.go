@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var (
+	recorderOnce sync.Once
+	recorder     *tracetest.SpanRecorder
+)
+
+// spanRecorder installs a global provider that records every span. The package
+// tracer only binds to the first provider installed, so it is shared by all tests.
+func spanRecorder() *tracetest.SpanRecorder {
+	recorderOnce.Do(func() {
+		recorder = tracetest.NewSpanRecorder()
+		otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+	})
+	return recorder
+}
+
+// endedSpan returns the ended span with the given name
+func endedSpan(t *testing.T, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spanRecorder().Ended() {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("no span named %q", name)
+	return nil
+}
+
+func TestEndRecordsError(t *testing.T) {
+	spanRecorder()
+
+	fail := func() (err error) {
+		_, span := Start(context.Background(), "test.fail")
+		defer End(span, &err)
+		return errors.New("boom")
+	}
+	succeed := func() (err error) {
+		_, span := Start(context.Background(), "test.succeed")
+		defer End(span, &err)
+		return nil
+	}
+	fail()
+	succeed()
+
+	if span := endedSpan(t, "test.fail"); span.Status().Code != codes.Error || len(span.Events()) == 0 {
+		t.Errorf("failed span has status %v and %d events, want an error with a recorded exception",
+			span.Status(), len(span.Events()))
+	}
+	if span := endedSpan(t, "test.succeed"); span.Status().Code == codes.Error {
+		t.Errorf("successful span has status %v", span.Status())
+	}
+}
+
+func TestMiddlewareContinuesTraceAndNamesRoute(t *testing.T) {
+	spanRecorder()
+
+	router := mux.NewRouter()
+	router.Use(RouteMiddleware)
+	router.HandleFunc("/cards/{id}", func(w http.ResponseWriter, r *http.Request) {
+		_, span := Start(r.Context(), "test.child")
+		span.End()
+	})
+	router.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {})
+	handler := Middleware(router)
+
+	req := httptest.NewRequest(http.MethodGet, "/cards/42", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	server := endedSpan(t, "GET /cards/{id}")
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("server span trace ID = %s, want the incoming one", got)
+	}
+	if got := server.Parent().SpanID().String(); got != "00f067aa0ba902b7" {
+		t.Errorf("server span parent = %s, want the incoming span", got)
+	}
+	if child := endedSpan(t, "test.child"); child.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Error("handler span is not a child of the server span")
+	}
+
+	for _, span := range spanRecorder().Ended() {
+		if span.Name() == "GET /metrics" || span.Name() == "GET" {
+			t.Errorf("unexpected span %q for a metrics scrape", span.Name())
+		}
+	}
+}
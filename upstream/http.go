@@ -0,0 +1,112 @@
+package upstream
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/tracing"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// HTTPSource fetches the catalogue from the upstream card API and images from the
+// URLs the catalogue lists
+type HTTPSource struct {
+	url    string
+	client *http.Client
+}
+
+// NewHTTPSource creates a source for the catalogue served at url, bounding each
+// request by timeout
+func NewHTTPSource(url string, timeout time.Duration) *HTTPSource {
+	return &HTTPSource{
+		url: url,
+		client: &http.Client{
+			Timeout:   timeout,
+			Transport: tracing.Transport(nil),
+		},
+	}
+}
+
+// Name returns the catalogue URL
+func (s *HTTPSource) Name() string {
+	return s.url
+}
+
+// FetchCatalogue downloads and decodes the catalogue. Its version is the hash of
+// the response body.
+func (s *HTTPSource) FetchCatalogue(ctx context.Context) (*Catalogue, error) {
+	body, err := s.get(ctx, s.url)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch cards from API: %w", err)
+	}
+
+	var apiResponse models.APIResponse
+	if err := json.Unmarshal(body, &apiResponse); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal JSON response: %w", err)
+	}
+	return &Catalogue{Cards: apiResponse.Data, Version: catalogueVersion(body)}, nil
+}
+
+// FetchImage downloads an image. When the server sends no content type it is
+// guessed from the URL's extension.
+func (s *HTTPSource) FetchImage(ctx context.Context, url string) ([]byte, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to create image request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to download image: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, "", fmt.Errorf("image download returned status code: %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image data: %w", err)
+	}
+
+	contentType := resp.Header.Get("Content-Type")
+	if contentType == "" {
+		contentType = contentTypeFromURL(url)
+	}
+	return data, contentType, nil
+}
+
+// get performs a GET request and returns the body of a 200 response
+func (s *HTTPSource) get(ctx context.Context, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create API request: %w", err)
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("API returned status code: %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read response body: %w", err)
+	}
+	return body, nil
+}
+
+// contentTypeFromURL guesses an image content type from its URL, defaulting to JPEG
+func contentTypeFromURL(url string) string {
+	if strings.HasSuffix(strings.ToLower(url), ".png") {
+		return "image/png"
+	}
+	return "image/jpeg"
+}
@@ -0,0 +1,183 @@
+package upstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/models"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+)
+
+// FileSource reads the catalogue from a local JSON file in the upstream API's format.
+// Images are read from local paths relative to the file, or downloaded when the
+// catalogue lists http or https URLs.
+type FileSource struct {
+	path   string
+	images localImages
+}
+
+// NewFileSource creates a source for the catalogue file at path, bounding image
+// downloads by timeout
+func NewFileSource(path string, timeout time.Duration) *FileSource {
+	return &FileSource{
+		path:   path,
+		images: localImages{dir: filepath.Dir(path), remote: NewHTTPSource("", timeout)},
+	}
+}
+
+// Name returns the catalogue file path
+func (s *FileSource) Name() string {
+	return "file:" + s.path
+}
+
+// FetchCatalogue reads and decodes the catalogue file. Its version is the hash of
+// the file's contents.
+func (s *FileSource) FetchCatalogue(ctx context.Context) (*Catalogue, error) {
+	data, err := os.ReadFile(s.path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalogue file: %w", err)
+	}
+
+	var catalogue models.APIResponse
+	if err := json.Unmarshal(data, &catalogue); err != nil {
+		return nil, fmt.Errorf("failed to parse catalogue file %s: %w", s.path, err)
+	}
+	return &Catalogue{Cards: catalogue.Data, Version: catalogueVersion(data)}, nil
+}
+
+// FetchImage reads or downloads an image listed in the catalogue
+func (s *FileSource) FetchImage(ctx context.Context, url string) ([]byte, string, error) {
+	return s.images.fetch(ctx, url)
+}
+
+// DirSource reads the catalogue from a directory of JSON fixtures. Each file holds
+// either a single card or a catalogue in the upstream API's format, and cards are
+// ingested in ID order. Images are resolved like FileSource's, relative to the
+// directory.
+type DirSource struct {
+	dir    string
+	images localImages
+}
+
+// NewDirSource creates a source for the fixtures in dir, bounding image downloads
+// by timeout
+func NewDirSource(dir string, timeout time.Duration) *DirSource {
+	return &DirSource{
+		dir:    dir,
+		images: localImages{dir: dir, remote: NewHTTPSource("", timeout)},
+	}
+}
+
+// Name returns the fixture directory
+func (s *DirSource) Name() string {
+	return "dir:" + s.dir
+}
+
+// FetchCatalogue reads every *.json fixture in the directory. Its version is the
+// hash of the fixtures' names and contents, so editing, adding or removing one
+// starts a new catalogue.
+func (s *DirSource) FetchCatalogue(ctx context.Context) (*Catalogue, error) {
+	files, err := filepath.Glob(filepath.Join(s.dir, "*.json"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list fixtures: %w", err)
+	}
+	sort.Strings(files)
+
+	hash := sha256.New()
+	seen := make(map[int64]string)
+	var cards []models.Card
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read fixture: %w", err)
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.Base(file), len(data))
+		hash.Write(data)
+
+		fixture, err := parseFixture(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse fixture %s: %w", file, err)
+		}
+		for _, card := range fixture {
+			if other, ok := seen[card.ID]; ok {
+				return nil, fmt.Errorf("card %d is defined in both %s and %s", card.ID, other, file)
+			}
+			seen[card.ID] = file
+			cards = append(cards, card)
+		}
+	}
+
+	sort.Slice(cards, func(i, j int) bool { return cards[i].ID < cards[j].ID })
+	return &Catalogue{Cards: cards, Version: hex.EncodeToString(hash.Sum(nil))}, nil
+}
+
+// FetchImage reads or downloads an image listed in a fixture
+func (s *DirSource) FetchImage(ctx context.Context, url string) ([]byte, string, error) {
+	return s.images.fetch(ctx, url)
+}
+
+// parseFixture decodes a fixture holding either a catalogue or a single card
+func parseFixture(data []byte) ([]models.Card, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields["data"]; ok {
+		var catalogue models.APIResponse
+		if err := json.Unmarshal(data, &catalogue); err != nil {
+			return nil, err
+		}
+		return catalogue.Data, nil
+	}
+
+	var card models.Card
+	if err := json.Unmarshal(data, &card); err != nil {
+		return nil, err
+	}
+	if card.ID == 0 {
+		return nil, fmt.Errorf("neither a card with an id nor a catalogue with data")
+	}
+	return []models.Card{card}, nil
+}
+
+// localImages resolves image URLs listed by a local catalogue. http and https URLs
+// are downloaded; file URLs and plain paths are read from disk, relative paths
+// from dir.
+type localImages struct {
+	dir    string
+	remote *HTTPSource
+}
+
+func (l localImages) fetch(ctx context.Context, raw string) ([]byte, string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, "", fmt.Errorf("invalid image URL %q: %w", raw, err)
+	}
+
+	path := raw
+	switch u.Scheme {
+	case "http", "https":
+		return l.remote.FetchImage(ctx, raw)
+	case "file":
+		path = u.Path
+	case "":
+	default:
+		return nil, "", fmt.Errorf("unsupported image URL scheme %q", u.Scheme)
+	}
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(l.dir, path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read image: %w", err)
+	}
+	return data, contentTypeFromURL(path), nil
+}
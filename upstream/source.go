@@ -0,0 +1,33 @@
+// Package upstream provides the card catalogue and card images that ingest stores
+package upstream
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"index-duel-backend/models"
+)
+
+// Catalogue is the full list of cards offered by a source
+type Catalogue struct {
+	Cards []models.Card
+	// Version identifies this exact catalogue, so an interrupted ingest only resumes
+	// over the same data
+	Version string
+}
+
+// CardSource provides the upstream card catalogue and card images
+type CardSource interface {
+	// Name identifies the source in ingest checkpoints and ingest runs
+	Name() string
+	// FetchCatalogue returns every card the source offers
+	FetchCatalogue(ctx context.Context) (*Catalogue, error)
+	// FetchImage returns the image stored at url along with its content type
+	FetchImage(ctx context.Context, url string) ([]byte, string, error)
+}
+
+// catalogueVersion identifies a catalogue by the hash of its raw payload
+func catalogueVersion(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
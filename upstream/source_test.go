@@ -0,0 +1,199 @@
+package upstream
+
+import (
+	"context"
+	"fmt"
+	"index-duel-backend/models"
+	"index-duel-backend/upstream/upstreamtest"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func cardIDs(cards []models.Card) []int64 {
+	ids := make([]int64, len(cards))
+	for i := range cards {
+		ids[i] = cards[i].ID
+	}
+	return ids
+}
+
+func TestHTTPSource(t *testing.T) {
+	server := upstreamtest.NewServer([]models.Card{{ID: 1, CardImages: []models.CardImage{{}}}, {ID: 2}})
+	defer server.Close()
+	ctx := context.Background()
+
+	tests := []struct {
+		name      string
+		failure   *upstreamtest.Failure
+		latency   time.Duration
+		wantError string
+	}{
+		{"catalogue served", nil, 0, ""},
+		{"server error", &upstreamtest.Failure{Prefix: upstreamtest.CataloguePath, Status: http.StatusBadGateway}, 0, "status code: 502"},
+		{"dropped connection", &upstreamtest.Failure{Prefix: upstreamtest.CataloguePath}, 0, "failed to fetch cards"},
+		{"slower than the timeout", nil, 500 * time.Millisecond, "Client.Timeout"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server.Reset()
+			if tt.failure != nil {
+				server.Fail(*tt.failure)
+			}
+			server.SetLatency(tt.latency)
+
+			source := NewHTTPSource(server.CatalogueURL(), 200*time.Millisecond)
+			catalogue, err := source.FetchCatalogue(ctx)
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("error = %v, want one mentioning %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchCatalogue failed: %v", err)
+			}
+			if len(catalogue.Cards) != 2 || catalogue.Version == "" {
+				t.Fatalf("catalogue = %v cards, version %q", cardIDs(catalogue.Cards), catalogue.Version)
+			}
+
+			data, contentType, err := source.FetchImage(ctx, catalogue.Cards[0].CardImages[0].ImageURLSmall)
+			if err != nil || contentType != "image/jpeg" || len(data) == 0 {
+				t.Errorf("FetchImage = %d bytes, %q, %v", len(data), contentType, err)
+			}
+		})
+	}
+}
+
+func TestFileSource(t *testing.T) {
+	dir := writeFiles(t, map[string]string{
+		"images/2.png":       "png bytes",
+		"images/small/2.jpg": "jpeg bytes",
+	})
+	// Relative paths resolve against the catalogue's directory; file URLs are absolute
+	path := filepath.Join(dir, "catalogue.json")
+	catalogueJSON := fmt.Sprintf(`{"data": [
+		{"id": 2, "name": "Kuriboh", "card_images": [{"image_url": "images/2.png", "image_url_small": "file://%s/images/small/2.jpg"}]},
+		{"id": 1, "name": "Dark Magician"}
+	]}`, filepath.ToSlash(dir))
+	if err := os.WriteFile(path, []byte(catalogueJSON), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	ctx := context.Background()
+	source := NewFileSource(path, time.Second)
+	catalogue, err := source.FetchCatalogue(ctx)
+	if err != nil {
+		t.Fatalf("FetchCatalogue failed: %v", err)
+	}
+	// The file's order is kept, as it is for the API
+	if ids := cardIDs(catalogue.Cards); len(ids) != 2 || ids[0] != 2 {
+		t.Fatalf("cards = %v, want [2 1]", ids)
+	}
+
+	image := catalogue.Cards[0].CardImages[0]
+	for _, tt := range []struct{ url, wantData, wantType string }{
+		{image.ImageURL, "png bytes", "image/png"},
+		{image.ImageURLSmall, "jpeg bytes", "image/jpeg"},
+	} {
+		data, contentType, err := source.FetchImage(ctx, tt.url)
+		if err != nil || string(data) != tt.wantData || contentType != tt.wantType {
+			t.Errorf("FetchImage(%s) = %q, %q, %v", tt.url, data, contentType, err)
+		}
+	}
+	if _, _, err := source.FetchImage(ctx, "images/missing.jpg"); err == nil {
+		t.Error("FetchImage of a missing file succeeded")
+	}
+}
+
+func TestDirSource(t *testing.T) {
+	tests := []struct {
+		name      string
+		files     map[string]string
+		wantIDs   []int64
+		wantError string
+	}{
+		{
+			name: "cards and catalogues in ID order",
+			files: map[string]string{
+				"b.json":     `{"id": 30, "name": "Card 30"}`,
+				"a.json":     `{"data": [{"id": 20}, {"id": 10}]}`,
+				"notes.txt":  `ignored`,
+				"empty.json": `{"data": []}`,
+			},
+			wantIDs: []int64{10, 20, 30},
+		},
+		{
+			name:      "duplicate card",
+			files:     map[string]string{"a.json": `{"id": 1}`, "b.json": `{"data": [{"id": 1}]}`},
+			wantError: "card 1 is defined in both",
+		},
+		{
+			name:      "card without an id",
+			files:     map[string]string{"a.json": `{"name": "Nameless"}`},
+			wantError: "neither a card",
+		},
+		{
+			name:      "malformed JSON",
+			files:     map[string]string{"a.json": `{"id": `},
+			wantError: "failed to parse fixture",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			source := NewDirSource(writeFiles(t, tt.files), time.Second)
+			catalogue, err := source.FetchCatalogue(context.Background())
+			if tt.wantError != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantError) {
+					t.Fatalf("error = %v, want one mentioning %q", err, tt.wantError)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FetchCatalogue failed: %v", err)
+			}
+			if ids := cardIDs(catalogue.Cards); len(ids) != len(tt.wantIDs) || ids[0] != tt.wantIDs[0] || ids[len(ids)-1] != tt.wantIDs[len(tt.wantIDs)-1] {
+				t.Errorf("cards = %v, want %v", ids, tt.wantIDs)
+			}
+		})
+	}
+}
+
+func TestDirSourceVersion(t *testing.T) {
+	dir := writeFiles(t, map[string]string{"a.json": `{"id": 1}`})
+	source := NewDirSource(dir, time.Second)
+	version := func() string {
+		catalogue, err := source.FetchCatalogue(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		return catalogue.Version
+	}
+
+	first := version()
+	if again := version(); again != first {
+		t.Errorf("version changed without edits: %s != %s", again, first)
+	}
+	os.WriteFile(filepath.Join(dir, "b.json"), []byte(`{"id": 2}`), 0o644)
+	if added := version(); added == first {
+		t.Error("version did not change when a fixture was added")
+	}
+}
@@ -0,0 +1,185 @@
+// Package upstreamtest provides a fake upstream card API for tests. It serves a
+// catalogue and card images, and can be told to fail or slow down requests.
+package upstreamtest
+
+import (
+	"encoding/json"
+	"fmt"
+	"index-duel-backend/models"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CataloguePath is where the server serves the catalogue
+const CataloguePath = "/api/v7/cardinfo.php"
+
+// imagePrefix is where the server serves card images
+const imagePrefix = "/images/"
+
+// Failure makes requests whose path starts with Prefix fail. Status is the response
+// status; zero drops the connection without a response. Times bounds how many
+// requests fail, after which the prefix is served normally; zero fails every request.
+type Failure struct {
+	Prefix string
+	Status int
+	Times  int
+}
+
+// Server is a fake upstream card API backed by httptest.Server
+type Server struct {
+	*httptest.Server
+
+	mu        sync.Mutex
+	catalogue []byte
+	latency   time.Duration
+	failures  []*Failure
+	requests  map[string]int
+}
+
+// NewServer starts a server offering cards. Every image of every card is pointed at
+// the server, which answers each image path with its own bytes. Close the server
+// when done.
+func NewServer(cards []models.Card) *Server {
+	s := &Server{requests: make(map[string]int)}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serve))
+	s.SetCards(cards)
+	return s
+}
+
+// CatalogueURL returns the URL of the catalogue
+func (s *Server) CatalogueURL() string {
+	return s.URL + CataloguePath
+}
+
+// SetCards replaces the catalogue, pointing its images at the server. The cards
+// passed in are not modified.
+func (s *Server) SetCards(cards []models.Card) {
+	served := make([]models.Card, len(cards))
+	for i, card := range cards {
+		card.CardImages = append([]models.CardImage(nil), card.CardImages...)
+		for j := range card.CardImages {
+			name := fmt.Sprintf("%d-%d.jpg", card.ID, j)
+			card.CardImages[j].ImageURL = s.URL + imagePrefix + name
+			card.CardImages[j].ImageURLSmall = s.URL + imagePrefix + "small/" + name
+			card.CardImages[j].ImageURLCropped = s.URL + imagePrefix + "cropped/" + name
+		}
+		served[i] = card
+	}
+
+	data, err := json.Marshal(models.APIResponse{Data: served})
+	if err != nil {
+		panic(fmt.Sprintf("upstreamtest: failed to encode catalogue: %v", err))
+	}
+
+	s.mu.Lock()
+	s.catalogue = data
+	s.mu.Unlock()
+}
+
+// SetLatency delays every response by d
+func (s *Server) SetLatency(d time.Duration) {
+	s.mu.Lock()
+	s.latency = d
+	s.mu.Unlock()
+}
+
+// Fail adds a failure rule. Rules are checked in the order they were added.
+func (s *Server) Fail(f Failure) {
+	s.mu.Lock()
+	s.failures = append(s.failures, &f)
+	s.mu.Unlock()
+}
+
+// Reset removes every failure rule and the latency
+func (s *Server) Reset() {
+	s.mu.Lock()
+	s.failures = nil
+	s.latency = 0
+	s.mu.Unlock()
+}
+
+// Requests returns how many requests have been made for paths starting with prefix
+func (s *Server) Requests(prefix string) int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	count := 0
+	for path, n := range s.requests {
+		if strings.HasPrefix(path, prefix) {
+			count += n
+		}
+	}
+	return count
+}
+
+func (s *Server) serve(w http.ResponseWriter, r *http.Request) {
+	s.mu.Lock()
+	s.requests[r.URL.Path]++
+	latency := s.latency
+	failure := s.matchFailure(r.URL.Path)
+	catalogue := s.catalogue
+	s.mu.Unlock()
+
+	if latency > 0 {
+		select {
+		case <-time.After(latency):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
+	if failure != nil {
+		if failure.Status == 0 {
+			dropConnection(w)
+			return
+		}
+		http.Error(w, http.StatusText(failure.Status), failure.Status)
+		return
+	}
+
+	switch {
+	case r.URL.Path == CataloguePath:
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(catalogue)
+	case strings.HasPrefix(r.URL.Path, imagePrefix):
+		w.Header().Set("Content-Type", "image/jpeg")
+		w.Write([]byte(r.URL.Path))
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// matchFailure returns the first failure rule for path that still applies, using
+// up one of its failures. The caller must hold s.mu.
+func (s *Server) matchFailure(path string) *Failure {
+	for i, f := range s.failures {
+		if !strings.HasPrefix(path, f.Prefix) {
+			continue
+		}
+		matched := *f
+		if f.Times > 0 {
+			f.Times--
+			if f.Times == 0 {
+				s.failures = append(s.failures[:i:i], s.failures[i+1:]...)
+			}
+		}
+		return &matched
+	}
+	return nil
+}
+
+// dropConnection closes the connection without writing a response
+func dropConnection(w http.ResponseWriter) {
+	hijacker, ok := w.(http.Hijacker)
+	if !ok {
+		panic("upstreamtest: response writer cannot be hijacked")
+	}
+	conn, _, err := hijacker.Hijack()
+	if err != nil {
+		panic(fmt.Sprintf("upstreamtest: failed to hijack connection: %v", err))
+	}
+	conn.Close()
+}